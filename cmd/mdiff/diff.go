@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/lwch/gotorch/consts"
+	"github.com/lwch/runtime"
+	"github.com/lwch/tnn/internal/mfile"
+	"github.com/lwch/tnn/internal/pb"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/proto"
+)
+
+// paramStats numeric difference between two params with the same shape
+type paramStats struct {
+	maxAbsDiff float64
+	cosine     float64
+	normRatio  float64 // |b| / |a|
+}
+
+func compare(a, b []float64) paramStats {
+	var stats paramStats
+	var dot, na, nb float64
+	for i := range a {
+		if diff := math.Abs(a[i] - b[i]); diff > stats.maxAbsDiff {
+			stats.maxAbsDiff = diff
+		}
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	na, nb = math.Sqrt(na), math.Sqrt(nb)
+	switch {
+	case na == 0 && nb == 0:
+		stats.cosine = 1
+		stats.normRatio = 1
+	case na == 0:
+		stats.normRatio = math.Inf(1)
+	case nb == 0:
+	default:
+		stats.cosine = dot / (na * nb)
+		stats.normRatio = nb / na
+	}
+	return stats
+}
+
+func formatArg(args map[string]float32, k string) string {
+	v, ok := args[k]
+	if !ok {
+		return "-"
+	}
+	return fmt.Sprintf("%g", v)
+}
+
+func formatShapes(shapes []int64) string {
+	items := make([]string, len(shapes))
+	for i, s := range shapes {
+		items[i] = fmt.Sprintf("%d", s)
+	}
+	return "(" + strings.Join(items, ", ") + ")"
+}
+
+func runDiff(_ *cobra.Command, args []string) {
+	a := open(args[0])
+	defer a.Close()
+	b := open(args[1])
+	defer b.Close()
+	changed, diffs, err := diff(os.Stdout, a, b, args[0], args[1], diffAll)
+	runtime.Assert(err)
+	fmt.Printf("%d structure changes, %d params differ\n", changed, diffs)
+}
+
+// diff write structure changes and param differences of model a and b to w,
+// params without difference are written only when all is set
+func diff(w io.Writer, a, b *mfile.File, nameA, nameB string, all bool) (changed, diffs int, err error) {
+	changes := tablewriter.NewWriter(w)
+	changes.SetHeader([]string{"change", "layer", "item", nameA, nameB})
+	changes.SetAutoMergeCellsByColumnIndex([]int{1})
+	params := tablewriter.NewWriter(w)
+	params.SetHeader([]string{"layer", "param", "max abs diff", "cosine", "norm ratio"})
+	params.SetAutoMergeCellsByColumnIndex([]int{0})
+	add := func(row ...string) {
+		changes.Append(row)
+		changed++
+	}
+	for _, la := range a.Spec.GetLayers() {
+		lb := b.Layer(la.GetName())
+		if lb == nil {
+			add("removed", la.GetName(), la.GetClass(), "", "")
+			continue
+		}
+		if la.GetClass() != lb.GetClass() {
+			add("class", la.GetName(), "", la.GetClass(), lb.GetClass())
+			continue
+		}
+		keys := make(map[string]bool)
+		for k := range la.GetArgs() {
+			keys[k] = true
+		}
+		for k := range lb.GetArgs() {
+			keys[k] = true
+		}
+		names := make([]string, 0, len(keys))
+		for k := range keys {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			va, vb := formatArg(la.GetArgs(), k), formatArg(lb.GetArgs(), k)
+			if va != vb {
+				add("arg", la.GetName(), k, va, vb)
+			}
+		}
+		for _, name := range mfile.ParamNames(la) {
+			paramA := la.GetParams()[name]
+			paramB := lb.GetParams()[name]
+			if paramB == nil {
+				add("param removed", la.GetName(), name, formatShapes(paramA.GetShapes()), "")
+				continue
+			}
+			if formatShapes(paramA.GetShapes()) != formatShapes(paramB.GetShapes()) {
+				add("shape", la.GetName(), name, formatShapes(paramA.GetShapes()), formatShapes(paramB.GetShapes()))
+				continue
+			}
+			if paramA.GetType() != paramB.GetType() {
+				add("dtype", la.GetName(), name,
+					consts.ScalarType(paramA.GetType()).String(),
+					consts.ScalarType(paramB.GetType()).String())
+			}
+			stats, err := diffParam(a, b, paramA, paramB)
+			if err != nil {
+				return 0, 0, fmt.Errorf("%s.%s: %v", la.GetName(), name, err)
+			}
+			if stats.maxAbsDiff == 0 && !all {
+				continue
+			}
+			if stats.maxAbsDiff != 0 {
+				diffs++
+			}
+			params.Append([]string{la.GetName(), name,
+				fmt.Sprintf("%g", stats.maxAbsDiff),
+				fmt.Sprintf("%.6f", stats.cosine),
+				fmt.Sprintf("%.6f", stats.normRatio)})
+		}
+		for _, name := range mfile.ParamNames(lb) {
+			if _, ok := la.GetParams()[name]; !ok {
+				add("param added", la.GetName(), name, "", formatShapes(lb.GetParams()[name].GetShapes()))
+			}
+		}
+	}
+	for _, lb := range b.Spec.GetLayers() {
+		if a.Layer(lb.GetName()) == nil {
+			add("added", lb.GetName(), lb.GetClass(), "", "")
+		}
+	}
+	if !proto.Equal(a.Spec.GetGraph(), b.Spec.GetGraph()) ||
+		!proto.Equal(&pb.Net{Modules: a.Spec.GetModules()}, &pb.Net{Modules: b.Spec.GetModules()}) {
+		add("graph", "", "", "", "")
+	}
+	entries, err := diffExtras(a, b)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, row := range entries {
+		add(row...)
+	}
+	if changed > 0 {
+		changes.Render()
+	}
+	if params.NumLines() > 0 {
+		params.Render()
+	}
+	return changed, diffs, nil
+}
+
+// diffExtras compare entries which are neither SPEC nor params, e.g. PREPROCESS
+func diffExtras(a, b *mfile.File) ([][]string, error) {
+	inB := make(map[string]bool)
+	for _, name := range b.Extras() {
+		inB[name] = true
+	}
+	var rows [][]string
+	for _, name := range a.Extras() {
+		if !inB[name] {
+			rows = append(rows, []string{"entry removed", "", name, "", ""})
+			continue
+		}
+		delete(inB, name)
+		da, err := a.ReadEntry(name)
+		if err != nil {
+			return nil, err
+		}
+		db, err := b.ReadEntry(name)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(da, db) {
+			rows = append(rows, []string{"entry", "", name, "", ""})
+		}
+	}
+	for _, name := range b.Extras() {
+		if inB[name] {
+			rows = append(rows, []string{"entry added", "", name, "", ""})
+		}
+	}
+	return rows, nil
+}
+
+func diffParam(a, b *mfile.File, paramA, paramB *pb.Param) (paramStats, error) {
+	da, err := a.Read(paramA)
+	if err != nil {
+		return paramStats{}, err
+	}
+	db, err := b.Read(paramB)
+	if err != nil {
+		return paramStats{}, err
+	}
+	return compare(mfile.Float64s(consts.ScalarType(paramA.GetType()), da),
+		mfile.Float64s(consts.ScalarType(paramB.GetType()), db)), nil
+}
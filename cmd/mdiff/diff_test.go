@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lwch/tnn/internal/mfile"
+)
+
+func TestCompare(t *testing.T) {
+	stats := compare([]float64{1, 0}, []float64{0, 2})
+	if stats.maxAbsDiff != 2 || stats.cosine != 0 || stats.normRatio != 2 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	stats = compare([]float64{1, 2}, []float64{2, 4})
+	if stats.maxAbsDiff != 2 || math.Abs(stats.cosine-1) > 1e-9 || stats.normRatio != 2 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.model")
+	b := filepath.Join(dir, "b.model")
+	writeModel(t, a, testSpec(), []float64{1, 2}, 3, nil)
+	spec := testSpec()
+	spec.Layers[0].Args["output"] = 3
+	writeModel(t, b, spec, []float64{1, 4}, 3, map[string][]byte{"PREPROCESS": []byte("preprocess")})
+	ma, err := mfile.Open(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ma.Close()
+	mb, err := mfile.Open(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mb.Close()
+
+	var buf bytes.Buffer
+	changed, diffs, err := diff(&buf, ma, mb, "a", "b", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// arg output and entry PREPROCESS changed, only w differs
+	if changed != 2 || diffs != 1 {
+		t.Fatalf("expect 2 changes and 1 diff, got %d and %d:\n%s", changed, diffs, buf.String())
+	}
+	for _, s := range []string{"entry added", "PREPROCESS", "output"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Fatalf("expect %q in output:\n%s", s, buf.String())
+		}
+	}
+
+	buf.Reset()
+	changed, diffs, err = diff(&buf, ma, ma, "a", "a", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed != 0 || diffs != 0 {
+		t.Fatalf("expect no difference, got %d changes and %d diffs", changed, diffs)
+	}
+	if !strings.Contains(buf.String(), "| w ") || !strings.Contains(buf.String(), "| b ") {
+		t.Fatalf("expect all params in output:\n%s", buf.String())
+	}
+}
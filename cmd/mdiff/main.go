@@ -0,0 +1,49 @@
+package main
+
+import (
+	"github.com/lwch/runtime"
+	"github.com/lwch/tnn/internal/mfile"
+	"github.com/spf13/cobra"
+)
+
+var rootCmd = cobra.Command{
+	Use: "mdiff",
+}
+
+var diffCmd = cobra.Command{
+	Use:   "diff [model a] [model b]",
+	Short: "compare layers and params of two models",
+	Args:  cobra.ExactArgs(2),
+	Run:   runDiff,
+}
+
+var mergeCmd = cobra.Command{
+	Use:   "merge [output] [models...]",
+	Short: "average params of models with the same structure",
+	Args:  cobra.MinimumNArgs(3),
+	Run:   runMerge,
+}
+
+var diffAll bool
+var mergeMethod string
+var mergeWeights string
+var mergeEMA float64
+
+func main() {
+	diffCmd.Flags().BoolVar(&diffAll, "all", false, "show params without difference")
+	mergeCmd.Flags().StringVar(&mergeMethod, "method", "uniform", "merge method: uniform, weighted or ema")
+	mergeCmd.Flags().StringVar(&mergeWeights, "weights", "", "comma separated non-negative weights of models for weighted method")
+	mergeCmd.Flags().Float64Var(&mergeEMA, "ema", 0.9, "decay of exponential moving average for ema method, in (0, 1)")
+
+	rootCmd.AddCommand(&diffCmd)
+	rootCmd.AddCommand(&mergeCmd)
+
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
+	runtime.Assert(rootCmd.Execute())
+}
+
+func open(dir string) *mfile.File {
+	mf, err := mfile.Open(dir)
+	runtime.Assert(err)
+	return mf
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/lwch/gotorch/consts"
+	"github.com/lwch/runtime"
+	"github.com/lwch/tnn/internal/mfile"
+	"github.com/lwch/tnn/internal/pb"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/proto"
+)
+
+// checkpointFile entry of training state saved by net.SaveCheckpoint,
+// it is not copied to the merged model
+const checkpointFile = "CHECKPOINT"
+
+// checkSame check models have the same layers, args, params, graph, modules
+// and other entries, e.g. PREPROCESS
+func checkSame(a, b *mfile.File) error {
+	if err := checkSpec(a.Spec, b.Spec); err != nil {
+		return err
+	}
+	extrasA, extrasB := mergeExtras(a), mergeExtras(b)
+	if len(extrasA) != len(extrasB) {
+		return fmt.Errorf("mismatched entries: %v and %v", extrasA, extrasB)
+	}
+	for i, name := range extrasA {
+		if extrasB[i] != name {
+			return fmt.Errorf("mismatched entries: %v and %v", extrasA, extrasB)
+		}
+		da, err := a.ReadEntry(name)
+		if err != nil {
+			return err
+		}
+		db, err := b.ReadEntry(name)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(da, db) {
+			return fmt.Errorf("mismatched %s", name)
+		}
+	}
+	return nil
+}
+
+func checkSpec(a, b *pb.Net) error {
+	if len(a.GetLayers()) != len(b.GetLayers()) {
+		return fmt.Errorf("mismatched layer count: %d and %d", len(a.GetLayers()), len(b.GetLayers()))
+	}
+	for i, la := range a.GetLayers() {
+		lb := b.GetLayers()[i]
+		if la.GetName() != lb.GetName() || la.GetClass() != lb.GetClass() {
+			return fmt.Errorf("layer %d: mismatched %s(%s) and %s(%s)", i,
+				la.GetClass(), la.GetName(), lb.GetClass(), lb.GetName())
+		}
+		if !proto.Equal(&pb.Layer{Args: la.GetArgs()}, &pb.Layer{Args: lb.GetArgs()}) {
+			return fmt.Errorf("layer %s: mismatched args", la.GetName())
+		}
+		if len(la.GetParams()) != len(lb.GetParams()) {
+			return fmt.Errorf("layer %s: mismatched param count", la.GetName())
+		}
+		for name, paramA := range la.GetParams() {
+			paramB := lb.GetParams()[name]
+			if paramB == nil {
+				return fmt.Errorf("layer %s: param %s not found", la.GetName(), name)
+			}
+			if paramA.GetType() != paramB.GetType() ||
+				formatShapes(paramA.GetShapes()) != formatShapes(paramB.GetShapes()) {
+				return fmt.Errorf("layer %s: mismatched param %s", la.GetName(), name)
+			}
+		}
+	}
+	if !proto.Equal(a.GetGraph(), b.GetGraph()) {
+		return fmt.Errorf("mismatched graph")
+	}
+	if !proto.Equal(&pb.Net{Modules: a.GetModules()}, &pb.Net{Modules: b.GetModules()}) {
+		return fmt.Errorf("mismatched modules")
+	}
+	return nil
+}
+
+// mergeExtras get names of entries copied to the merged model
+func mergeExtras(mf *mfile.File) []string {
+	var ret []string
+	for _, name := range mf.Extras() {
+		if name != checkpointFile {
+			ret = append(ret, name)
+		}
+	}
+	return ret
+}
+
+// mergeWeightsOf get weight of each model by method, ema merges models in
+// order by avg = decay*avg + (1-decay)*model which equals to weighted average
+func mergeWeightsOf(method, items string, decay float64, n int) ([]float64, error) {
+	weights := make([]float64, n)
+	switch method {
+	case "uniform":
+		for i := range weights {
+			weights[i] = 1 / float64(n)
+		}
+	case "ema":
+		if decay <= 0 || decay >= 1 {
+			return nil, fmt.Errorf("invalid ema decay: %g", decay)
+		}
+		w := 1.0
+		for i := n - 1; i > 0; i-- {
+			weights[i] = w * (1 - decay)
+			w *= decay
+		}
+		weights[0] = w
+	case "weighted":
+		if len(items) == 0 {
+			return nil, fmt.Errorf("--weights is required by weighted method")
+		}
+		values := strings.Split(items, ",")
+		if len(values) != n {
+			return nil, fmt.Errorf("got %d weights for %d models", len(values), n)
+		}
+		var sum float64
+		for i, item := range values {
+			v, err := strconv.ParseFloat(strings.TrimSpace(item), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid weight %q: %v", item, err)
+			}
+			if v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+				return nil, fmt.Errorf("invalid weight %q: must be finite and non-negative", item)
+			}
+			weights[i] = v
+			sum += v
+		}
+		if sum == 0 {
+			return nil, fmt.Errorf("sum of weights is zero")
+		}
+		for i := range weights {
+			weights[i] /= sum
+		}
+	default:
+		return nil, fmt.Errorf("unsupported merge method: %s", method)
+	}
+	return weights, nil
+}
+
+func runMerge(cmd *cobra.Command, args []string) {
+	if cmd.Flags().Changed("weights") && mergeMethod != "weighted" {
+		runtime.Assert(fmt.Errorf("--weights can only be used with --method weighted"))
+	}
+	if cmd.Flags().Changed("ema") && mergeMethod != "ema" {
+		runtime.Assert(fmt.Errorf("--ema can only be used with --method ema"))
+	}
+	weights, err := mergeWeightsOf(mergeMethod, mergeWeights, mergeEMA, len(args)-1)
+	runtime.Assert(err)
+	if err = merge(args[0], args[1:], weights); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Printf("merged %d models into %s\n", len(args)-1, args[0])
+}
+
+// merge average params of inputs by weights and write to output, other
+// entries except CHECKPOINT are copied from the first model
+func merge(output string, inputs []string, weights []float64) error {
+	models := make([]*mfile.File, 0, len(inputs))
+	defer func() {
+		for _, mf := range models {
+			mf.Close()
+		}
+	}()
+	for _, dir := range inputs {
+		mf, err := mfile.Open(dir)
+		if err != nil {
+			return fmt.Errorf("%s: %v", dir, err)
+		}
+		models = append(models, mf)
+		if len(models) > 1 {
+			if err := checkSame(models[0], mf); err != nil {
+				return fmt.Errorf("%s: %v", dir, err)
+			}
+		}
+	}
+	extras := make(map[string][]byte)
+	for _, name := range mergeExtras(models[0]) {
+		data, err := models[0].ReadEntry(name)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		extras[name] = data
+	}
+
+	tmp := output + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	err = mfile.Write(f, models[0].Spec, func(param *pb.Param) ([]byte, error) {
+		return mergeParam(models, weights, param)
+	}, extras)
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, output)
+}
+
+// mergeParam average floating point params by weights, others must be
+// identical in all models
+func mergeParam(models []*mfile.File, weights []float64, param *pb.Param) ([]byte, error) {
+	t := consts.ScalarType(param.GetType())
+	data, err := models[0].Read(param)
+	if err != nil {
+		return nil, err
+	}
+	if !mfile.IsFloat(t) {
+		for _, mf := range models[1:] {
+			other, err := mf.Read(param)
+			if err != nil {
+				return nil, err
+			}
+			if !bytes.Equal(data, other) {
+				return nil, fmt.Errorf("can not merge different %s params", t.String())
+			}
+		}
+		return data, nil
+	}
+	sum := mfile.Float64s(t, data)
+	for i := range sum {
+		sum[i] *= weights[0]
+	}
+	for j, mf := range models[1:] {
+		data, err := mf.Read(param)
+		if err != nil {
+			return nil, err
+		}
+		values := mfile.Float64s(t, data)
+		for i := range sum {
+			sum[i] += values[i] * weights[j+1]
+		}
+	}
+	return mfile.EncodeFloats(t, sum), nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lwch/gotorch/consts"
+	"github.com/lwch/tnn/internal/mfile"
+	"github.com/lwch/tnn/internal/pb"
+)
+
+func testSpec() *pb.Net {
+	return &pb.Net{Layers: []*pb.Layer{{
+		Class: "linear",
+		Name:  "output",
+		Args:  map[string]float32{"output": 2},
+		Params: map[string]*pb.Param{
+			"w": {Type: uint32(consts.KFloat), ElemCount: 2, Name: "w", Shapes: []int64{1, 2}, File: "layer_0_param_w.bin"},
+			"b": {Type: uint32(consts.KInt64), ElemCount: 1, Name: "b", Shapes: []int64{1}, File: "layer_0_param_b.bin"},
+		},
+	}}}
+}
+
+// writeModel write model with float param w and int param b
+func writeModel(t *testing.T, dir string, spec *pb.Net, w []float64, b int64, extras map[string][]byte) {
+	var buf bytes.Buffer
+	err := mfile.Write(&buf, spec, func(param *pb.Param) ([]byte, error) {
+		if param.GetName() == "w" {
+			return mfile.EncodeFloats(consts.KFloat, w), nil
+		}
+		data := make([]byte, 8)
+		binary.BigEndian.PutUint64(data, uint64(b))
+		return data, nil
+	}, extras)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(dir, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMergeWeights(t *testing.T) {
+	tests := []struct {
+		method string
+		items  string
+		decay  float64
+		expect []float64
+	}{
+		{"uniform", "", 0, []float64{0.25, 0.25, 0.25, 0.25}},
+		{"weighted", "1,0,1,2", 0, []float64{0.25, 0, 0.25, 0.5}},
+		{"ema", "", 0.5, []float64{0.125, 0.125, 0.25, 0.5}},
+	}
+	for _, tt := range tests {
+		got, err := mergeWeightsOf(tt.method, tt.items, tt.decay, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range got {
+			if math.Abs(got[i]-tt.expect[i]) > 1e-9 {
+				t.Fatalf("%s: expect %v, got %v", tt.method, tt.expect, got)
+			}
+		}
+	}
+	invalid := []struct {
+		method string
+		items  string
+		decay  float64
+	}{
+		{"weighted", "", 0},
+		{"weighted", "1,2", 0},
+		{"weighted", "1,-1,1,1", 0},
+		{"weighted", "0,0,0,0", 0},
+		{"ema", "", 0},
+		{"ema", "", 1},
+		{"median", "", 0},
+	}
+	for _, tt := range invalid {
+		if _, err := mergeWeightsOf(tt.method, tt.items, tt.decay, 4); err == nil {
+			t.Fatalf("%s %q %g: expect error", tt.method, tt.items, tt.decay)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	dir := t.TempDir()
+	extras := map[string][]byte{"PREPROCESS": []byte("preprocess")}
+	a := filepath.Join(dir, "a.model")
+	b := filepath.Join(dir, "b.model")
+	writeModel(t, a, testSpec(), []float64{1, 2}, 3, extras)
+	writeModel(t, b, testSpec(), []float64{3, 6}, 3, map[string][]byte{
+		"PREPROCESS": []byte("preprocess"),
+		"CHECKPOINT": []byte("checkpoint"),
+	})
+	output := filepath.Join(dir, "avg.model")
+	if err := merge(output, []string{a, b}, []float64{0.25, 0.75}); err != nil {
+		t.Fatal(err)
+	}
+	mf, err := mfile.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mf.Close()
+	data, err := mf.Read(mf.Layer("output").GetParams()["w"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := mfile.Float64s(consts.KFloat, data); got[0] != 2.5 || got[1] != 5 {
+		t.Fatalf("expect [2.5 5], got %v", got)
+	}
+	if extras := mf.Extras(); len(extras) != 1 || extras[0] != "PREPROCESS" {
+		t.Fatalf("unexpected extras: %v", extras)
+	}
+	if data, err = mf.ReadEntry("PREPROCESS"); err != nil || string(data) != "preprocess" {
+		t.Fatalf("unexpected PREPROCESS: %q, %v", data, err)
+	}
+}
+
+func TestMergeMismatch(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.model")
+	writeModel(t, a, testSpec(), []float64{1, 2}, 3, nil)
+	modules := testSpec()
+	modules.Modules = []*pb.Graph{{Name: "block", Inputs: 1}}
+	tests := []struct {
+		name   string
+		spec   *pb.Net
+		b      int64
+		extras map[string][]byte
+		expect string
+	}{
+		{"int", testSpec(), 4, nil, "can not merge"},
+		{"preprocess", testSpec(), 3, map[string][]byte{"PREPROCESS": []byte("preprocess")}, "mismatched entries"},
+		{"modules", modules, 3, nil, "mismatched modules"},
+	}
+	for _, tt := range tests {
+		b := filepath.Join(dir, tt.name+".model")
+		writeModel(t, b, tt.spec, []float64{1, 2}, tt.b, tt.extras)
+		err := merge(filepath.Join(dir, "avg.model"), []string{a, b}, []float64{0.5, 0.5})
+		if err == nil || !strings.Contains(err.Error(), tt.expect) {
+			t.Fatalf("%s: expect error contains %q, got %v", tt.name, tt.expect, err)
+		}
+	}
+	index := filepath.Join(dir, "model.index.json")
+	if err := os.WriteFile(index, []byte(`{"weight_map": {}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := merge(filepath.Join(dir, "avg.model"), []string{a, index}, []float64{0.5, 0.5})
+	if err == nil || !strings.Contains(err.Error(), "SaveSharded") {
+		t.Fatalf("expect sharded model error, got %v", err)
+	}
+}
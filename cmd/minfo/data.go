@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/lwch/gotorch/consts"
+	"github.com/lwch/tnn/internal/mfile"
+	"github.com/lwch/tnn/internal/npy"
+)
+
+// writeCSV write param in rows of the last dimension
+func writeCSV(w io.Writer, t consts.ScalarType, shapes []int64, data []byte) error {
+	cols := 1
+	if len(shapes) > 0 {
+		cols = int(shapes[len(shapes)-1])
+	}
+	cnt := len(data) / int(mfile.ElemSize(t))
+	bw := bufio.NewWriter(w)
+	row := make([]string, 0, cols)
+	for i := 0; i < cnt; i++ {
+		row = append(row, mfile.Format(t, data, i))
+		if len(row) == cols || i == cnt-1 {
+			if _, err := fmt.Fprintln(bw, strings.Join(row, ",")); err != nil {
+				return err
+			}
+			row = row[:0]
+		}
+	}
+	return bw.Flush()
+}
+
+var npyTypes = map[consts.ScalarType]string{
+	consts.KUint8:  "|u1",
+	consts.KInt8:   "|i1",
+	consts.KBool:   "|b1",
+	consts.KInt16:  ">i2",
+	consts.KInt32:  ">i4",
+	consts.KInt64:  ">i8",
+	consts.KHalf:   ">f2",
+	consts.KFloat:  ">f4",
+	consts.KDouble: ">f8",
+}
+
+// writeNpy write param in npy format version 1.0, bfloat16 is converted to float32
+func writeNpy(w io.Writer, t consts.ScalarType, shapes []int64, data []byte) error {
+	descr, ok := npyTypes[t]
+	if t == consts.KBFloat16 {
+		descr, ok = ">f4", true
+		buf := make([]byte, len(data)*2)
+		for i := 0; i < len(data); i += 2 {
+			copy(buf[i*2:], data[i:i+2])
+		}
+		data = buf
+	}
+	if !ok {
+		return fmt.Errorf("unsupported scalar type: %s", t.String())
+	}
+	return npy.Write(w, descr, shapes, data)
+}
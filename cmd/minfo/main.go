@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/lwch/gotorch/consts"
+	"github.com/lwch/runtime"
+	"github.com/lwch/tnn/internal/mfile"
+	"github.com/lwch/tnn/internal/pb"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var rootCmd = cobra.Command{
+	Use:  "minfo [model]",
+	Args: cobra.ExactArgs(1),
+	Run:  runShow,
+}
+
+var verifyCmd = cobra.Command{
+	Use:  "verify [model]",
+	Args: cobra.ExactArgs(1),
+	Run:  runVerify,
+}
+
+var dumpCmd = cobra.Command{
+	Use:  "dump [model] [layer] [param]",
+	Args: cobra.ExactArgs(3),
+	Run:  runDump,
+}
+
+var dumpFormat string
+var dumpOutput string
+
+func main() {
+	dumpCmd.Flags().StringVar(&dumpFormat, "format", "csv", "output format, csv or npy")
+	dumpCmd.Flags().StringVarP(&dumpOutput, "output", "o", "", "output file, default is stdout")
+
+	rootCmd.AddCommand(&verifyCmd)
+	rootCmd.AddCommand(&dumpCmd)
+
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
+	runtime.Assert(rootCmd.Execute())
+}
+
+func open(dir string) *mfile.File {
+	mf, err := mfile.Open(dir)
+	runtime.Assert(err)
+	return mf
+}
+
+func formatArgs(args map[string]float32) string {
+	keys := make([]string, 0, len(args))
+	for k := range args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	items := make([]string, len(keys))
+	for i, k := range keys {
+		items[i] = fmt.Sprintf("%s=%g", k, args[k])
+	}
+	return strings.Join(items, ", ")
+}
+
+func formatShapes(shapes []int64) string {
+	items := make([]string, len(shapes))
+	for i, s := range shapes {
+		items[i] = fmt.Sprintf("%d", s)
+	}
+	return "(" + strings.Join(items, ", ") + ")"
+}
+
+func formatType(param *pb.Param) string {
+	t := consts.ScalarType(param.GetType()).String()
+	if quant := param.GetQuant(); quant != nil {
+		t += fmt.Sprintf(" (int%d, group %d, %s)", quant.GetBits(), quant.GetGroupSize(), formatShapes(quant.GetShapes()))
+	}
+	return t
+}
+
+func runShow(_ *cobra.Command, args []string) {
+	m := open(args[0])
+	defer m.Close()
+	table := tablewriter.NewWriter(os.Stdout)
+	defer table.Render()
+	table.SetHeader([]string{"class", "name", "args", "param", "shape", "dtype", "count", "compressed", "uncompressed"})
+	table.SetAutoMergeCellsByColumnIndex([]int{0, 1, 2})
+	var total, compressed, uncompressed uint64
+	for _, l := range m.Spec.GetLayers() {
+		row := []string{l.GetClass(), l.GetName(), formatArgs(l.GetArgs())}
+		if len(l.GetParams()) == 0 {
+			table.Append(append(row, "", "", "", "", "", ""))
+			continue
+		}
+		for _, name := range mfile.ParamNames(l) {
+			param := l.GetParams()[name]
+			var csize, usize string
+			if f := m.Entry(param.GetFile()); f != nil {
+				csize = fmt.Sprintf("%d", f.CompressedSize64)
+				usize = fmt.Sprintf("%d", f.UncompressedSize64)
+				compressed += f.CompressedSize64
+				uncompressed += f.UncompressedSize64
+			}
+			total += uint64(param.GetElemCount())
+			table.Append(append(row, name,
+				formatShapes(param.GetShapes()),
+				formatType(param),
+				fmt.Sprintf("%d", param.GetElemCount()),
+				csize, usize))
+		}
+	}
+	table.SetFooter([]string{"", "", "", "", "", "total",
+		fmt.Sprintf("%d", total),
+		fmt.Sprintf("%d", compressed),
+		fmt.Sprintf("%d", uncompressed)})
+	if m.Spec.GetGraph() != nil {
+		fmt.Printf("graph: %s, %d nodes, %d modules\n", m.Spec.GetGraph().GetName(),
+			len(m.Spec.GetGraph().GetNodes()), len(m.Spec.GetModules()))
+	}
+}
+
+func runVerify(_ *cobra.Command, args []string) {
+	m := open(args[0])
+	defer m.Close()
+	var failed int
+	for i, l := range m.Spec.GetLayers() {
+		for _, name := range mfile.ParamNames(l) {
+			if err := m.Verify(l.GetParams()[name]); err != nil {
+				fmt.Printf("layer %d(%s) param %s: %v\n", i, l.GetName(), name, err)
+				failed++
+			}
+		}
+	}
+	if failed > 0 {
+		fmt.Printf("%d params failed\n", failed)
+		os.Exit(1)
+	}
+	fmt.Println("ok")
+}
+
+func runDump(_ *cobra.Command, args []string) {
+	m := open(args[0])
+	defer m.Close()
+	param := m.Layer(args[1]).GetParams()[args[2]]
+	if param == nil {
+		fmt.Printf("param %s of layer %s not found\n", args[2], args[1])
+		os.Exit(1)
+	}
+	data, err := m.Read(param)
+	runtime.Assert(err)
+	w := io.Writer(os.Stdout)
+	if len(dumpOutput) > 0 {
+		f, err := os.Create(dumpOutput)
+		runtime.Assert(err)
+		defer f.Close()
+		w = f
+	}
+	t := consts.ScalarType(param.GetType())
+	switch dumpFormat {
+	case "csv":
+		runtime.Assert(writeCSV(w, t, param.GetShapes(), data))
+	case "npy":
+		runtime.Assert(writeNpy(w, t, param.GetShapes(), data))
+	default:
+		fmt.Printf("unsupported format: %s\n", dumpFormat)
+		os.Exit(1)
+	}
+}
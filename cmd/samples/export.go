@@ -0,0 +1,158 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/lwch/runtime"
+	"github.com/lwch/tnn/internal/npy"
+	"github.com/lwch/tnn/nn/sample"
+	"github.com/spf13/cobra"
+)
+
+func runExport(_ *cobra.Command, args []string) {
+	r := open(args[0])
+	defer r.Close()
+	f := detectFormat(args[1])
+	switch f {
+	case "csv", "tsv", "jsonl", "ndjson", "npz":
+	default:
+		fmt.Printf("unsupported format: %s\n", f)
+		os.Exit(1)
+	}
+	out, err := os.Create(args[1])
+	runtime.Assert(err)
+	defer out.Close()
+	switch f {
+	case "csv":
+		err = exportCSV(r, ",", out)
+	case "tsv":
+		err = exportCSV(r, "\t", out)
+	case "jsonl", "ndjson":
+		err = exportJSONL(r, out)
+	case "npz":
+		err = exportNpz(r, out)
+	}
+	runtime.Assert(err)
+}
+
+// each call fn with features and labels of each sample in order
+func each(r *sample.MmapReader, fn func(features, labels []float32) error) error {
+	features := make([]float32, r.FeatureSize())
+	labels := make([]float32, r.LabelSize())
+	for i := uint32(0); i < r.BatchSize(); i++ {
+		if err := r.ReadSample(i, features, labels); err != nil {
+			return fmt.Errorf("sample %d: %v", i, err)
+		}
+		if err := fn(features, labels); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatFloat(v float32) string {
+	return strconv.FormatFloat(float64(v), 'g', -1, 32)
+}
+
+// exportCSV write features and labels of each sample in one row, the header
+// is f0..fn and l0..ln when --header is set
+func exportCSV(r *sample.MmapReader, sep string, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	row := make([]string, 0, r.FeatureSize()+r.LabelSize())
+	if csvHeader {
+		for i := uint32(0); i < r.FeatureSize(); i++ {
+			row = append(row, fmt.Sprintf("f%d", i))
+		}
+		for i := uint32(0); i < r.LabelSize(); i++ {
+			row = append(row, fmt.Sprintf("l%d", i))
+		}
+		if _, err := fmt.Fprintln(bw, strings.Join(row, sep)); err != nil {
+			return err
+		}
+	}
+	err := each(r, func(features, labels []float32) error {
+		row = row[:0]
+		for _, v := range features {
+			row = append(row, formatFloat(v))
+		}
+		for _, v := range labels {
+			row = append(row, formatFloat(v))
+		}
+		_, err := fmt.Fprintln(bw, strings.Join(row, sep))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func exportJSONL(r *sample.MmapReader, w io.Writer) error {
+	fk, lk := featureKey, labelKey
+	if len(fk) == 0 {
+		fk = "features"
+	}
+	if len(lk) == 0 {
+		lk = "labels"
+	}
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	err := each(r, func(features, labels []float32) error {
+		return enc.Encode(map[string][]float32{fk: features, lk: labels})
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func exportNpz(r *sample.MmapReader, w io.Writer) error {
+	fk, lk := featureKey, labelKey
+	if len(fk) == 0 {
+		fk = "x"
+	}
+	if len(lk) == 0 {
+		lk = "y"
+	}
+	n := int(r.BatchSize())
+	features := make([]float32, n*int(r.FeatureSize()))
+	labels := make([]float32, n*int(r.LabelSize()))
+	idx := make([]uint32, n)
+	for i := range idx {
+		idx[i] = uint32(i)
+	}
+	if err := r.ReadBatch(idx, features, labels); err != nil {
+		return err
+	}
+	zw := zip.NewWriter(w)
+	for _, arr := range []struct {
+		name  string
+		shape []int64
+		data  []float32
+	}{
+		{fk, []int64{int64(n), int64(r.FeatureSize())}, features},
+		{lk, []int64{int64(n), int64(r.LabelSize())}, labels},
+	} {
+		f, err := zw.Create(arr.name + ".npy")
+		if err != nil {
+			return err
+		}
+		data := make([]byte, len(arr.data)*4)
+		for i, v := range arr.data {
+			binary.LittleEndian.PutUint32(data[i*4:], math.Float32bits(v))
+		}
+		if err = npy.Write(f, "<f4", arr.shape, data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/lwch/tnn/internal/npy"
+	"github.com/lwch/tnn/nn/sample"
+	"github.com/spf13/cobra"
+)
+
+func runImport(_ *cobra.Command, args []string) {
+	var fn func(dir string, w *sample.Writer) error
+	switch f := detectFormat(args[0]); f {
+	case "csv":
+		fn = func(dir string, w *sample.Writer) error {
+			return importCSV(dir, ',', w)
+		}
+	case "tsv":
+		fn = func(dir string, w *sample.Writer) error {
+			return importCSV(dir, '\t', w)
+		}
+	case "jsonl", "ndjson":
+		fn = importJSONL
+	case "npy":
+		fn = importNpy
+	case "npz":
+		fn = importNpz
+	default:
+		fmt.Printf("unsupported format: %s\n", f)
+		os.Exit(1)
+	}
+	create(args[1], func(w *sample.Writer) error {
+		return fn(args[0], w)
+	})
+}
+
+// parseColumns parse columns like 0-3,5 or names of columns like a-c,e,
+// empty spec returns nil
+func parseColumns(spec string, names []string) ([]int, error) {
+	var ret []int
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		if i := columnIndex(item, names); i >= 0 {
+			ret = append(ret, i)
+			continue
+		}
+		from, to, isRange := strings.Cut(item, "-")
+		start := columnIndex(from, names)
+		end := start
+		if isRange {
+			end = columnIndex(to, names)
+		}
+		if start < 0 || end < start {
+			return nil, fmt.Errorf("invalid column: %s", item)
+		}
+		for i := start; i <= end; i++ {
+			ret = append(ret, i)
+		}
+	}
+	return ret, nil
+}
+
+// columnIndex get index of column by name or number, returns -1 when invalid
+func columnIndex(str string, names []string) int {
+	for i, name := range names {
+		if name == str {
+			return i
+		}
+	}
+	n, err := strconv.Atoi(str)
+	if err != nil || n < 0 {
+		return -1
+	}
+	return n
+}
+
+// selectColumns get columns of features and labels, labels are the last
+// column and features are the rest columns by default
+func selectColumns(names []string, total int) ([]int, []int, error) {
+	features, err := parseColumns(featureCols, names)
+	if err != nil {
+		return nil, nil, err
+	}
+	labels, err := parseColumns(labelCols, names)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(labels) == 0 {
+		labels = []int{total - 1}
+	}
+	if len(features) == 0 {
+		used := make(map[int]bool)
+		for _, i := range labels {
+			used[i] = true
+		}
+		for i := 0; i < total; i++ {
+			if !used[i] {
+				features = append(features, i)
+			}
+		}
+	}
+	for _, i := range append(append([]int{}, features...), labels...) {
+		if i < 0 || i >= total {
+			return nil, nil, fmt.Errorf("column %d out of range, %d columns", i, total)
+		}
+	}
+	return features, labels, nil
+}
+
+func pick(row []float32, cols []int) []float32 {
+	ret := make([]float32, len(cols))
+	for i, c := range cols {
+		ret[i] = row[c]
+	}
+	return ret
+}
+
+// usedColumns get distinct columns of features and labels
+func usedColumns(features, labels []int) []int {
+	var ret []int
+	used := make(map[int]bool)
+	for _, i := range append(append([]int{}, features...), labels...) {
+		if !used[i] {
+			used[i] = true
+			ret = append(ret, i)
+		}
+	}
+	return ret
+}
+
+func importCSV(dir string, sep rune, w *sample.Writer) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := csv.NewReader(bufio.NewReader(f))
+	r.Comma = sep
+	r.ReuseRecord = true
+	var names []string
+	if csvHeader {
+		row, err := r.Read()
+		if err != nil {
+			return err
+		}
+		names = append(names, row...)
+	}
+	var features, labels, used []int
+	var values []float32
+	for line := 1; ; line++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if features == nil {
+			if features, labels, err = selectColumns(names, len(row)); err != nil {
+				return err
+			}
+			used = usedColumns(features, labels)
+			values = make([]float32, len(row))
+		}
+		// only selected columns are parsed, the others can be any text
+		for _, i := range used {
+			n, err := strconv.ParseFloat(strings.TrimSpace(row[i]), 32)
+			if err != nil {
+				return fmt.Errorf("row %d column %d: %v", line, i, err)
+			}
+			values[i] = float32(n)
+		}
+		if err = w.WriteSample(pick(values, features), pick(values, labels)); err != nil {
+			return err
+		}
+	}
+}
+
+// flatten flatten number or nested arrays of numbers
+func flatten(v interface{}, ret []float32) ([]float32, error) {
+	switch v := v.(type) {
+	case float64:
+		return append(ret, float32(v)), nil
+	case bool:
+		if v {
+			return append(ret, 1), nil
+		}
+		return append(ret, 0), nil
+	case []interface{}:
+		var err error
+		for _, item := range v {
+			if ret, err = flatten(item, ret); err != nil {
+				return nil, err
+			}
+		}
+		return ret, nil
+	}
+	return nil, fmt.Errorf("unexpected value: %v", v)
+}
+
+func importJSONL(dir string, w *sample.Writer) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fk, lk := featureKey, labelKey
+	if len(fk) == 0 {
+		fk = "features"
+	}
+	if len(lk) == 0 {
+		lk = "labels"
+	}
+	dec := json.NewDecoder(bufio.NewReader(f))
+	fs, ls := -1, -1
+	for line := 1; ; line++ {
+		var row map[string]interface{}
+		if err = dec.Decode(&row); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+		features, err := flatten(row[fk], nil)
+		if err != nil {
+			return fmt.Errorf("line %d: %s: %v", line, fk, err)
+		}
+		labels, err := flatten(row[lk], nil)
+		if err != nil {
+			return fmt.Errorf("line %d: %s: %v", line, lk, err)
+		}
+		if fs < 0 {
+			fs, ls = len(features), len(labels)
+		} else if len(features) != fs || len(labels) != ls {
+			return fmt.Errorf("line %d: expect %d features and %d labels, got %d and %d",
+				line, fs, ls, len(features), len(labels))
+		}
+		if err = w.WriteSample(features, labels); err != nil {
+			return err
+		}
+	}
+}
+
+func importNpy(dir string, w *sample.Writer) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	shape, data, err := npy.Read(bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+	var rows, cols int
+	switch len(shape) {
+	case 1:
+		rows, cols = int(shape[0]), 1
+	case 2:
+		rows, cols = int(shape[0]), int(shape[1])
+	default:
+		return fmt.Errorf("expect 1 or 2 dimensions array, got shape %v", shape)
+	}
+	features, labels, err := selectColumns(nil, cols)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < rows; i++ {
+		row := data[i*cols : (i+1)*cols]
+		if err = w.WriteSample(pick(row, features), pick(row, labels)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func importNpz(dir string, w *sample.Writer) error {
+	shapes, arrays, err := npy.ReadNpz(dir)
+	if err != nil {
+		return err
+	}
+	fk, lk := featureKey, labelKey
+	if len(fk) == 0 {
+		fk = "x"
+	}
+	if len(lk) == 0 {
+		lk = "y"
+	}
+	for _, key := range []string{fk, lk} {
+		if _, ok := arrays[key]; !ok {
+			return fmt.Errorf("array %s not found", key)
+		}
+		if len(shapes[key]) == 0 {
+			return fmt.Errorf("array %s is a scalar", key)
+		}
+	}
+	rows := int(shapes[fk][0])
+	if int(shapes[lk][0]) != rows {
+		return fmt.Errorf("rows of %s and %s mismatch: %d vs %d", fk, lk, rows, shapes[lk][0])
+	}
+	features, labels := arrays[fk], arrays[lk]
+	if rows == 0 {
+		return nil
+	}
+	for _, arr := range []struct {
+		name string
+		data []float32
+	}{{fk, features}, {lk, labels}} {
+		if len(arr.data)%rows != 0 {
+			return fmt.Errorf("%d values of %s can not be split into %d rows", len(arr.data), arr.name, rows)
+		}
+	}
+	fs, ls := len(features)/rows, len(labels)/rows
+	for i := 0; i < rows; i++ {
+		if err = w.WriteSample(features[i*fs:(i+1)*fs], labels[i*ls:(i+1)*ls]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
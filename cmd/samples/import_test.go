@@ -0,0 +1,167 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lwch/tnn/internal/npy"
+	"github.com/lwch/tnn/nn/sample"
+)
+
+// importFile import samples by fn and read them back
+func importFile(t *testing.T, fn func(w *sample.Writer) error) ([][]float32, [][]float32, error) {
+	dir := filepath.Join(t.TempDir(), "samples")
+	f, err := os.Create(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := sample.NewWriter(f)
+	if err = fn(w); err != nil {
+		return nil, nil, err
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := sample.OpenMmap(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var features, labels [][]float32
+	for i := uint32(0); i < r.BatchSize(); i++ {
+		fs := make([]float32, r.FeatureSize())
+		ls := make([]float32, r.LabelSize())
+		if err = r.ReadSample(i, fs, ls); err != nil {
+			t.Fatal(err)
+		}
+		features = append(features, fs)
+		labels = append(labels, ls)
+	}
+	return features, labels, nil
+}
+
+func checkSamples(t *testing.T, got, expect [][]float32) {
+	if len(got) != len(expect) {
+		t.Fatalf("expect %d samples, got %d", len(expect), len(got))
+	}
+	for i := range expect {
+		if len(got[i]) != len(expect[i]) {
+			t.Fatalf("sample %d: expect %v, got %v", i, expect[i], got[i])
+		}
+		for j := range expect[i] {
+			if got[i][j] != expect[i][j] {
+				t.Fatalf("sample %d: expect %v, got %v", i, expect[i], got[i])
+			}
+		}
+	}
+}
+
+func setColumns(t *testing.T, features, labels string, header bool) {
+	featureCols, labelCols, csvHeader = features, labels, header
+	t.Cleanup(func() {
+		featureCols, labelCols, csvHeader = "", "", false
+	})
+}
+
+func TestImportCSV(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data.csv")
+	data := "id,a,b,label\nx1,1,2,0\nx2,3,4,1\n"
+	if err := os.WriteFile(dir, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	setColumns(t, "a-b", "label", true)
+	features, labels, err := importFile(t, func(w *sample.Writer) error {
+		return importCSV(dir, ',', w)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSamples(t, features, [][]float32{{1, 2}, {3, 4}})
+	checkSamples(t, labels, [][]float32{{0}, {1}})
+
+	// id column is not a number
+	setColumns(t, "", "label", true)
+	_, _, err = importFile(t, func(w *sample.Writer) error {
+		return importCSV(dir, ',', w)
+	})
+	if err == nil {
+		t.Fatal("expect error of id column")
+	}
+}
+
+// writeNpz write float32 arrays into npz file
+func writeNpz(t *testing.T, dir string, arrays map[string][]int64, values map[string][]float32) {
+	f, err := os.Create(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for name, shape := range arrays {
+		w, err := zw.Create(name + ".npy")
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := make([]byte, len(values[name])*4)
+		for i, v := range values[name] {
+			binary.LittleEndian.PutUint32(data[i*4:], math.Float32bits(v))
+		}
+		if err = npy.Write(w, "<f4", shape, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportNpz(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data.npz")
+	writeNpz(t, dir, map[string][]int64{
+		"x": {3, 2},
+		"y": {3},
+	}, map[string][]float32{
+		"x": {1, 2, 3, 4, 5, 6},
+		"y": {0, 1, 0},
+	})
+	features, labels, err := importFile(t, func(w *sample.Writer) error {
+		return importNpz(dir, w)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSamples(t, features, [][]float32{{1, 2}, {3, 4}, {5, 6}})
+	checkSamples(t, labels, [][]float32{{0}, {1}, {0}})
+
+	writeNpz(t, dir, map[string][]int64{
+		"x": {3, 2},
+		"y": {2},
+	}, map[string][]float32{
+		"x": {1, 2, 3, 4, 5, 6},
+		"y": {0, 1},
+	})
+	_, _, err = importFile(t, func(w *sample.Writer) error {
+		return importNpz(dir, w)
+	})
+	if err == nil {
+		t.Fatal("expect error of rows")
+	}
+
+	writeNpz(t, dir, map[string][]int64{
+		"x": {-3, 2},
+		"y": {3},
+	}, map[string][]float32{
+		"y": {0, 1, 0},
+	})
+	_, _, err = importFile(t, func(w *sample.Writer) error {
+		return importNpz(dir, w)
+	})
+	if err == nil {
+		t.Fatal("expect error of shape")
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lwch/runtime"
+	"github.com/lwch/tnn/nn/sample"
+	"github.com/spf13/cobra"
+)
+
+var rootCmd = cobra.Command{
+	Use: "samples",
+}
+
+var importCmd = cobra.Command{
+	Use:   "import [input] [output]",
+	Short: "convert csv, tsv, jsonl, npy or npz file to sample file",
+	Args:  cobra.ExactArgs(2),
+	Run:   runImport,
+}
+
+var exportCmd = cobra.Command{
+	Use:   "export [input] [output]",
+	Short: "convert sample file to csv, tsv, jsonl or npz file",
+	Args:  cobra.ExactArgs(2),
+	Run:   runExport,
+}
+
+var statsCmd = cobra.Command{
+	Use:   "stats [input]",
+	Short: "show statistics of features and histogram of labels",
+	Args:  cobra.ExactArgs(1),
+	Run:   runStats,
+}
+
+var splitCmd = cobra.Command{
+	Use:   "split [input] [output prefix]",
+	Short: "shuffle samples and split them into train, val and test files",
+	Args:  cobra.ExactArgs(2),
+	Run:   runSplit,
+}
+
+var format string
+var featureCols string
+var labelCols string
+var csvHeader bool
+var featureKey string
+var labelKey string
+var histBins int
+var splitRatio string
+var splitSeed int64
+
+func main() {
+	for _, cmd := range []*cobra.Command{&importCmd, &exportCmd} {
+		cmd.Flags().StringVar(&format, "format", "", "format of csv, tsv, jsonl, npy or npz, default is detected by extension")
+		cmd.Flags().BoolVar(&csvHeader, "header", false, "the first row of csv or tsv is column names")
+		cmd.Flags().StringVar(&featureKey, "features-key", "", "key of features in jsonl or name of array in npz, default is features for jsonl and x for npz")
+		cmd.Flags().StringVar(&labelKey, "labels-key", "", "key of labels in jsonl or name of array in npz, default is labels for jsonl and y for npz")
+	}
+	importCmd.Flags().StringVar(&featureCols, "features", "", "columns of features in csv, tsv or npy, e.g. 0-3,5 or names with --header, default is all columns except labels")
+	importCmd.Flags().StringVar(&labelCols, "labels", "", "columns of labels in csv, tsv or npy, default is the last column")
+	statsCmd.Flags().IntVar(&histBins, "bins", 10, "bins of label histogram when labels are not classes")
+	splitCmd.Flags().StringVar(&splitRatio, "ratio", "0.8,0.1,0.1", "comma separated ratio of train, val and test")
+	splitCmd.Flags().Int64Var(&splitSeed, "seed", 0, "seed of shuffling")
+
+	rootCmd.AddCommand(&importCmd)
+	rootCmd.AddCommand(&exportCmd)
+	rootCmd.AddCommand(&statsCmd)
+	rootCmd.AddCommand(&splitCmd)
+
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
+	runtime.Assert(rootCmd.Execute())
+}
+
+func open(dir string) *sample.MmapReader {
+	r, err := sample.OpenMmap(dir)
+	runtime.Assert(err)
+	return r
+}
+
+// detectFormat get format from --format or extension of file
+func detectFormat(dir string) string {
+	if len(format) > 0 {
+		return strings.ToLower(format)
+	}
+	return strings.TrimPrefix(strings.ToLower(filepath.Ext(dir)), ".")
+}
+
+// create create sample file and write samples, the file is removed on error
+func create(dir string, fn func(w *sample.Writer) error) {
+	f, err := os.Create(dir)
+	runtime.Assert(err)
+	defer f.Close()
+	w := sample.NewWriter(f)
+	err = fn(w)
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(dir)
+	}
+	runtime.Assert(err)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/lwch/runtime"
+	"github.com/lwch/tnn/nn/sample"
+	"github.com/spf13/cobra"
+)
+
+// parseRatio parse ratio of train, val and test, ratio is normalized
+func parseRatio(str string) ([]float64, error) {
+	items := strings.Split(str, ",")
+	if len(items) != 3 {
+		return nil, fmt.Errorf("expect ratio of train, val and test, got %q", str)
+	}
+	var sum float64
+	ret := make([]float64, len(items))
+	for i, item := range items {
+		v, err := strconv.ParseFloat(strings.TrimSpace(item), 64)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("invalid ratio: %q", item)
+		}
+		ret[i] = v
+		sum += v
+	}
+	if sum <= 0 {
+		return nil, fmt.Errorf("invalid ratio: %q", str)
+	}
+	for i := range ret {
+		ret[i] /= sum
+	}
+	return ret, nil
+}
+
+// splitIndex get end of train and val samples of n samples split by ratio,
+// all the rest samples are val when ratio of test is 0
+func splitIndex(n int, ratio []float64) (int, int) {
+	train := int(float64(n) * ratio[0])
+	val := int(float64(n) * (ratio[0] + ratio[1]))
+	if ratio[2] == 0 {
+		val = n
+	}
+	return train, val
+}
+
+func runSplit(_ *cobra.Command, args []string) {
+	ratio, err := parseRatio(splitRatio)
+	runtime.Assert(err)
+	r := open(args[0])
+	defer r.Close()
+	n := int(r.BatchSize())
+	idx := rand.New(rand.NewSource(splitSeed)).Perm(n)
+	train, val := splitIndex(n, ratio)
+	features := make([]float32, r.FeatureSize())
+	labels := make([]float32, r.LabelSize())
+	for i, part := range []struct {
+		name string
+		idx  []int
+	}{
+		{"train", idx[:train]},
+		{"val", idx[train:val]},
+		{"test", idx[val:]},
+	} {
+		if ratio[i] == 0 {
+			continue
+		}
+		create(args[1]+"."+part.name, func(w *sample.Writer) error {
+			for _, i := range part.idx {
+				if err := r.ReadSample(uint32(i), features, labels); err != nil {
+					return fmt.Errorf("sample %d: %v", i, err)
+				}
+				if err := w.WriteSample(features, labels); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		fmt.Printf("%s: %d samples\n", args[1]+"."+part.name, len(part.idx))
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestSplitRatio(t *testing.T) {
+	tests := []struct {
+		ratio      string
+		n          int
+		train, val int
+	}{
+		{"0.8,0.1,0.1", 100, 80, 90},
+		{"8,1,1", 10, 8, 9},
+		{"1,1,0", 5, 2, 5},
+		{"0,0,1", 7, 0, 0},
+		{"1,0,0", 3, 3, 3},
+	}
+	for _, tt := range tests {
+		ratio, err := parseRatio(tt.ratio)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.ratio, err)
+		}
+		train, val := splitIndex(tt.n, ratio)
+		if train != tt.train || val != tt.val {
+			t.Fatalf("%s of %d: expect %d,%d, got %d,%d", tt.ratio, tt.n, tt.train, tt.val, train, val)
+		}
+	}
+	for _, str := range []string{"", "1,1", "1,-1,1", "0,0,0", "a,1,1"} {
+		if _, err := parseRatio(str); err == nil {
+			t.Fatalf("expect error of ratio %q", str)
+		}
+	}
+}
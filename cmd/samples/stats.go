@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/lwch/runtime"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+// maxClasses labels which have more distinct values are shown by bins
+const maxClasses = 100
+
+type featureStat struct {
+	count    float64
+	mean, m2 float64
+	min, max float64
+}
+
+// add update mean and variance by Welford's algorithm
+func (s *featureStat) add(v float64) {
+	if s.count == 0 {
+		s.min, s.max = v, v
+	}
+	s.count++
+	delta := v - s.mean
+	s.mean += delta / s.count
+	s.m2 += delta * (v - s.mean)
+	s.min = math.Min(s.min, v)
+	s.max = math.Max(s.max, v)
+}
+
+func (s *featureStat) std() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	return math.Sqrt(s.m2 / s.count)
+}
+
+func runStats(_ *cobra.Command, args []string) {
+	r := open(args[0])
+	defer r.Close()
+	fmt.Printf("samples: %d, features: %d, labels: %d\n", r.BatchSize(), r.FeatureSize(), r.LabelSize())
+	stats := make([]featureStat, r.FeatureSize())
+	var labels []float64
+	err := each(r, func(f, l []float32) error {
+		for i, v := range f {
+			stats[i].add(float64(v))
+		}
+		labels = append(labels, labelValue(l))
+		return nil
+	})
+	runtime.Assert(err)
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"feature", "mean", "std", "min", "max"})
+	for i, s := range stats {
+		table.Append([]string{
+			fmt.Sprintf("%d", i),
+			fmt.Sprintf("%g", s.mean),
+			fmt.Sprintf("%g", s.std()),
+			fmt.Sprintf("%g", s.min),
+			fmt.Sprintf("%g", s.max),
+		})
+	}
+	table.Render()
+
+	if len(labels) == 0 || r.LabelSize() == 0 {
+		return
+	}
+	table = tablewriter.NewWriter(os.Stdout)
+	if r.LabelSize() > 1 {
+		table.SetHeader([]string{"class (argmax)", "count", "percent"})
+	} else {
+		table.SetHeader([]string{"label", "count", "percent"})
+	}
+	for _, b := range histogram(labels, histBins) {
+		table.Append([]string{
+			b.name,
+			fmt.Sprintf("%d", b.count),
+			fmt.Sprintf("%.2f%%", float64(b.count)*100/float64(len(labels))),
+		})
+	}
+	table.Render()
+}
+
+// labelValue get value of single label or index of the max value of one-hot labels
+func labelValue(labels []float32) float64 {
+	if len(labels) == 1 {
+		return float64(labels[0])
+	}
+	best := 0
+	for i, v := range labels {
+		if v > labels[best] {
+			best = i
+		}
+	}
+	return float64(best)
+}
+
+type bin struct {
+	name  string
+	count int
+}
+
+// histogram count each distinct value when values are integers of a few
+// classes, otherwise count values in bins of the same width
+func histogram(values []float64, bins int) []bin {
+	counts := make(map[float64]int)
+	classes := true
+	for _, v := range values {
+		if v != math.Trunc(v) {
+			classes = false
+			break
+		}
+		counts[v]++
+		if len(counts) > maxClasses {
+			classes = false
+			break
+		}
+	}
+	var ret []bin
+	if classes {
+		keys := make([]float64, 0, len(counts))
+		for k := range counts {
+			keys = append(keys, k)
+		}
+		sort.Float64s(keys)
+		for _, k := range keys {
+			ret = append(ret, bin{name: fmt.Sprintf("%g", k), count: counts[k]})
+		}
+		return ret
+	}
+	if bins <= 0 {
+		bins = 10
+	}
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+	width := (hi - lo) / float64(bins)
+	ret = make([]bin, bins)
+	for i := range ret {
+		start := lo + width*float64(i)
+		ret[i].name = fmt.Sprintf("[%g, %g)", start, start+width)
+	}
+	ret[bins-1].name = fmt.Sprintf("[%g, %g]", lo+width*float64(bins-1), hi)
+	for _, v := range values {
+		i := bins - 1
+		if width > 0 {
+			i = int((v - lo) / width)
+		}
+		if i >= bins {
+			i = bins - 1
+		} else if i < 0 {
+			i = 0
+		}
+		ret[i].count++
+	}
+	return ret
+}
@@ -6,7 +6,6 @@ import (
 	"github.com/lwch/gotorch/optimizer"
 	"github.com/lwch/gotorch/tensor"
 	"github.com/lwch/tnn/nn/layer"
-	"github.com/lwch/tnn/nn/layer/activation"
 	"github.com/lwch/tnn/nn/net"
 )
 
@@ -22,21 +21,16 @@ func newModel(net *net.Net, optimizer optimizer.Optimizer) *model {
 	}
 }
 
-func (m *model) Forward(x *tensor.Tensor) *tensor.Tensor {
-	output := x
-	for _, l := range m.net.Layers() {
-		switch ln := l.(type) {
-		case *layer.Linear:
-			output = ln.Forward(output)
-		case *activation.ReLU:
-			output = ln.Forward(output)
-		}
+func (m *model) Forward(x *tensor.Tensor, train bool) *tensor.Tensor {
+	outputs, err := m.net.Forward(layer.NewContext(train), x)
+	if err != nil {
+		panic(err)
 	}
-	return output
+	return outputs[0]
 }
 
 func (m *model) Train(x, y *tensor.Tensor) float32 {
-	pred := m.Forward(x)
+	pred := m.Forward(x, true)
 	l := lossFunc(pred, y)
 	l.Backward()
 	value := l.Value()
@@ -46,11 +40,11 @@ func (m *model) Train(x, y *tensor.Tensor) float32 {
 }
 
 func (m *model) Predict(x *tensor.Tensor) []float32 {
-	return m.Forward(x).Float32Value()
+	return m.Forward(x, false).Float32Value()
 }
 
 func (m *model) Loss(x, y *tensor.Tensor) float32 {
-	pred := m.Forward(x)
+	pred := m.Forward(x, false)
 	loss := lossFunc(y, pred)
 	return float32(loss.Value())
 }
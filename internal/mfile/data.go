@@ -0,0 +1,177 @@
+package mfile
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/lwch/gotorch/consts"
+	"github.com/lwch/tnn/internal/npy"
+	"github.com/lwch/tnn/internal/pb"
+)
+
+// ParamNames get sorted param names of layer
+func ParamNames(l *pb.Layer) []string {
+	names := make([]string, 0, len(l.GetParams()))
+	for name := range l.GetParams() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ElemSize get bytes of each element, returns 0 for unsupported type
+func ElemSize(t consts.ScalarType) int64 {
+	switch t {
+	case consts.KUint8, consts.KInt8, consts.KBool:
+		return 1
+	case consts.KInt16, consts.KHalf, consts.KBFloat16:
+		return 2
+	case consts.KInt32, consts.KFloat:
+		return 4
+	case consts.KInt64, consts.KDouble:
+		return 8
+	default:
+		return 0
+	}
+}
+
+// IsFloat check whether t is floating point type
+func IsFloat(t consts.ScalarType) bool {
+	switch t {
+	case consts.KHalf, consts.KBFloat16, consts.KFloat, consts.KDouble:
+		return true
+	default:
+		return false
+	}
+}
+
+// float32ToHalf convert float32 to IEEE 754 half precision bits, round to nearest even
+func float32ToHalf(f float32) uint16 {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int32(bits>>23) & 0xff
+	frac := bits & 0x7fffff
+	switch {
+	case exp == 0xff:
+		if frac != 0 {
+			return sign | 0x7e00
+		}
+		return sign | 0x7c00
+	case exp-127 > 15:
+		return sign | 0x7c00
+	case exp-127 >= -14:
+		h := uint32(exp-112)<<10 | frac>>13
+		round := frac & 0x1fff
+		if round > 0x1000 || (round == 0x1000 && h&1 == 1) {
+			// carry into exponent gives inf on overflow which is expected
+			h++
+		}
+		return sign | uint16(h)
+	case exp-127 >= -25:
+		// subnormal
+		frac |= 0x800000
+		shift := uint32(-exp + 126 - 14 + 13 + 1)
+		h := frac >> shift
+		round := frac & (1<<shift - 1)
+		half := uint32(1) << (shift - 1)
+		if round > half || (round == half && h&1 == 1) {
+			h++
+		}
+		return sign | uint16(h)
+	default:
+		return sign
+	}
+}
+
+// float32ToBFloat16 convert float32 to bfloat16 bits, round to nearest even
+func float32ToBFloat16(f float32) uint16 {
+	bits := math.Float32bits(f)
+	if f != f {
+		return uint16(bits>>16) | 0x40
+	}
+	bits += 0x7fff + (bits>>16)&1
+	return uint16(bits >> 16)
+}
+
+// Float64s decode big endian data as float64
+func Float64s(t consts.ScalarType, data []byte) []float64 {
+	size := int(ElemSize(t))
+	if size == 0 {
+		return nil
+	}
+	ret := make([]float64, len(data)/size)
+	for i := range ret {
+		ret[i] = Float64At(t, data, i)
+	}
+	return ret
+}
+
+// Float64At decode the i-th element of big endian data as float64
+func Float64At(t consts.ScalarType, data []byte, i int) float64 {
+	switch t {
+	case consts.KUint8:
+		return float64(data[i])
+	case consts.KInt8:
+		return float64(int8(data[i]))
+	case consts.KBool:
+		if data[i] != 0 {
+			return 1
+		}
+		return 0
+	case consts.KInt16:
+		return float64(int16(binary.BigEndian.Uint16(data[i*2:])))
+	case consts.KInt32:
+		return float64(int32(binary.BigEndian.Uint32(data[i*4:])))
+	case consts.KInt64:
+		return float64(int64(binary.BigEndian.Uint64(data[i*8:])))
+	case consts.KHalf:
+		return float64(npy.HalfToFloat32(binary.BigEndian.Uint16(data[i*2:])))
+	case consts.KBFloat16:
+		return float64(math.Float32frombits(uint32(binary.BigEndian.Uint16(data[i*2:])) << 16))
+	case consts.KFloat:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data[i*4:])))
+	case consts.KDouble:
+		return math.Float64frombits(binary.BigEndian.Uint64(data[i*8:]))
+	default:
+		return 0
+	}
+}
+
+// EncodeFloats encode floating point values as big endian data of type t
+func EncodeFloats(t consts.ScalarType, values []float64) []byte {
+	size := int(ElemSize(t))
+	data := make([]byte, len(values)*size)
+	for i, v := range values {
+		switch t {
+		case consts.KHalf:
+			binary.BigEndian.PutUint16(data[i*2:], float32ToHalf(float32(v)))
+		case consts.KBFloat16:
+			binary.BigEndian.PutUint16(data[i*2:], float32ToBFloat16(float32(v)))
+		case consts.KFloat:
+			binary.BigEndian.PutUint32(data[i*4:], math.Float32bits(float32(v)))
+		case consts.KDouble:
+			binary.BigEndian.PutUint64(data[i*8:], math.Float64bits(v))
+		}
+	}
+	return data
+}
+
+// Format format the i-th element of big endian data
+func Format(t consts.ScalarType, data []byte, i int) string {
+	switch t {
+	case consts.KBool:
+		return strconv.FormatBool(data[i] != 0)
+	case consts.KUint8, consts.KInt8, consts.KInt16, consts.KInt32:
+		return strconv.FormatInt(int64(Float64At(t, data, i)), 10)
+	case consts.KInt64:
+		return strconv.FormatInt(int64(binary.BigEndian.Uint64(data[i*8:])), 10)
+	case consts.KDouble:
+		return strconv.FormatFloat(Float64At(t, data, i), 'g', -1, 64)
+	case consts.KHalf, consts.KBFloat16, consts.KFloat:
+		return strconv.FormatFloat(Float64At(t, data, i), 'g', -1, 32)
+	default:
+		return ""
+	}
+}
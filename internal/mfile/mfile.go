@@ -0,0 +1,259 @@
+// Package mfile read and write model files saved by net.Save without libgotorch
+package mfile
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/lwch/gotorch/consts"
+	"github.com/lwch/tnn/internal/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// File opened model file
+type File struct {
+	f    *os.File
+	zr   *zip.Reader
+	Spec *pb.Net
+}
+
+// Open open model file and parse SPEC
+func Open(dir string) (*File, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	zr, err := zip.NewReader(f, fi.Size())
+	if err != nil {
+		err = detect(f, err)
+		f.Close()
+		return nil, err
+	}
+	zr.RegisterDecompressor(zip.Deflate, func(r io.Reader) io.ReadCloser {
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return io.NopCloser(errReader{err})
+		}
+		return zr.IOReadCloser()
+	})
+	mf := &File{f: f, zr: zr}
+	data, err := mf.read("SPEC")
+	if errors.Is(err, fs.ErrNotExist) {
+		f.Close()
+		return nil, fmt.Errorf("SPEC not found, shards of model saved by SaveSharded are not supported, " +
+			"load it by net.Load and save it by net.Save first")
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	var spec pb.Net
+	if err = proto.Unmarshal(data, &spec); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("invalid SPEC: %v", err)
+	}
+	mf.Spec = &spec
+	return mf, nil
+}
+
+// detect explain the error of opening file which is not a zip file
+func detect(f *os.File, err error) error {
+	var head [9]byte
+	n, _ := f.ReadAt(head[:], 0)
+	switch {
+	case n > 0 && head[0] == '{':
+		return fmt.Errorf("index of model saved by SaveSharded is not supported, " +
+			"load it by net.Load and save it by net.Save first")
+	case n == len(head) && head[8] == '{':
+		return fmt.Errorf("safetensors file is not supported, " +
+			"load it by net.LoadSafetensors and save it by net.Save first")
+	}
+	return fmt.Errorf("not a model file saved by net.Save: %v", err)
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// Close close file
+func (mf *File) Close() error {
+	return mf.f.Close()
+}
+
+func (mf *File) read(name string) ([]byte, error) {
+	f, err := mf.zr.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// ReadEntry read data of zip entry by name
+func (mf *File) ReadEntry(name string) ([]byte, error) {
+	return mf.read(name)
+}
+
+// Extras get names of entries which are neither SPEC nor param files,
+// e.g. PREPROCESS and CHECKPOINT
+func (mf *File) Extras() []string {
+	files := make(map[string]bool)
+	for _, l := range mf.Spec.GetLayers() {
+		for _, param := range l.GetParams() {
+			files[param.GetFile()] = true
+		}
+	}
+	var ret []string
+	for _, f := range mf.zr.File {
+		if f.Name != "SPEC" && !files[f.Name] {
+			ret = append(ret, f.Name)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Entry get zip entry by name, returns nil when not found
+func (mf *File) Entry(name string) *zip.File {
+	for _, f := range mf.zr.File {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// Layer get layer spec by name, returns nil when not found
+func (mf *File) Layer(name string) *pb.Layer {
+	for _, l := range mf.Spec.GetLayers() {
+		if l.GetName() == name {
+			return l
+		}
+	}
+	return nil
+}
+
+func checkParam(param *pb.Param) (int64, error) {
+	t := consts.ScalarType(param.GetType())
+	size := ElemSize(t)
+	if size == 0 {
+		return 0, fmt.Errorf("unsupported scalar type: %s", t.String())
+	}
+	cnt := int64(1)
+	for _, s := range param.GetShapes() {
+		if s < 0 || (s > 0 && cnt > math.MaxInt64/size/s) {
+			return 0, fmt.Errorf("invalid shapes %v", param.GetShapes())
+		}
+		cnt *= s
+	}
+	if cnt != param.GetElemCount() {
+		return 0, fmt.Errorf("shapes %v mismatch elem count %d", param.GetShapes(), param.GetElemCount())
+	}
+	return cnt * size, nil
+}
+
+// Verify check size of param data, crc32 is checked by zip reader when reaching EOF
+func (mf *File) Verify(param *pb.Param) error {
+	size, err := checkParam(param)
+	if err != nil {
+		return err
+	}
+	f, err := mf.zr.Open(param.GetFile())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	n, err := io.Copy(io.Discard, f)
+	if err != nil {
+		return err
+	}
+	if n != size {
+		return fmt.Errorf("invalid size of %s: expect %d bytes, got %d", param.GetFile(), size, n)
+	}
+	return nil
+}
+
+// Read read big endian data of param
+func (mf *File) Read(param *pb.Param) ([]byte, error) {
+	size, err := checkParam(param)
+	if err != nil {
+		return nil, err
+	}
+	data, err := mf.read(param.GetFile())
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != size {
+		return nil, fmt.Errorf("invalid size of %s: expect %d bytes, got %d", param.GetFile(), size, len(data))
+	}
+	return data, nil
+}
+
+// Write write model file with SPEC, data returns big endian data of each param,
+// extras are written as other entries, e.g. PREPROCESS
+func Write(w io.Writer, spec *pb.Net, data func(param *pb.Param) ([]byte, error), extras map[string][]byte) error {
+	zw := zip.NewWriter(w)
+	zw.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+		return zstd.NewWriter(w)
+	})
+	buf, err := proto.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	write := func(name string, data []byte) error {
+		f, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, bytes.NewReader(data))
+		return err
+	}
+	if err = write("SPEC", buf); err != nil {
+		return err
+	}
+	for _, l := range spec.GetLayers() {
+		for _, name := range ParamNames(l) {
+			param := l.GetParams()[name]
+			buf, err := data(param)
+			if err != nil {
+				return fmt.Errorf("%s.%s: %v", l.GetName(), name, err)
+			}
+			if err = write(param.GetFile(), buf); err != nil {
+				return err
+			}
+		}
+	}
+	names := make([]string, 0, len(extras))
+	for name := range extras {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err = write(name, extras[name]); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
@@ -0,0 +1,166 @@
+package mfile
+
+import (
+	"archive/zip"
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lwch/gotorch/consts"
+	"github.com/lwch/tnn/internal/npy"
+	"github.com/lwch/tnn/internal/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestHalf(t *testing.T) {
+	for _, v := range []float32{0, 1, -2.5, 65504, 6.1035156e-05, 5.9604645e-08, 0.1} {
+		h := float32ToHalf(v)
+		got := npy.HalfToFloat32(h)
+		if math.Abs(float64(got-v)) > math.Abs(float64(v))*1e-3 {
+			t.Fatalf("half of %g: got %g", v, got)
+		}
+	}
+	if h := float32ToHalf(1e10); h != 0x7c00 {
+		t.Fatalf("overflow: got %x", h)
+	}
+	if v := math.Float32frombits(uint32(float32ToBFloat16(1.5)) << 16); v != 1.5 {
+		t.Fatalf("bfloat16: got %g", v)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	spec := &pb.Net{Layers: []*pb.Layer{{
+		Class: "linear",
+		Name:  "output",
+		Params: map[string]*pb.Param{
+			"w": {Type: uint32(consts.KFloat), ElemCount: 6, Name: "w", Shapes: []int64{2, 3}, File: "layer_0_param_w.bin"},
+			"b": {Type: uint32(consts.KHalf), ElemCount: 3, Name: "b", Shapes: []int64{1, 3}, File: "layer_0_param_b.bin"},
+		},
+	}}}
+	values := []float64{1, 2, 3, 4, 5, 6}
+	var buf bytes.Buffer
+	err := Write(&buf, spec, func(param *pb.Param) ([]byte, error) {
+		t := consts.ScalarType(param.GetType())
+		return EncodeFloats(t, values[:param.GetElemCount()]), nil
+	}, map[string][]byte{"PREPROCESS": []byte("preprocess")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(t.TempDir(), "test.model")
+	if err = os.WriteFile(dir, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mf, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mf.Close()
+	if extras := mf.Extras(); len(extras) != 1 || extras[0] != "PREPROCESS" {
+		t.Fatalf("unexpected extras: %v", extras)
+	}
+	if data, err := mf.ReadEntry("PREPROCESS"); err != nil || string(data) != "preprocess" {
+		t.Fatalf("unexpected PREPROCESS: %q, %v", data, err)
+	}
+	l := mf.Layer("output")
+	if l == nil {
+		t.Fatal("layer not found")
+	}
+	for _, name := range ParamNames(l) {
+		param := l.GetParams()[name]
+		if err = mf.Verify(param); err != nil {
+			t.Fatal(err)
+		}
+		data, err := mf.Read(param)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := Float64s(consts.ScalarType(param.GetType()), data)
+		for i := range got {
+			if got[i] != values[i] {
+				t.Fatalf("%s[%d]: expect %g, got %g", name, i, values[i], got[i])
+			}
+		}
+	}
+}
+
+func TestOpenStore(t *testing.T) {
+	spec := &pb.Net{Layers: []*pb.Layer{{
+		Class: "linear",
+		Name:  "output",
+		Params: map[string]*pb.Param{
+			"w": {Type: uint32(consts.KFloat), ElemCount: 2, Name: "w", Shapes: []int64{1, 2}, File: "layer_0_param_w.bin"},
+		},
+	}}}
+	specData, err := proto.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// params saved by WithStore for lazy loading are not compressed
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range map[string][]byte{
+		"SPEC":                specData,
+		"layer_0_param_w.bin": EncodeFloats(consts.KFloat, []float64{1, 2}),
+	} {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = f.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(t.TempDir(), "store.model")
+	if err = os.WriteFile(dir, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mf, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mf.Close()
+	data, err := mf.Read(mf.Layer("output").GetParams()["w"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Float64s(consts.KFloat, data); got[0] != 1 || got[1] != 2 {
+		t.Fatalf("unexpected values: %v", got)
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	var shard bytes.Buffer
+	zw := zip.NewWriter(&shard)
+	if _, err := zw.Create("layer_0_param_w.bin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		data   []byte
+		expect string
+	}{
+		{"model.index.json", []byte(`{"metadata": {}}`), "SaveSharded"},
+		{"model-00001-of-00002.tnn", shard.Bytes(), "SaveSharded"},
+		{"model.safetensors", append([]byte{2, 0, 0, 0, 0, 0, 0, 0}, "{}"...), "safetensors"},
+		{"model.txt", []byte("hello world"), "not a model file"},
+	}
+	for _, tt := range tests {
+		dir := filepath.Join(t.TempDir(), tt.name)
+		if err := os.WriteFile(dir, tt.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := Open(dir)
+		if err == nil || !strings.Contains(err.Error(), tt.expect) {
+			t.Fatalf("%s: expect error contains %q, got %v", tt.name, tt.expect, err)
+		}
+	}
+}
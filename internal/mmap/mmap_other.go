@@ -0,0 +1,14 @@
+//go:build !unix
+
+package mmap
+
+import "os"
+
+// Open read whole file into memory on platforms without mmap support
+func Open(dir string) ([]byte, func() error, error) {
+	data, err := os.ReadFile(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
@@ -0,0 +1,32 @@
+//go:build unix
+
+// Package mmap map files into memory read only
+package mmap
+
+import (
+	"os"
+	"syscall"
+)
+
+// Open map file into memory read only, the returned function unmaps it
+func Open(dir string) ([]byte, func() error, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if fi.Size() == 0 {
+		return nil, func() error { return nil }, nil
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(fi.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error {
+		return syscall.Munmap(data)
+	}, nil
+}
@@ -0,0 +1,119 @@
+// Package nnutil helpers of tensors shared by nn packages
+package nnutil
+
+import (
+	"github.com/lwch/gotorch/consts"
+	"github.com/lwch/gotorch/optimizer"
+	"github.com/lwch/gotorch/tensor"
+)
+
+// Detach copy leaf t out of the graph, requiresGrad is the flag of t
+// restored after copying, gotorch can not read the flag
+func Detach(t *tensor.Tensor, requiresGrad bool) *tensor.Tensor {
+	t.SetRequiresGrad(false)
+	defer t.SetRequiresGrad(requiresGrad)
+	return t.Mul(Scalar(1, t))
+}
+
+// Copy copy floating point t through host memory as a new leaf which does
+// not require grad, the flag of t is not changed
+func Copy(t *tensor.Tensor) *tensor.Tensor {
+	opts := []tensor.Option{
+		tensor.WithShapes(t.Shapes()...),
+		tensor.WithDevice(t.DeviceType()),
+	}
+	cpu := t.ToDevice(consts.KCPU)
+	if t.ScalarType() == consts.KDouble {
+		return tensor.FromFloat64(cpu.Float64Value(), opts...)
+	}
+	// half and bfloat16 are exactly represented by float32
+	return tensor.FromFloat32(cpu.ToScalarType(consts.KFloat).Float32Value(), opts...).
+		ToScalarType(t.ScalarType())
+}
+
+// Scalar create one element tensor of n in type and device of t
+func Scalar(n float64, t *tensor.Tensor) *tensor.Tensor {
+	return tensor.FromFloat32([]float32{float32(n)},
+		tensor.WithShapes(1),
+		tensor.WithDevice(t.DeviceType())).ToScalarType(t.ScalarType())
+}
+
+// IsFloat check whether t is floating point type
+func IsFloat(t consts.ScalarType) bool {
+	switch t {
+	case consts.KHalf, consts.KBFloat16, consts.KFloat, consts.KDouble:
+		return true
+	}
+	return false
+}
+
+// SameShapes check whether shapes a and b are equal
+func SameShapes(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Min get the smaller one of a and b
+func Min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// readoutLr learning rate, weight decay and eps of readout optimizer, with
+// zero betas adamw moves probe to -lr*g/(|g|+eps), which is -g exactly
+const readoutLr = float64(1 << 50)
+
+// NewProbe create float32 zero leaf in shapes and device of t, add it to a
+// copy of t to receive the gradient of t, see ReadProbes
+func NewProbe(t *tensor.Tensor) *tensor.Tensor {
+	p := tensor.Zeros(consts.KFloat,
+		tensor.WithShapes(t.Shapes()...),
+		tensor.WithDevice(t.DeviceType()))
+	p.SetRequiresGrad(true)
+	return p
+}
+
+// ReadProbes read gradients accumulated by probes, gotorch does not expose
+// gradients, so the gradients are read by stepping an adamw, gradients which
+// are not finite become nan, the gradients of probes are cleared.
+// This is a workaround of the limitation of gotorch, values are exact only
+// when |g| is far below readoutLr, use it only for inspecting gradients
+func ReadProbes(probes []*tensor.Tensor) []*tensor.Tensor {
+	if len(probes) == 0 {
+		return nil
+	}
+	// probes not used by forward must have zero gradient, adamw skips
+	// params without gradient
+	var sum *tensor.Tensor
+	for _, p := range probes {
+		s := p.Mul(Scalar(0, p)).Reshape(-1).Sum(0, false)
+		if sum == nil {
+			sum = s
+		} else {
+			sum = sum.Add(s.ToDevice(sum.DeviceType()))
+		}
+	}
+	sum.Backward()
+	// state of adamw is not used with zero betas,
+	// a new one is created each time to drop it
+	optimizer.NewAdamW(
+		optimizer.WithAdamWLr(readoutLr),
+		optimizer.WithAdamWWeightDecay(1/readoutLr),
+		optimizer.WithAdamWEps(readoutLr),
+		optimizer.WithAdamWBeta1(0),
+		optimizer.WithAdamWBeta2(0)).Step(probes)
+	ret := make([]*tensor.Tensor, len(probes))
+	for i, p := range probes {
+		ret[i] = Detach(p, true).Neg()
+	}
+	return ret
+}
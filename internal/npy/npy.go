@@ -0,0 +1,202 @@
+// Package npy read and write arrays in numpy npy and npz format
+package npy
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	npyDescr   = regexp.MustCompile(`'descr':\s*'([<>|=])([a-z])(\d+)'`)
+	npyFortran = regexp.MustCompile(`'fortran_order':\s*(True|False)`)
+	npyShape   = regexp.MustCompile(`'shape':\s*\(([^)]*)\)`)
+)
+
+// Read read npy array of version 1.0, 2.0 or 3.0 and convert values to float32
+func Read(r io.Reader) ([]int64, []float32, error) {
+	var prefix [8]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, nil, err
+	}
+	if string(prefix[:6]) != "\x93NUMPY" {
+		return nil, nil, fmt.Errorf("invalid npy magic")
+	}
+	var size int
+	switch prefix[6] {
+	case 1:
+		var n uint16
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, nil, err
+		}
+		size = int(n)
+	case 2, 3:
+		var n uint32
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, nil, err
+		}
+		size = int(n)
+	default:
+		return nil, nil, fmt.Errorf("unsupported npy version: %d", prefix[6])
+	}
+	header := make([]byte, size)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, nil, err
+	}
+	descr := npyDescr.FindStringSubmatch(string(header))
+	if descr == nil {
+		return nil, nil, fmt.Errorf("unsupported npy dtype: %s", header)
+	}
+	if m := npyFortran.FindStringSubmatch(string(header)); m == nil || m[1] == "True" {
+		return nil, nil, fmt.Errorf("fortran order is not supported")
+	}
+	m := npyShape.FindStringSubmatch(string(header))
+	if m == nil {
+		return nil, nil, fmt.Errorf("missing shape of npy")
+	}
+	var shape []int64
+	count := int64(1)
+	for _, s := range strings.Split(m[1], ",") {
+		s = strings.TrimSpace(s)
+		if len(s) == 0 {
+			continue
+		}
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n < 0 || (n > 0 && count > math.MaxInt64/8/n) {
+			return nil, nil, fmt.Errorf("invalid shape of npy: %s", m[1])
+		}
+		shape = append(shape, n)
+		count *= n
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if descr[1] == ">" {
+		order = binary.BigEndian
+	}
+	width, _ := strconv.Atoi(descr[3])
+	decode, err := decoder(descr[2], width, order)
+	if err != nil {
+		return nil, nil, err
+	}
+	raw := make([]byte, count*int64(width))
+	if _, err = io.ReadFull(r, raw); err != nil {
+		return nil, nil, err
+	}
+	data := make([]float32, count)
+	for i := range data {
+		data[i] = decode(raw[i*width:])
+	}
+	return shape, data, nil
+}
+
+func decoder(kind string, size int, order binary.ByteOrder) (func([]byte) float32, error) {
+	switch {
+	case kind == "f" && size == 4:
+		return func(b []byte) float32 { return math.Float32frombits(order.Uint32(b)) }, nil
+	case kind == "f" && size == 8:
+		return func(b []byte) float32 { return float32(math.Float64frombits(order.Uint64(b))) }, nil
+	case kind == "f" && size == 2:
+		return func(b []byte) float32 { return HalfToFloat32(order.Uint16(b)) }, nil
+	case (kind == "u" || kind == "b") && size == 1:
+		return func(b []byte) float32 { return float32(b[0]) }, nil
+	case kind == "i" && size == 1:
+		return func(b []byte) float32 { return float32(int8(b[0])) }, nil
+	case kind == "i" && size == 2:
+		return func(b []byte) float32 { return float32(int16(order.Uint16(b))) }, nil
+	case kind == "u" && size == 2:
+		return func(b []byte) float32 { return float32(order.Uint16(b)) }, nil
+	case kind == "i" && size == 4:
+		return func(b []byte) float32 { return float32(int32(order.Uint32(b))) }, nil
+	case kind == "u" && size == 4:
+		return func(b []byte) float32 { return float32(order.Uint32(b)) }, nil
+	case kind == "i" && size == 8:
+		return func(b []byte) float32 { return float32(int64(order.Uint64(b))) }, nil
+	case kind == "u" && size == 8:
+		return func(b []byte) float32 { return float32(order.Uint64(b)) }, nil
+	}
+	return nil, fmt.Errorf("unsupported npy dtype: %s%d", kind, size)
+}
+
+// HalfToFloat32 convert IEEE 754 half precision bits to float32
+func HalfToFloat32(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	frac := uint32(h) & 0x3ff
+	switch {
+	case exp == 0 && frac == 0:
+		return math.Float32frombits(sign)
+	case exp == 0:
+		// subnormal
+		v := float32(frac) / (1 << 24)
+		if sign != 0 {
+			v = -v
+		}
+		return v
+	case exp == 0x1f:
+		return math.Float32frombits(sign | 0xff<<23 | frac<<13)
+	default:
+		return math.Float32frombits(sign | (exp+112)<<23 | frac<<13)
+	}
+}
+
+// ReadNpz read arrays of npz file, names of arrays are keys without .npy suffix
+func ReadNpz(dir string) (map[string][]int64, map[string][]float32, error) {
+	zr, err := zip.OpenReader(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer zr.Close()
+	shapes := make(map[string][]int64)
+	arrays := make(map[string][]float32)
+	for _, f := range zr.File {
+		if !strings.HasSuffix(f.Name, ".npy") {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, nil, err
+		}
+		name := strings.TrimSuffix(f.Name, ".npy")
+		shapes[name], arrays[name], err = Read(r)
+		r.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", f.Name, err)
+		}
+	}
+	return shapes, arrays, nil
+}
+
+// Write write array in npy format version 1.0, descr is the numpy dtype of
+// data, e.g. <f4 or >i8, data is written as is
+func Write(w io.Writer, descr string, shape []int64, data []byte) error {
+	dims := make([]string, len(shape))
+	for i, s := range shape {
+		dims[i] = strconv.FormatInt(s, 10)
+	}
+	str := strings.Join(dims, ", ")
+	if len(shape) == 1 {
+		str += ","
+	}
+	header := fmt.Sprintf("{'descr': '%s', 'fortran_order': False, 'shape': (%s), }", descr, str)
+	// magic(6) + version(2) + header length(2) + header + '\n' must be aligned to 64 bytes
+	pad := 64 - (10+len(header)+1)%64
+	if pad == 64 {
+		pad = 0
+	}
+	header += strings.Repeat(" ", pad) + "\n"
+	var prefix [10]byte
+	copy(prefix[:], "\x93NUMPY\x01\x00")
+	binary.LittleEndian.PutUint16(prefix[8:], uint16(len(header)))
+	if _, err := w.Write(prefix[:]); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
@@ -0,0 +1,195 @@
+package amp
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/lwch/gotorch/consts"
+	"github.com/lwch/gotorch/optimizer"
+	"github.com/lwch/gotorch/tensor"
+	"github.com/lwch/tnn/internal/nnutil"
+	"github.com/lwch/tnn/nn/net"
+)
+
+type key struct {
+	layer int
+	name  string
+}
+
+// Optimizer mixed precision training of net, the optimizer updates float32
+// master params, layers of the net compute with half or bfloat16 copies.
+//
+// Gradients reach the master params through the graph of the copies and are
+// unscaled in float32 there. gotorch exposes neither gradients nor in-place
+// copies of tensors, so:
+//   - overflow is detected from non-finite master params after the optimizer
+//     step, the masters are then restored from the copies taken before the
+//     step and the optimizer is recreated, which drops its state (e.g.
+//     moments of adam)
+//   - Cast creates new compute copies after each step and updates layers in
+//     place by Net.MapParams, frozen layers keep frozen and their params are
+//     not updated
+type Optimizer struct {
+	net          *net.Net
+	t            consts.ScalarType
+	scaler       *Scaler
+	newOptimizer func() optimizer.Optimizer
+	optm         optimizer.Optimizer
+	keys         []key
+	masters      []*tensor.Tensor
+	trainable    []bool
+}
+
+// Option option of mixed precision training
+type Option func(*Optimizer)
+
+// WithScaler set loss scaler, default is NewScaler(), loss scaling is
+// usually not needed for bfloat16, use WithInitScale(1) and WithGrowthInterval(0)
+func WithScaler(s *Scaler) Option {
+	return func(o *Optimizer) {
+		o.scaler = s
+	}
+}
+
+// New create mixed precision optimizer of net, t is consts.KHalf or
+// consts.KBFloat16, newOptimizer creates the optimizer of master params
+func New(n *net.Net, t consts.ScalarType, newOptimizer func() optimizer.Optimizer, opts ...Option) (*Optimizer, error) {
+	if t != consts.KHalf && t != consts.KBFloat16 {
+		return nil, fmt.Errorf("unsupported compute type: %s", t.String())
+	}
+	o := &Optimizer{
+		net:          n,
+		t:            t,
+		scaler:       NewScaler(),
+		newOptimizer: newOptimizer,
+		optm:         newOptimizer(),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	for i, l := range n.Layers() {
+		frozen := false
+		if f, ok := l.(interface{ Frozen() bool }); ok {
+			frozen = f.Frozen()
+		}
+		for name, p := range l.Params() {
+			if !nnutil.IsFloat(p.ScalarType()) {
+				continue
+			}
+			// requires grad of p is not changed by copying
+			m := nnutil.Copy(p).ToScalarType(consts.KFloat)
+			m.SetRequiresGrad(!frozen)
+			o.keys = append(o.keys, key{i, name})
+			o.masters = append(o.masters, m)
+			o.trainable = append(o.trainable, !frozen)
+		}
+	}
+	return o, o.Cast()
+}
+
+// Params get float32 master params, they are replaced when a step is skipped
+func (o *Optimizer) Params() []*tensor.Tensor {
+	return o.masters
+}
+
+// Optimizer get optimizer of master params, it is recreated when a step is skipped
+func (o *Optimizer) Optimizer() optimizer.Optimizer {
+	return o.optm
+}
+
+// Scaler get loss scaler
+func (o *Optimizer) Scaler() *Scaler {
+	return o.scaler
+}
+
+// Backward run backward of loss multiplied by current loss scale
+func (o *Optimizer) Backward(loss *tensor.Tensor) {
+	loss = loss.ToScalarType(consts.KFloat)
+	loss.Mul(nnutil.Scalar(o.scaler.Scale(), loss)).Backward()
+}
+
+// Step update master params and cast them to the layers, returns false
+// when the step is skipped for overflow
+func (o *Optimizer) Step() (bool, error) {
+	var params, backups []*tensor.Tensor
+	for i, m := range o.masters {
+		if o.trainable[i] {
+			params = append(params, m)
+			backups = append(backups, nnutil.Detach(m, true))
+		}
+	}
+	o.optm.Step(params)
+	finite := isFinite(params)
+	if !finite {
+		for i, j := 0, 0; i < len(o.masters); i++ {
+			if !o.trainable[i] {
+				continue
+			}
+			backups[j].SetRequiresGrad(true)
+			o.masters[i] = backups[j]
+			j++
+		}
+		lr := o.optm.GetLr()
+		o.optm = o.newOptimizer()
+		o.optm.SetLr(lr)
+	}
+	o.scaler.Update(finite)
+	return finite, o.Cast()
+}
+
+// Cast update layers of the net with compute copies of master params,
+// the layers are updated in place
+func (o *Optimizer) Cast() error {
+	inv := 1 / o.scaler.Scale()
+	copies := make(map[key]*tensor.Tensor, len(o.masters))
+	for i, m := range o.masters {
+		d := nnutil.Detach(m, o.trainable[i])
+		c := d
+		if o.trainable[i] {
+			// same value as master, gradient of master is divided by scale
+			c = d.Add(m.Sub(d).Mul(nnutil.Scalar(inv, m)))
+		}
+		copies[o.keys[i]] = c.ToScalarType(o.t)
+	}
+	return o.net.MapParams(func(i int, name string, t *tensor.Tensor) *tensor.Tensor {
+		if c, ok := copies[key{i, name}]; ok {
+			return c
+		}
+		return t
+	}, net.WithLoadParamType(o.t))
+}
+
+// Sync update layers of the net with float32 master params, e.g. before
+// saving, call Cast to continue training
+func (o *Optimizer) Sync() error {
+	masters := make(map[key]*tensor.Tensor, len(o.masters))
+	for i, m := range o.masters {
+		masters[o.keys[i]] = nnutil.Detach(m, o.trainable[i])
+	}
+	return o.net.MapParams(func(i int, name string, t *tensor.Tensor) *tensor.Tensor {
+		if m, ok := masters[key{i, name}]; ok {
+			return m
+		}
+		return t
+	}, net.WithLoadParamType(consts.KFloat))
+}
+
+// isFinite check whether all params are finite
+func isFinite(params []*tensor.Tensor) bool {
+	sums := make(map[consts.DeviceType]*tensor.Tensor)
+	for _, p := range params {
+		// nan and inf are kept by sum
+		sum := nnutil.Detach(p, true).Abs().Reshape(-1).Sum(0, false)
+		if prev, ok := sums[p.DeviceType()]; ok {
+			sum = prev.Add(sum)
+		}
+		sums[p.DeviceType()] = sum
+	}
+	for _, sum := range sums {
+		v := float64(sum.ToDevice(consts.KCPU).Float32Value()[0])
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return false
+		}
+	}
+	return true
+}
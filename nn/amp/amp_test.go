@@ -0,0 +1,130 @@
+package amp
+
+import (
+	"math"
+	"testing"
+
+	"github.com/lwch/gotorch/consts"
+	"github.com/lwch/gotorch/optimizer"
+	"github.com/lwch/gotorch/tensor"
+	"github.com/lwch/tnn/nn/layer"
+	"github.com/lwch/tnn/nn/net"
+)
+
+func TestScaler(t *testing.T) {
+	s := NewScaler(WithInitScale(8), WithGrowthInterval(2))
+	s.Update(true)
+	if s.Scale() != 8 {
+		t.Fatalf("unexpected grow: %f", s.Scale())
+	}
+	s.Update(true)
+	if s.Scale() != 16 {
+		t.Fatalf("scale not grown: %f", s.Scale())
+	}
+	s.Update(true)
+	s.Update(false)
+	if s.Scale() != 8 || s.Skipped() != 1 {
+		t.Fatalf("scale not backed off: %f", s.Scale())
+	}
+	s.Update(true)
+	if s.Scale() != 8 {
+		t.Fatal("good steps not reset after overflow")
+	}
+}
+
+func TestStep(t *testing.T) {
+	var n net.Net
+	n.Add(layer.NewLinear("linear", 2, 1))
+	o, err := New(&n, consts.KBFloat16, func() optimizer.Optimizer {
+		return optimizer.NewAdam(optimizer.WithAdamLr(0.1))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := n.Layers()[0]
+	if l.Params()["w"].ScalarType() != consts.KBFloat16 {
+		t.Fatal("layer not casted")
+	}
+	optm := o.Optimizer()
+	x := tensor.FromBFloat16([]float32{1, 2, 3, 4}, tensor.WithShapes(2, 2))
+	before := o.Params()[0].Float32Value()
+	y, err := n.Forward(layer.NewContext(true), x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.Backward(y[0].Sum(0, false))
+	ok, err := o.Step()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("step skipped")
+	}
+	after := o.Params()[0].Float32Value()
+	if before[0] == after[0] {
+		t.Fatal("master not updated")
+	}
+
+	o.Scaler().scale = math.MaxFloat32
+	y, err = n.Forward(layer.NewContext(true), x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.Backward(y[0].Sum(0, false))
+	ok, err = o.Step()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("overflow not detected")
+	}
+	if o.Scaler().Scale() != math.MaxFloat32/2 {
+		t.Fatalf("scale not backed off: %f", o.Scaler().Scale())
+	}
+	if got := o.Params()[0].Float32Value(); got[0] != after[0] {
+		t.Fatal("master updated by skipped step")
+	}
+	if o.Optimizer() == optm {
+		t.Fatal("optimizer not recreated by skipped step")
+	}
+	if n.Layers()[0] != l || l.Params()["w"].ScalarType() != consts.KBFloat16 {
+		t.Fatal("layer not updated in place")
+	}
+	if err = o.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if n.Layers()[0].Params()["w"].ScalarType() != consts.KFloat {
+		t.Fatal("master not synced")
+	}
+}
+
+func TestFrozen(t *testing.T) {
+	var n net.Net
+	n.Add(layer.NewLinear("frozen", 2, 2), layer.NewLinear("linear", 2, 1))
+	n.Layers()[0].Freeze()
+	o, err := New(&n, consts.KBFloat16, func() optimizer.Optimizer {
+		return optimizer.NewAdam(optimizer.WithAdamLr(0.1))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := o.Params()[0].Float32Value()
+	x := tensor.FromBFloat16([]float32{1, 2, 3, 4}, tensor.WithShapes(2, 2))
+	y, err := n.Forward(layer.NewContext(true), x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.Backward(y[0].Sum(0, false))
+	if _, err = o.Step(); err != nil {
+		t.Fatal(err)
+	}
+	if !n.Layers()[0].(interface{ Frozen() bool }).Frozen() {
+		t.Fatal("layer unfrozen by cast")
+	}
+	after := o.Params()[0].Float32Value()
+	for i := range before {
+		if before[i] != after[i] {
+			t.Fatal("frozen master updated")
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package amp
+
+// Scaler dynamic loss scaler, the scale grows after a number of steps
+// without overflow and backs off when gradients overflow
+type Scaler struct {
+	scale    float64
+	growth   float64
+	backoff  float64
+	interval int
+	good     int
+	skipped  int
+}
+
+// ScalerOption option of loss scaler
+type ScalerOption func(*Scaler)
+
+// WithInitScale set initial scale, default is 65536
+func WithInitScale(scale float64) ScalerOption {
+	return func(s *Scaler) {
+		s.scale = scale
+	}
+}
+
+// WithGrowthFactor set factor of growing, default is 2
+func WithGrowthFactor(factor float64) ScalerOption {
+	return func(s *Scaler) {
+		s.growth = factor
+	}
+}
+
+// WithBackoffFactor set factor of backing off, default is 0.5
+func WithBackoffFactor(factor float64) ScalerOption {
+	return func(s *Scaler) {
+		s.backoff = factor
+	}
+}
+
+// WithGrowthInterval set steps without overflow before growing, default is 2000,
+// the scale never grows when n <= 0
+func WithGrowthInterval(n int) ScalerOption {
+	return func(s *Scaler) {
+		s.interval = n
+	}
+}
+
+// NewScaler create loss scaler
+func NewScaler(opts ...ScalerOption) *Scaler {
+	s := Scaler{
+		scale:    65536,
+		growth:   2,
+		backoff:  0.5,
+		interval: 2000,
+	}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return &s
+}
+
+// Scale get current scale
+func (s *Scaler) Scale() float64 {
+	return s.scale
+}
+
+// Skipped get count of steps skipped for overflow
+func (s *Scaler) Skipped() int {
+	return s.skipped
+}
+
+// Update update scale by whether gradients of the step are finite
+func (s *Scaler) Update(finite bool) {
+	if !finite {
+		s.scale *= s.backoff
+		s.good = 0
+		s.skipped++
+		return
+	}
+	s.good++
+	if s.interval > 0 && s.good >= s.interval {
+		s.scale *= s.growth
+		s.good = 0
+	}
+}
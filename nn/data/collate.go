@@ -0,0 +1,59 @@
+package data
+
+import (
+	"fmt"
+
+	"github.com/lwch/gotorch/consts"
+	"github.com/lwch/gotorch/tensor"
+)
+
+// Collate merge samples of one batch into tensors
+type Collate func(samples []Sample) ([]*tensor.Tensor, error)
+
+// Stack stack each field of samples into one float32 tensor, shapes are the
+// pinned shapes of fields without batch dimension, the i-th tensor has shapes
+// [len(samples), shapes[i]...], when shapes is empty each field is stacked to
+// [len(samples), size of field]
+func Stack(shapes ...[]int64) Collate {
+	return StackOn(consts.KCPU, shapes...)
+}
+
+// StackOn same as Stack but the tensors are created on device
+func StackOn(device consts.DeviceType, shapes ...[]int64) Collate {
+	return func(samples []Sample) ([]*tensor.Tensor, error) {
+		if len(samples) == 0 {
+			return nil, fmt.Errorf("empty batch")
+		}
+		fields := len(samples[0])
+		if len(shapes) > 0 && len(shapes) != fields {
+			return nil, fmt.Errorf("expect %d fields, got %d", len(shapes), fields)
+		}
+		ret := make([]*tensor.Tensor, fields)
+		for i := 0; i < fields; i++ {
+			size := int64(len(samples[0][i]))
+			shape := []int64{size}
+			if len(shapes) > 0 {
+				shape = shapes[i]
+				size = 1
+				for _, n := range shape {
+					size *= n
+				}
+			}
+			data := make([]float32, 0, int64(len(samples))*size)
+			for j, s := range samples {
+				if len(s) != fields {
+					return nil, fmt.Errorf("sample %d: expect %d fields, got %d", j, fields, len(s))
+				}
+				if int64(len(s[i])) != size {
+					return nil, fmt.Errorf("sample %d: field %d: expect %d values, got %d",
+						j, i, size, len(s[i]))
+				}
+				data = append(data, s[i]...)
+			}
+			ret[i] = tensor.FromFloat32(data,
+				tensor.WithShapes(append([]int64{int64(len(samples))}, shape...)...),
+				tensor.WithDevice(device))
+		}
+		return ret, nil
+	}
+}
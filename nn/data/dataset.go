@@ -0,0 +1,113 @@
+package data
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/lwch/tnn/nn/sample"
+)
+
+// Sample fields of one sample, each field is a flattened float32 array,
+// e.g. features and labels
+type Sample [][]float32
+
+// Dataset indexable dataset, Get may be called by workers concurrently
+type Dataset interface {
+	// Len get count of samples
+	Len() int
+	// Get get the idx-th sample
+	Get(idx int) (Sample, error)
+}
+
+// IterableDataset dataset which can only be read in order, e.g. stream from network,
+// Next is called by one goroutine
+type IterableDataset interface {
+	// Next get the next sample, returns io.EOF at the end
+	Next() (Sample, error)
+	// Reset restart from the first sample, it is called at the beginning of each epoch
+	Reset() error
+}
+
+type sliceDataset []Sample
+
+// FromSlice create dataset from samples in memory
+func FromSlice(samples []Sample) Dataset {
+	return sliceDataset(samples)
+}
+
+func (ds sliceDataset) Len() int {
+	return len(ds)
+}
+
+func (ds sliceDataset) Get(idx int) (Sample, error) {
+	if idx < 0 || idx >= len(ds) {
+		return nil, fmt.Errorf("index out of range: %d", idx)
+	}
+	return ds[idx], nil
+}
+
+// SampleReader reader of version 1 sample file, e.g. *sample.Reader,
+// *sample.ReaderAt and *sample.MmapReader
+type SampleReader interface {
+	BatchSize() uint32
+	FeatureSize() uint32
+	LabelSize() uint32
+	ReadSample(idx uint32, features, labels []float32) error
+}
+
+var (
+	_ SampleReader = &sample.Reader{}
+	_ SampleReader = &sample.ReaderAt{}
+	_ SampleReader = &sample.MmapReader{}
+)
+
+type sampleDataset struct {
+	r SampleReader
+}
+
+// FromSampleReader create dataset from sample file, each sample has two
+// fields, features and labels, use sample.ReaderAt or sample.MmapReader
+// to read samples by workers without lock
+func FromSampleReader(r SampleReader) Dataset {
+	return sampleDataset{r: r}
+}
+
+func (ds sampleDataset) Len() int {
+	return int(ds.r.BatchSize())
+}
+
+func (ds sampleDataset) Get(idx int) (Sample, error) {
+	features := make([]float32, ds.r.FeatureSize())
+	labels := make([]float32, ds.r.LabelSize())
+	if err := ds.r.ReadSample(uint32(idx), features, labels); err != nil {
+		return nil, err
+	}
+	return Sample{features, labels}, nil
+}
+
+type iterDataset struct {
+	ds  Dataset
+	idx int
+}
+
+// Iterable read indexable dataset in order
+func Iterable(ds Dataset) IterableDataset {
+	return &iterDataset{ds: ds}
+}
+
+func (it *iterDataset) Next() (Sample, error) {
+	if it.idx >= it.ds.Len() {
+		return nil, io.EOF
+	}
+	s, err := it.ds.Get(it.idx)
+	if err != nil {
+		return nil, err
+	}
+	it.idx++
+	return s, nil
+}
+
+func (it *iterDataset) Reset() error {
+	it.idx = 0
+	return nil
+}
@@ -0,0 +1,344 @@
+package data
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"runtime"
+	"sync"
+
+	"github.com/lwch/gotorch/tensor"
+)
+
+// Batch one batch of samples
+type Batch struct {
+	Tensors []*tensor.Tensor
+	// Index indices of samples, nil for iterable dataset
+	Index []int
+	// Size count of real samples, the rest of padded batch are zero samples
+	Size int
+}
+
+// DataLoader read samples of dataset into batches, samples are loaded and
+// collated by a pool of workers, batches are returned in order
+type DataLoader struct {
+	ds   Dataset
+	iter IterableDataset
+
+	batchSize int
+	shuffle   bool
+	rand      *rand.Rand
+	dropLast  bool
+	padLast   bool
+	collate   Collate
+	workers   int
+	prefetch  int
+}
+
+// Option option of data loader
+type Option func(*DataLoader)
+
+// WithBatchSize set samples of each batch, default is 32
+func WithBatchSize(n int) Option {
+	return func(l *DataLoader) {
+		l.batchSize = n
+	}
+}
+
+// WithShuffle shuffle samples of each epoch by random source, it only works for
+// indexable dataset, net.RandSource can be used to save the state in checkpoint
+func WithShuffle(src rand.Source) Option {
+	return func(l *DataLoader) {
+		l.shuffle = true
+		l.rand = rand.New(src)
+	}
+}
+
+// WithDropLast drop the last batch when it is not full
+func WithDropLast() Option {
+	return func(l *DataLoader) {
+		l.dropLast = true
+		l.padLast = false
+	}
+}
+
+// WithPadLast pad the last batch by zero samples when it is not full, so all
+// batches have the same shapes, see Batch.Size
+func WithPadLast() Option {
+	return func(l *DataLoader) {
+		l.padLast = true
+		l.dropLast = false
+	}
+}
+
+// WithCollate set collate function, default is Stack()
+func WithCollate(fn Collate) Option {
+	return func(l *DataLoader) {
+		l.collate = fn
+	}
+}
+
+// WithWorkers set count of workers loading and collating samples, default is count of cpus
+func WithWorkers(n int) Option {
+	return func(l *DataLoader) {
+		l.workers = n
+	}
+}
+
+// WithPrefetch set count of batches loaded ahead, default is 2 times of workers
+func WithPrefetch(n int) Option {
+	return func(l *DataLoader) {
+		l.prefetch = n
+	}
+}
+
+// NewLoader create data loader of indexable dataset
+func NewLoader(ds Dataset, opts ...Option) *DataLoader {
+	return newLoader(&DataLoader{ds: ds}, opts)
+}
+
+// NewIterableLoader create data loader of iterable dataset, samples are read by
+// one goroutine and collated by workers
+func NewIterableLoader(ds IterableDataset, opts ...Option) *DataLoader {
+	return newLoader(&DataLoader{iter: ds}, opts)
+}
+
+func newLoader(l *DataLoader, opts []Option) *DataLoader {
+	l.batchSize = 32
+	l.collate = Stack()
+	l.workers = runtime.NumCPU()
+	for _, opt := range opts {
+		opt(l)
+	}
+	if l.batchSize <= 0 {
+		l.batchSize = 1
+	}
+	if l.workers <= 0 {
+		l.workers = 1
+	}
+	if l.prefetch <= 0 {
+		l.prefetch = l.workers * 2
+	}
+	return l
+}
+
+// Len get count of batches of each epoch, returns -1 for iterable dataset
+func (l *DataLoader) Len() int {
+	if l.ds == nil {
+		return -1
+	}
+	if l.dropLast {
+		return l.ds.Len() / l.batchSize
+	}
+	return (l.ds.Len() + l.batchSize - 1) / l.batchSize
+}
+
+type result struct {
+	batch *Batch
+	err   error
+}
+
+type job struct {
+	out     chan result
+	idx     []int
+	samples []Sample
+}
+
+// Iterator iterate batches of one epoch
+type Iterator struct {
+	pending chan chan result
+	done    chan struct{}
+	once    sync.Once
+	wg      sync.WaitGroup
+}
+
+// Iter start loading batches of next epoch, the iterator must be closed
+// before starting next epoch
+//
+//	it := loader.Iter()
+//	defer it.Close()
+//	for {
+//	    b, err := it.Next()
+//	    if err == io.EOF {
+//	        break
+//	    }
+//	    ...
+//	}
+func (l *DataLoader) Iter() *Iterator {
+	it := &Iterator{
+		pending: make(chan chan result, l.prefetch),
+		done:    make(chan struct{}),
+	}
+	jobs := make(chan job)
+	for i := 0; i < l.workers; i++ {
+		it.wg.Add(1)
+		go func() {
+			defer it.wg.Done()
+			for j := range jobs {
+				b, err := l.load(j)
+				j.out <- result{batch: b, err: err}
+			}
+		}()
+	}
+	var next func() (job, error)
+	if l.ds != nil {
+		next = l.indexJobs()
+	} else {
+		next = l.iterJobs()
+	}
+	it.wg.Add(1)
+	go func() {
+		defer it.wg.Done()
+		defer close(it.pending)
+		defer close(jobs)
+		for {
+			j, err := next()
+			if err == io.EOF {
+				return
+			}
+			out := make(chan result, 1)
+			select {
+			case it.pending <- out:
+			case <-it.done:
+				return
+			}
+			if err != nil {
+				out <- result{err: err}
+				return
+			}
+			j.out = out
+			select {
+			case jobs <- j:
+			case <-it.done:
+				return
+			}
+		}
+	}()
+	return it
+}
+
+// indexJobs split indices of samples into batches, indices are shuffled in
+// the caller goroutine so the order is reproducible
+func (l *DataLoader) indexJobs() func() (job, error) {
+	idx := make([]int, l.ds.Len())
+	for i := range idx {
+		idx[i] = i
+	}
+	if l.shuffle {
+		l.rand.Shuffle(len(idx), func(i, j int) {
+			idx[i], idx[j] = idx[j], idx[i]
+		})
+	}
+	batches := l.Len()
+	i := 0
+	return func() (job, error) {
+		if i >= batches {
+			return job{}, io.EOF
+		}
+		start := i * l.batchSize
+		end := start + l.batchSize
+		if end > len(idx) {
+			end = len(idx)
+		}
+		i++
+		return job{idx: idx[start:end]}, nil
+	}
+}
+
+// iterJobs read samples of each batch in order
+func (l *DataLoader) iterJobs() func() (job, error) {
+	reset := true
+	eof := false
+	return func() (job, error) {
+		if reset {
+			reset = false
+			if err := l.iter.Reset(); err != nil {
+				return job{}, err
+			}
+		}
+		if eof {
+			return job{}, io.EOF
+		}
+		samples := make([]Sample, 0, l.batchSize)
+		for len(samples) < l.batchSize {
+			s, err := l.iter.Next()
+			if err == io.EOF {
+				eof = true
+				break
+			}
+			if err != nil {
+				return job{}, err
+			}
+			samples = append(samples, s)
+		}
+		if len(samples) == 0 || (eof && l.dropLast && len(samples) < l.batchSize) {
+			return job{}, io.EOF
+		}
+		return job{samples: samples}, nil
+	}
+}
+
+func (l *DataLoader) load(j job) (*Batch, error) {
+	samples := j.samples
+	if samples == nil {
+		samples = make([]Sample, len(j.idx))
+		for i, idx := range j.idx {
+			var err error
+			if samples[i], err = l.ds.Get(idx); err != nil {
+				return nil, fmt.Errorf("sample %d: %v", idx, err)
+			}
+		}
+	}
+	size := len(samples)
+	if l.padLast && size < l.batchSize {
+		zero := make(Sample, len(samples[0]))
+		for i, field := range samples[0] {
+			zero[i] = make([]float32, len(field))
+		}
+		for len(samples) < l.batchSize {
+			samples = append(samples, zero)
+		}
+	}
+	tensors, err := l.collate(samples)
+	if err != nil {
+		return nil, err
+	}
+	return &Batch{Tensors: tensors, Index: j.idx, Size: size}, nil
+}
+
+// Next get the next batch, returns io.EOF at the end of the epoch, the
+// iteration should be stopped when error is returned
+func (it *Iterator) Next() (*Batch, error) {
+	out, ok := <-it.pending
+	if !ok {
+		return nil, io.EOF
+	}
+	r := <-out
+	return r.batch, r.err
+}
+
+// Close stop loading and wait for workers
+func (it *Iterator) Close() {
+	it.once.Do(func() {
+		close(it.done)
+	})
+	it.wg.Wait()
+}
+
+// ForEach iterate batches of one epoch
+func (l *DataLoader) ForEach(fn func(b *Batch) error) error {
+	it := l.Iter()
+	defer it.Close()
+	for {
+		b, err := it.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = fn(b); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package data
+
+import (
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lwch/tnn/nn/sample"
+)
+
+func testSamples(n int) []Sample {
+	samples := make([]Sample, n)
+	for i := range samples {
+		samples[i] = Sample{
+			{float32(i), float32(i), float32(i), float32(i)},
+			{float32(i)},
+		}
+	}
+	return samples
+}
+
+func collect(t *testing.T, l *DataLoader) [][]float32 {
+	var ret [][]float32
+	err := l.ForEach(func(b *Batch) error {
+		features := b.Tensors[0].Float32Value()
+		labels := b.Tensors[1].Float32Value()
+		shapes := b.Tensors[0].Shapes()
+		if len(shapes) != 3 || shapes[1] != 2 || shapes[2] != 2 {
+			t.Fatalf("unexpected shapes: %v", shapes)
+		}
+		for i := 0; i < b.Size; i++ {
+			if features[i*4] != labels[i] {
+				t.Fatalf("sample %d: features %v do not match label %v", i, features[i*4:i*4+4], labels[i])
+			}
+			if b.Index != nil && labels[i] != float32(b.Index[i]) {
+				t.Fatalf("sample %d: expect index %d, got %v", i, b.Index[i], labels[i])
+			}
+		}
+		ret = append(ret, labels)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ret
+}
+
+func TestLoader(t *testing.T) {
+	ds := FromSlice(testSamples(10))
+	collate := WithCollate(Stack([]int64{2, 2}, []int64{1}))
+	batches := collect(t, NewLoader(ds, WithBatchSize(4), collate, WithWorkers(3)))
+	if len(batches) != 3 || len(batches[2]) != 2 {
+		t.Fatalf("unexpected batches: %v", batches)
+	}
+	for i, b := range batches {
+		for j, v := range b {
+			if v != float32(i*4+j) {
+				t.Fatalf("batch %d: unexpected order: %v", i, b)
+			}
+		}
+	}
+
+	batches = collect(t, NewLoader(ds, WithBatchSize(4), collate, WithDropLast()))
+	if len(batches) != 2 {
+		t.Fatalf("expect 2 batches, got %d", len(batches))
+	}
+
+	batches = collect(t, NewLoader(ds, WithBatchSize(4), collate, WithPadLast()))
+	if len(batches) != 3 || len(batches[2]) != 4 || batches[2][3] != 0 {
+		t.Fatalf("unexpected padded batches: %v", batches)
+	}
+}
+
+func TestShuffle(t *testing.T) {
+	ds := FromSlice(testSamples(10))
+	collate := WithCollate(Stack([]int64{2, 2}, []int64{1}))
+	epoch := func(seed int64) []float32 {
+		var ret []float32
+		for _, b := range collect(t, NewLoader(ds, WithBatchSize(3), collate, WithShuffle(rand.NewSource(seed)))) {
+			ret = append(ret, b...)
+		}
+		return ret
+	}
+	a, b := epoch(1), epoch(1)
+	seen := make(map[float32]bool)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatal("shuffle is not reproducible")
+		}
+		seen[a[i]] = true
+	}
+	if len(seen) != 10 {
+		t.Fatalf("expect 10 samples, got %v", a)
+	}
+}
+
+func TestIterable(t *testing.T) {
+	it := Iterable(FromSlice(testSamples(10)))
+	collate := WithCollate(Stack([]int64{2, 2}, []int64{1}))
+	l := NewIterableLoader(it, WithBatchSize(4), collate, WithDropLast())
+	for epoch := 0; epoch < 2; epoch++ {
+		batches := collect(t, l)
+		if len(batches) != 2 || batches[1][3] != 7 {
+			t.Fatalf("epoch %d: unexpected batches: %v", epoch, batches)
+		}
+	}
+}
+
+func TestClose(t *testing.T) {
+	l := NewLoader(FromSlice(testSamples(100)), WithBatchSize(1), WithPrefetch(1))
+	it := l.Iter()
+	if _, err := it.Next(); err != nil {
+		t.Fatal(err)
+	}
+	it.Close()
+	it = l.Iter()
+	defer it.Close()
+	n := 0
+	for {
+		_, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != 100 {
+		t.Fatalf("expect 100 batches, got %d", n)
+	}
+}
+
+func TestSampleReader(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "samples"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := sample.NewWriter(f)
+	for _, s := range testSamples(10) {
+		if err = w.WriteSample(s[0], s[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	r, err := sample.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collate := WithCollate(Stack([]int64{2, 2}, []int64{1}))
+	batches := collect(t, NewLoader(FromSampleReader(r), WithBatchSize(5), collate))
+	if len(batches) != 2 || batches[1][4] != 9 {
+		t.Fatalf("unexpected batches: %v", batches)
+	}
+}
@@ -0,0 +1,310 @@
+package dist
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lwch/tnn/internal/nnutil"
+)
+
+// Config of worker, Addrs are listen addresses of all workers ordered by rank
+type Config struct {
+	Rank  int
+	World int
+	Addrs []string
+	// Timeout of connecting and each collective operation, default is 5 minutes,
+	// the peer is treated as lost when it does not respond in time
+	Timeout time.Duration
+}
+
+// ConfigFromEnv read config from TNN_RANK, TNN_WORLD_SIZE and TNN_ADDRS,
+// addresses are separated by comma
+func ConfigFromEnv() (Config, error) {
+	var cfg Config
+	var err error
+	if cfg.Rank, err = strconv.Atoi(os.Getenv("TNN_RANK")); err != nil {
+		return cfg, fmt.Errorf("invalid TNN_RANK: %v", err)
+	}
+	if cfg.World, err = strconv.Atoi(os.Getenv("TNN_WORLD_SIZE")); err != nil {
+		return cfg, fmt.Errorf("invalid TNN_WORLD_SIZE: %v", err)
+	}
+	cfg.Addrs = strings.Split(os.Getenv("TNN_ADDRS"), ",")
+	return cfg, cfg.check()
+}
+
+func (cfg Config) check() error {
+	if cfg.World <= 0 || cfg.Rank < 0 || cfg.Rank >= cfg.World {
+		return fmt.Errorf("invalid rank %d of world size %d", cfg.Rank, cfg.World)
+	}
+	if len(cfg.Addrs) != cfg.World {
+		return fmt.Errorf("expect %d addresses, got %d", cfg.World, len(cfg.Addrs))
+	}
+	return nil
+}
+
+// PeerError error of communicating with the peer, e.g. the peer disappears
+type PeerError struct {
+	Rank int
+	Err  error
+}
+
+func (e *PeerError) Error() string {
+	return fmt.Sprintf("peer %d: %v", e.Rank, e.Err)
+}
+
+func (e *PeerError) Unwrap() error {
+	return e.Err
+}
+
+// Ring workers connected as a ring, each worker sends to the next rank and
+// receives from the previous rank
+type Ring struct {
+	rank, world int
+	timeout     time.Duration
+	ln          net.Listener
+	next        net.Conn // send to the next rank
+	prev        net.Conn // receive from the previous rank
+	seq         uint64   // sequence of messages, detects out of sync peers
+}
+
+// Dial listen on address of rank and connect to the next rank, it blocks until
+// the ring is connected
+func Dial(cfg Config) (*Ring, error) {
+	if err := cfg.check(); err != nil {
+		return nil, err
+	}
+	r := &Ring{rank: cfg.Rank, world: cfg.World, timeout: cfg.Timeout}
+	if r.timeout <= 0 {
+		r.timeout = 5 * time.Minute
+	}
+	if r.world == 1 {
+		return r, nil
+	}
+	ln, err := net.Listen("tcp", cfg.Addrs[r.rank])
+	if err != nil {
+		return nil, err
+	}
+	r.ln = ln
+	deadline := time.Now().Add(r.timeout)
+	var wg sync.WaitGroup
+	var acceptErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.prev, acceptErr = r.accept(deadline)
+	}()
+	r.next, err = r.dial(cfg.Addrs[r.nextRank()], deadline)
+	wg.Wait()
+	if err == nil {
+		err = acceptErr
+	}
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Ring) nextRank() int {
+	return (r.rank + 1) % r.world
+}
+
+func (r *Ring) prevRank() int {
+	return (r.rank - 1 + r.world) % r.world
+}
+
+func (r *Ring) dial(addr string, deadline time.Time) (net.Conn, error) {
+	for {
+		conn, err := net.DialTimeout("tcp", addr, time.Until(deadline))
+		if err == nil {
+			var hdr [8]byte
+			binary.LittleEndian.PutUint32(hdr[:], uint32(r.rank))
+			binary.LittleEndian.PutUint32(hdr[4:], uint32(r.world))
+			conn.SetWriteDeadline(deadline)
+			if _, err = conn.Write(hdr[:]); err == nil {
+				return conn, nil
+			}
+			conn.Close()
+		}
+		if time.Now().After(deadline) {
+			return nil, &PeerError{Rank: r.nextRank(), Err: err}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func (r *Ring) accept(deadline time.Time) (net.Conn, error) {
+	if ln, ok := r.ln.(*net.TCPListener); ok {
+		ln.SetDeadline(deadline)
+	}
+	for {
+		conn, err := r.ln.Accept()
+		if err != nil {
+			return nil, &PeerError{Rank: r.prevRank(), Err: err}
+		}
+		var hdr [8]byte
+		conn.SetReadDeadline(deadline)
+		if _, err = io.ReadFull(conn, hdr[:]); err != nil {
+			conn.Close()
+			continue
+		}
+		rank := int(binary.LittleEndian.Uint32(hdr[:]))
+		world := int(binary.LittleEndian.Uint32(hdr[4:]))
+		if rank != r.prevRank() || world != r.world {
+			conn.Close()
+			return nil, fmt.Errorf("unexpected peer %d of world size %d", rank, world)
+		}
+		return conn, nil
+	}
+}
+
+// Rank get rank of the worker
+func (r *Ring) Rank() int {
+	return r.rank
+}
+
+// World get count of workers
+func (r *Ring) World() int {
+	return r.world
+}
+
+// Close close connections
+func (r *Ring) Close() error {
+	var errs []error
+	for _, c := range []io.Closer{r.next, r.prev, r.ln} {
+		if c != nil {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// send and receive one message concurrently
+func (r *Ring) exchange(send, recv []float32) error {
+	r.seq++
+	errs := make(chan error, 1)
+	go func() {
+		errs <- r.write(send)
+	}()
+	err := r.read(recv)
+	if e := <-errs; err == nil {
+		err = e
+	}
+	return err
+}
+
+func (r *Ring) write(data []float32) error {
+	buf := make([]byte, 12+len(data)*4)
+	binary.LittleEndian.PutUint64(buf, r.seq)
+	binary.LittleEndian.PutUint32(buf[8:], uint32(len(data)))
+	for i, v := range data {
+		binary.LittleEndian.PutUint32(buf[12+i*4:], math.Float32bits(v))
+	}
+	r.next.SetWriteDeadline(time.Now().Add(r.timeout))
+	if _, err := r.next.Write(buf); err != nil {
+		return &PeerError{Rank: r.nextRank(), Err: err}
+	}
+	return nil
+}
+
+func (r *Ring) read(data []float32) error {
+	r.prev.SetReadDeadline(time.Now().Add(r.timeout))
+	var hdr [12]byte
+	if _, err := io.ReadFull(r.prev, hdr[:]); err != nil {
+		return &PeerError{Rank: r.prevRank(), Err: err}
+	}
+	seq := binary.LittleEndian.Uint64(hdr[:])
+	n := int(binary.LittleEndian.Uint32(hdr[8:]))
+	if seq != r.seq || n != len(data) {
+		return &PeerError{Rank: r.prevRank(), Err: fmt.Errorf(
+			"unexpected message %d of %d values, expect message %d of %d values",
+			seq, n, r.seq, len(data))}
+	}
+	buf := make([]byte, n*4)
+	if _, err := io.ReadFull(r.prev, buf); err != nil {
+		return &PeerError{Rank: r.prevRank(), Err: err}
+	}
+	for i := range data {
+		data[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[i*4:]))
+	}
+	return nil
+}
+
+// chunk get range of the i-th chunk when data of size n is split to world chunks
+func (r *Ring) chunk(n, i int) (int, int) {
+	i = (i%r.world + r.world) % r.world
+	size := n / r.world
+	rest := n % r.world
+	start := i*size + nnutil.Min(i, rest)
+	if i < rest {
+		size++
+	}
+	return start, start + size
+}
+
+// AllReduce sum data of all workers in place, all workers get the same result
+func (r *Ring) AllReduce(data []float32) error {
+	if r.world == 1 {
+		return nil
+	}
+	n := len(data)
+	recv := make([]float32, n/r.world+1)
+	// reduce-scatter, chunk rank+1 is reduced on each worker at last
+	for s := 0; s < r.world-1; s++ {
+		ss, se := r.chunk(n, r.rank-s)
+		rs, re := r.chunk(n, r.rank-s-1)
+		buf := recv[:re-rs]
+		if err := r.exchange(data[ss:se], buf); err != nil {
+			return err
+		}
+		for i, v := range buf {
+			data[rs+i] += v
+		}
+	}
+	// all-gather
+	for s := 0; s < r.world-1; s++ {
+		ss, se := r.chunk(n, r.rank+1-s)
+		rs, re := r.chunk(n, r.rank-s)
+		if err := r.exchange(data[ss:se], data[rs:re]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AllReduceMean average data of all workers in place
+func (r *Ring) AllReduceMean(data []float32) error {
+	if err := r.AllReduce(data); err != nil {
+		return err
+	}
+	for i := range data {
+		data[i] /= float32(r.world)
+	}
+	return nil
+}
+
+// Broadcast copy data of root to all workers
+func (r *Ring) Broadcast(data []float32, root int) error {
+	if r.world == 1 {
+		return nil
+	}
+	r.seq++
+	if r.rank != root {
+		if err := r.read(data); err != nil {
+			return err
+		}
+	}
+	if r.nextRank() == root {
+		return nil
+	}
+	return r.write(data)
+}
@@ -0,0 +1,236 @@
+package dist
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func freeAddrs(t *testing.T, n int) []string {
+	var addrs []string
+	for i := 0; i < n; i++ {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addrs = append(addrs, ln.Addr().String())
+		ln.Close()
+	}
+	return addrs
+}
+
+func dialAll(t *testing.T, world int) []*Ring {
+	addrs := freeAddrs(t, world)
+	rings := make([]*Ring, world)
+	errs := make([]error, world)
+	var wg sync.WaitGroup
+	for rank := 0; rank < world; rank++ {
+		wg.Add(1)
+		go func(rank int) {
+			defer wg.Done()
+			rings[rank], errs[rank] = Dial(Config{
+				Rank:    rank,
+				World:   world,
+				Addrs:   addrs,
+				Timeout: 5 * time.Second,
+			})
+		}(rank)
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		t.Fatal(err)
+	}
+	return rings
+}
+
+func workerData(rank, n int) []float32 {
+	data := make([]float32, n)
+	for i := range data {
+		data[i] = float32(rank*n + i)
+	}
+	return data
+}
+
+func TestAllReduce(t *testing.T) {
+	for _, world := range []int{1, 2, 3, 4} {
+		rings := dialAll(t, world)
+		for _, n := range []int{1, 3, 10} {
+			results := make([][]float32, world)
+			errs := make([]error, world)
+			var wg sync.WaitGroup
+			for rank, r := range rings {
+				wg.Add(1)
+				go func(rank int, r *Ring) {
+					defer wg.Done()
+					results[rank] = workerData(rank, n)
+					errs[rank] = r.AllReduce(results[rank])
+				}(rank, r)
+			}
+			wg.Wait()
+			if err := errors.Join(errs...); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < n; i++ {
+				var expect float32
+				for rank := 0; rank < world; rank++ {
+					expect += workerData(rank, n)[i]
+				}
+				for rank := range results {
+					if results[rank][i] != expect {
+						t.Fatalf("world %d: rank %d: index %d: expect %v, got %v",
+							world, rank, i, expect, results[rank][i])
+					}
+				}
+			}
+		}
+		for _, r := range rings {
+			r.Close()
+		}
+	}
+}
+
+func TestBroadcast(t *testing.T) {
+	rings := dialAll(t, 3)
+	defer func() {
+		for _, r := range rings {
+			r.Close()
+		}
+	}()
+	results := make([][]float32, len(rings))
+	errs := make([]error, len(rings))
+	var wg sync.WaitGroup
+	for rank, r := range rings {
+		wg.Add(1)
+		go func(rank int, r *Ring) {
+			defer wg.Done()
+			results[rank] = workerData(rank, 4)
+			errs[rank] = r.Broadcast(results[rank], 1)
+		}(rank, r)
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		t.Fatal(err)
+	}
+	expect := workerData(1, 4)
+	for rank := range results {
+		for i := range expect {
+			if results[rank][i] != expect[i] {
+				t.Fatalf("rank %d: expect %v, got %v", rank, expect, results[rank])
+			}
+		}
+	}
+}
+
+func TestPeerLost(t *testing.T) {
+	rings := dialAll(t, 3)
+	defer func() {
+		for _, r := range rings {
+			r.Close()
+		}
+	}()
+	// rank 2 disappears
+	rings[2].Close()
+	errs := make([]error, 2)
+	var wg sync.WaitGroup
+	for rank := 0; rank < 2; rank++ {
+		wg.Add(1)
+		go func(rank int) {
+			defer wg.Done()
+			errs[rank] = rings[rank].AllReduce(workerData(rank, 10))
+			if errs[rank] != nil {
+				// workers exit on error
+				rings[rank].Close()
+			}
+		}(rank)
+	}
+	wg.Wait()
+	// rank 0 receives from rank 2 and detects it first
+	var pe *PeerError
+	if !errors.As(errs[0], &pe) || pe.Rank != 2 {
+		t.Fatalf("expect error of peer 2, got %v", errs[0])
+	}
+	if errs[1] == nil {
+		t.Fatal("expect error of rank 1")
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("TNN_RANK", "1")
+	t.Setenv("TNN_WORLD_SIZE", "2")
+	t.Setenv("TNN_ADDRS", "127.0.0.1:1000,127.0.0.1:1001")
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Rank != 1 || cfg.World != 2 || cfg.Addrs[1] != "127.0.0.1:1001" {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	t.Setenv("TNN_RANK", "2")
+	if _, err = ConfigFromEnv(); err == nil {
+		t.Fatal("expect error of invalid rank")
+	}
+}
+
+// TestProcesses run workers as processes on loopback
+func TestProcesses(t *testing.T) {
+	if os.Getenv("TNN_RANK") != "" {
+		t.Skip("worker process")
+	}
+	const world = 3
+	addrs := strings.Join(freeAddrs(t, world), ",")
+	cmds := make([]*exec.Cmd, world)
+	outputs := make([]strings.Builder, world)
+	for rank := range cmds {
+		cmds[rank] = exec.Command(os.Args[0], "-test.run=^TestWorker$")
+		cmds[rank].Env = append(os.Environ(),
+			fmt.Sprintf("TNN_RANK=%d", rank),
+			fmt.Sprintf("TNN_WORLD_SIZE=%d", world),
+			"TNN_ADDRS="+addrs)
+		cmds[rank].Stdout = &outputs[rank]
+		cmds[rank].Stderr = &outputs[rank]
+		if err := cmds[rank].Start(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for rank, cmd := range cmds {
+		if err := cmd.Wait(); err != nil {
+			t.Fatalf("rank %d: %v\n%s", rank, err, outputs[rank].String())
+		}
+	}
+}
+
+func TestWorker(t *testing.T) {
+	if os.Getenv("TNN_RANK") == "" {
+		t.Skip("run by TestProcesses")
+	}
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Timeout = 10 * time.Second
+	r, err := Dial(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	data := workerData(cfg.Rank, 5)
+	if err = r.AllReduceMean(data); err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range data {
+		var expect float32
+		for rank := 0; rank < cfg.World; rank++ {
+			expect += workerData(rank, 5)[i]
+		}
+		expect /= float32(cfg.World)
+		if v != expect {
+			t.Fatalf("index %d: expect %v, got %v", i, expect, v)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package dist
+
+import (
+	"github.com/lwch/gotorch/consts"
+	"github.com/lwch/gotorch/tensor"
+	"github.com/lwch/tnn/nn/grad"
+)
+
+// AllReduceGrads average gradients collected by tracker over all workers,
+// nil gradients are treated as zero
+func (r *Ring) AllReduceGrads(t *grad.Tracker) error {
+	params := t.Params()
+	grads, err := r.allReduceMean(params, t.Grads())
+	if err != nil {
+		return err
+	}
+	return t.SetGrads(grads)
+}
+
+// SyncParams copy params of rank 0 to all workers, call it before training
+// to start from the same params
+func (r *Ring) SyncParams(t *grad.Tracker) error {
+	params := t.Params()
+	data := flatten(params, params)
+	if err := r.Broadcast(data, 0); err != nil {
+		return err
+	}
+	return t.Load(unflatten(data, params))
+}
+
+// AllReduceTensors average tensors over all workers, returns tensors with the
+// same shapes, scalar types and devices
+func (r *Ring) AllReduceTensors(ts []*tensor.Tensor) ([]*tensor.Tensor, error) {
+	return r.allReduceMean(ts, ts)
+}
+
+func (r *Ring) allReduceMean(like, ts []*tensor.Tensor) ([]*tensor.Tensor, error) {
+	data := flatten(like, ts)
+	if err := r.AllReduceMean(data); err != nil {
+		return nil, err
+	}
+	return unflatten(data, like), nil
+}
+
+// flatten copy ts to one float32 slice, nil tensor is filled by zeros with size of like
+func flatten(like, ts []*tensor.Tensor) []float32 {
+	var size int64
+	for _, t := range like {
+		size += t.ElemCount()
+	}
+	ret := make([]float32, 0, size)
+	for i, t := range ts {
+		if t == nil {
+			ret = append(ret, make([]float32, like[i].ElemCount())...)
+			continue
+		}
+		ret = append(ret, t.ToDevice(consts.KCPU).ToScalarType(consts.KFloat).Float32Value()...)
+	}
+	return ret
+}
+
+// unflatten split data to tensors like
+func unflatten(data []float32, like []*tensor.Tensor) []*tensor.Tensor {
+	ret := make([]*tensor.Tensor, len(like))
+	for i, t := range like {
+		n := t.ElemCount()
+		ret[i] = tensor.FromFloat32(data[:n],
+			tensor.WithShapes(t.Shapes()...),
+			tensor.WithDevice(t.DeviceType())).ToScalarType(t.ScalarType())
+		data = data[n:]
+	}
+	return ret
+}
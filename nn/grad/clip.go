@@ -0,0 +1,26 @@
+package grad
+
+import (
+	"github.com/lwch/gotorch/tensor"
+	"github.com/lwch/tnn/nn/net"
+)
+
+// ClipNorm clip gradients of params by their global l2 norm
+func ClipNorm(params []*tensor.Tensor, max float64) {
+	tensor.ClipGradNorm(params, max, 2)
+}
+
+// ClipLayerNorm clip gradients of each layer of the net by l2 norm of the layer
+func ClipLayerNorm(n *net.Net, max float64) {
+	for _, l := range n.Layers() {
+		params := l.Params()
+		if len(params) == 0 {
+			continue
+		}
+		list := make([]*tensor.Tensor, 0, len(params))
+		for _, p := range params {
+			list = append(list, p)
+		}
+		tensor.ClipGradNorm(list, max, 2)
+	}
+}
@@ -0,0 +1,272 @@
+package grad
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/lwch/gotorch/consts"
+	"github.com/lwch/gotorch/optimizer"
+	"github.com/lwch/gotorch/tensor"
+	"github.com/lwch/tnn/internal/nnutil"
+	"github.com/lwch/tnn/nn/net"
+)
+
+type param struct {
+	layer int
+	name  string
+	t     *tensor.Tensor // leaf param updated by optimizer
+	probe *tensor.Tensor // float32 zero probe receives gradient of t
+	grad  *tensor.Tensor // gradient read from probe, nil means zero
+}
+
+// LayerStat gradient statistics of one layer
+type LayerStat struct {
+	Index  int
+	Layer  string
+	Norm   float64
+	MaxAbs float64
+	Finite bool
+}
+
+// Tracker make gradients of net params readable and changeable before the
+// optimizer steps, gotorch does not expose gradients, so layers of the net
+// compute with copies of params plus zero probes, gradients are read from the
+// probes and written back to params by Step. Layers are updated in place, so
+// layers got by Layers of the net keep valid. It costs about 4 times memory of
+// the params, use it to debug or monitor training.
+//
+//	y, err := n.Forward(ctx, x)
+//	lossFunc(y[0], target).Backward()
+//	err = tracker.Collect()
+//	tracker.ClipNorm(1)
+//	err = tracker.Step(optm)
+type Tracker struct {
+	net    *net.Net
+	names  []string
+	params []*param
+}
+
+// NewTracker create tracker of net, params are copied, so requires grad of
+// params of the net are not changed
+func NewTracker(n *net.Net) (*Tracker, error) {
+	t := &Tracker{net: n}
+	for i, l := range n.Layers() {
+		t.names = append(t.names, l.Name())
+		for name, p := range l.Params() {
+			if !nnutil.IsFloat(p.ScalarType()) {
+				continue
+			}
+			v := nnutil.Copy(p)
+			v.SetRequiresGrad(true)
+			t.params = append(t.params, &param{layer: i, name: name, t: v})
+		}
+	}
+	sort.Slice(t.params, func(i, j int) bool {
+		if t.params[i].layer != t.params[j].layer {
+			return t.params[i].layer < t.params[j].layer
+		}
+		return t.params[i].name < t.params[j].name
+	})
+	return t, t.attach()
+}
+
+// Params get params updated by optimizer
+func (t *Tracker) Params() []*tensor.Tensor {
+	ret := make([]*tensor.Tensor, len(t.params))
+	for i, p := range t.params {
+		ret[i] = p.t
+	}
+	return ret
+}
+
+// attach update layers with params plus new probes
+func (t *Tracker) attach() error {
+	copies := make(map[string]*tensor.Tensor, len(t.params))
+	for _, p := range t.params {
+		p.probe = nnutil.NewProbe(p.t)
+		// zero whatever the value of probe is after reading
+		zero := p.probe.Sub(nnutil.Detach(p.probe, true)).ToScalarType(p.t.ScalarType())
+		copies[fmt.Sprintf("%d/%s", p.layer, p.name)] = nnutil.Detach(p.t, true).Add(zero)
+	}
+	return t.net.MapParams(func(i int, name string, current *tensor.Tensor) *tensor.Tensor {
+		if c, ok := copies[fmt.Sprintf("%d/%s", i, name)]; ok {
+			return c
+		}
+		return current
+	})
+}
+
+// Collect read gradients accumulated by backward, gradients of layers
+// which are not finite are dropped and the layers are reported by error
+func (t *Tracker) Collect() error {
+	probes := make([]*tensor.Tensor, len(t.params))
+	for i, p := range t.params {
+		probes[i] = p.probe
+	}
+	for i, g := range nnutil.ReadProbes(probes) {
+		t.params[i].grad = g
+	}
+	var bad []string
+	for _, s := range t.Stats() {
+		if s.Finite {
+			continue
+		}
+		bad = append(bad, s.Layer)
+		for _, p := range t.params {
+			if p.layer == s.Index {
+				p.grad = nil
+			}
+		}
+	}
+	if len(bad) > 0 {
+		// probes of the layers are nan, replace them
+		if err := t.attach(); err != nil {
+			return err
+		}
+		return fmt.Errorf("gradients of layers are not finite: %s", strings.Join(bad, ", "))
+	}
+	return nil
+}
+
+// Stats get gradient statistics of each layer which has params, Index is index of the layer in net
+func (t *Tracker) Stats() []LayerStat {
+	var ret []LayerStat
+	for _, p := range t.params {
+		if len(ret) == 0 || ret[len(ret)-1].Index != p.layer {
+			ret = append(ret, LayerStat{Index: p.layer, Layer: t.names[p.layer], Finite: true})
+		}
+		s := &ret[len(ret)-1]
+		if p.grad == nil {
+			continue
+		}
+		norm := value(p.grad.Pow(2).Reshape(-1).Sum(0, false))
+		maxAbs := value(p.grad.Abs().Reshape(-1).Max(0, false))
+		if math.IsNaN(norm) || math.IsInf(norm, 0) {
+			s.Finite = false
+		}
+		s.Norm += norm
+		s.MaxAbs = math.Max(s.MaxAbs, maxAbs)
+	}
+	for i := range ret {
+		ret[i].Norm = math.Sqrt(ret[i].Norm)
+	}
+	return ret
+}
+
+// ClipNorm clip gradients by global l2 norm, returns the norm before clipping
+func (t *Tracker) ClipNorm(max float64) float64 {
+	var total float64
+	for _, s := range t.Stats() {
+		total += s.Norm * s.Norm
+	}
+	total = math.Sqrt(total)
+	if total > max {
+		t.scale(func(*param) bool { return true }, max/(total+1e-6))
+	}
+	return total
+}
+
+// ClipLayerNorm clip gradients of each layer by l2 norm of the layer
+func (t *Tracker) ClipLayerNorm(max float64) {
+	for _, s := range t.Stats() {
+		if s.Norm <= max {
+			continue
+		}
+		index := s.Index
+		t.scale(func(p *param) bool { return p.layer == index }, max/(s.Norm+1e-6))
+	}
+}
+
+// ClipValue clip each gradient into [-v, v]
+func (t *Tracker) ClipValue(v float64) {
+	for _, p := range t.params {
+		if p.grad == nil {
+			continue
+		}
+		limit := nnutil.Scalar(v, p.grad)
+		// min(max(g, -v), v)
+		p.grad = p.grad.Sub(p.grad.Sub(limit).Relu()).
+			Add(p.grad.Neg().Sub(limit).Relu())
+	}
+}
+
+// Grads get collected gradients in the order of Params, nil means zero
+func (t *Tracker) Grads() []*tensor.Tensor {
+	ret := make([]*tensor.Tensor, len(t.params))
+	for i, p := range t.params {
+		ret[i] = p.grad
+	}
+	return ret
+}
+
+// SetGrads replace collected gradients, grads are in the order of Params
+func (t *Tracker) SetGrads(grads []*tensor.Tensor) error {
+	if len(grads) != len(t.params) {
+		return fmt.Errorf("expect %d gradients, got %d", len(t.params), len(grads))
+	}
+	for i, p := range t.params {
+		if grads[i] != nil && !nnutil.SameShapes(grads[i].Shapes(), p.t.Shapes()) {
+			return fmt.Errorf("layer %s: param %s: expect shapes %v, got %v",
+				t.names[p.layer], p.name, p.t.Shapes(), grads[i].Shapes())
+		}
+		p.grad = grads[i]
+	}
+	return nil
+}
+
+// Load copy values of params in the order of Params and update layers
+func (t *Tracker) Load(params []*tensor.Tensor) error {
+	if len(params) != len(t.params) {
+		return fmt.Errorf("expect %d params, got %d", len(t.params), len(params))
+	}
+	for i, p := range t.params {
+		if !nnutil.SameShapes(params[i].Shapes(), p.t.Shapes()) {
+			return fmt.Errorf("layer %s: param %s: expect shapes %v, got %v",
+				t.names[p.layer], p.name, p.t.Shapes(), params[i].Shapes())
+		}
+		v := nnutil.Copy(params[i]).ToScalarType(p.t.ScalarType()).ToDevice(p.t.DeviceType())
+		v.SetRequiresGrad(true)
+		p.t = v
+	}
+	return t.attach()
+}
+
+// ZeroGrads drop collected gradients
+func (t *Tracker) ZeroGrads() {
+	for _, p := range t.params {
+		p.grad = nil
+	}
+}
+
+// Step write collected gradients to params, step optimizer with Params and
+// update layers with the updated params
+func (t *Tracker) Step(optm optimizer.Optimizer) error {
+	var params []*tensor.Tensor
+	for _, p := range t.params {
+		if p.grad == nil {
+			continue
+		}
+		// gradient of sum(t*g) is g
+		p.t.Mul(p.grad.ToScalarType(p.t.ScalarType())).Reshape(-1).Sum(0, false).Backward()
+		params = append(params, p.t)
+		p.grad = nil
+	}
+	if len(params) > 0 {
+		optm.Step(params)
+	}
+	return t.attach()
+}
+
+func (t *Tracker) scale(match func(*param) bool, factor float64) {
+	for _, p := range t.params {
+		if p.grad != nil && match(p) {
+			p.grad = p.grad.Mul(nnutil.Scalar(factor, p.grad))
+		}
+	}
+}
+
+func value(t *tensor.Tensor) float64 {
+	return t.ToDevice(consts.KCPU).ToScalarType(consts.KDouble).Float64Value()[0]
+}
@@ -0,0 +1,95 @@
+package grad
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/lwch/gotorch/optimizer"
+	"github.com/lwch/gotorch/tensor"
+	"github.com/lwch/tnn/nn/layer"
+	"github.com/lwch/tnn/nn/net"
+)
+
+func backward(t *testing.T, n *net.Net, x []float32) {
+	y, err := n.Forward(layer.NewContext(true), tensor.FromFloat32(x, tensor.WithShapes(2, 2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	y[0].Reshape(-1).Sum(0, false).Backward()
+}
+
+func TestTracker(t *testing.T) {
+	var n net.Net
+	n.Add(layer.NewLinear("hidden", 2, 3))
+	n.Add(layer.NewLinear("output", 3, 1))
+	tr, err := NewTracker(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backward(t, &n, []float32{1, 2, 3, 4})
+	if err = tr.Collect(); err != nil {
+		t.Fatal(err)
+	}
+	stats := tr.Stats()
+	if len(stats) != 2 || stats[1].Layer != "output" {
+		t.Fatalf("unexpected stats: %v", stats)
+	}
+	// output is x*hidden^T*output^T, gradient of output weight j is
+	// sum of hidden activation j over batch, 4*hidden[j][0] + 6*hidden[j][1]
+	hidden := tr.params[0].t.Float32Value()
+	var matched bool
+	for _, p := range tr.params {
+		if p.layer != 1 || p.name != "w" {
+			continue
+		}
+		matched = true
+		got := p.grad.Float32Value()
+		if len(got) != 3 {
+			t.Fatalf("unexpected gradient: %v", got)
+		}
+		for j := range got {
+			expect := 4*float64(hidden[j*2]) + 6*float64(hidden[j*2+1])
+			if math.Abs(float64(got[j])-expect) > 1e-4*(1+math.Abs(expect)) {
+				t.Fatalf("unexpected gradient of %d: expect %f, got %f", j, expect, got[j])
+			}
+		}
+	}
+	if !matched {
+		t.Fatal("param w of output layer not found")
+	}
+	total := tr.ClipNorm(1e-3)
+	if total <= 1e-3 {
+		t.Fatalf("unexpected norm: %f", total)
+	}
+	var clipped float64
+	for _, s := range tr.Stats() {
+		clipped += s.Norm * s.Norm
+	}
+	if math.Abs(math.Sqrt(clipped)-1e-3) > 1e-6 {
+		t.Fatalf("norm not clipped: %f", math.Sqrt(clipped))
+	}
+	tr.ClipValue(1e-4)
+	for _, s := range tr.Stats() {
+		if s.MaxAbs > 1e-4+1e-9 {
+			t.Fatalf("value not clipped: %f", s.MaxAbs)
+		}
+	}
+	before := tr.Params()[0].Float32Value()
+	if err = tr.Step(optimizer.NewAdam()); err != nil {
+		t.Fatal(err)
+	}
+	if tr.Params()[0].Float32Value()[0] == before[0] {
+		t.Fatal("param not updated")
+	}
+
+	backward(t, &n, []float32{float32(math.NaN()), 2, 3, 4})
+	err = tr.Collect()
+	if err == nil || !strings.Contains(err.Error(), "hidden") {
+		t.Fatalf("expect error of hidden layer, got %v", err)
+	}
+	backward(t, &n, []float32{1, 2, 3, 4})
+	if err = tr.Collect(); err != nil {
+		t.Fatal(err)
+	}
+}
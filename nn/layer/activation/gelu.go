@@ -10,6 +10,8 @@ type GeLU struct {
 	tanh bool
 }
 
+var _ layer.Module = &GeLU{}
+
 func NewGeLU(tanh bool) *GeLU {
 	var layer GeLU
 	layer.base = new("gelu")
@@ -17,7 +19,7 @@ func NewGeLU(tanh bool) *GeLU {
 	return &layer
 }
 
-func LoadGelu(name string, _ map[string]*tensor.Tensor, args map[string]float32) layer.Layer {
+func LoadGelu(name string, _ map[string]*tensor.Tensor, args map[string]float32, _ ...layer.LayerCreateOption) layer.Layer {
 	var layer GeLU
 	layer.base = new("gelu")
 	layer.name = name
@@ -31,6 +33,11 @@ func (layer *GeLU) Forward(x *tensor.Tensor) *tensor.Tensor {
 	return x.Gelu(layer.tanh)
 }
 
+func (l *GeLU) Call(_ *layer.Context, inputs ...*tensor.Tensor) []*tensor.Tensor {
+	layer.CheckInputs(l, inputs, 1)
+	return []*tensor.Tensor{l.Forward(inputs[0])}
+}
+
 func (layer *GeLU) Args() map[string]float32 {
 	var tanh float32
 	if layer.tanh {
@@ -9,13 +9,15 @@ type ReLU struct {
 	*base
 }
 
+var _ layer.Module = &ReLU{}
+
 func NewReLU() *ReLU {
 	var layer ReLU
 	layer.base = new("relu")
 	return &layer
 }
 
-func LoadRelu(name string, _ map[string]*tensor.Tensor, _ map[string]float32) layer.Layer {
+func LoadRelu(name string, _ map[string]*tensor.Tensor, _ map[string]float32, _ ...layer.LayerCreateOption) layer.Layer {
 	var layer ReLU
 	layer.base = new("relu")
 	layer.name = name
@@ -25,3 +27,8 @@ func LoadRelu(name string, _ map[string]*tensor.Tensor, _ map[string]float32) la
 func (layer *ReLU) Forward(x *tensor.Tensor) *tensor.Tensor {
 	return x.Relu()
 }
+
+func (l *ReLU) Call(_ *layer.Context, inputs ...*tensor.Tensor) []*tensor.Tensor {
+	layer.CheckInputs(l, inputs, 1)
+	return []*tensor.Tensor{l.Forward(inputs[0])}
+}
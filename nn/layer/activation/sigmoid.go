@@ -9,13 +9,15 @@ type Sigmoid struct {
 	*base
 }
 
+var _ layer.Module = &Sigmoid{}
+
 func NewSigmoid() *Sigmoid {
 	var layer Sigmoid
 	layer.base = new("sigmoid")
 	return &layer
 }
 
-func LoadSigmoid(name string, _ map[string]*tensor.Tensor, _ map[string]float32) layer.Layer {
+func LoadSigmoid(name string, _ map[string]*tensor.Tensor, _ map[string]float32, _ ...layer.LayerCreateOption) layer.Layer {
 	var layer Sigmoid
 	layer.base = new("sigmoid")
 	layer.name = name
@@ -25,3 +27,8 @@ func LoadSigmoid(name string, _ map[string]*tensor.Tensor, _ map[string]float32)
 func (layer *Sigmoid) Forward(x *tensor.Tensor) *tensor.Tensor {
 	return x.Sigmoid()
 }
+
+func (l *Sigmoid) Call(_ *layer.Context, inputs ...*tensor.Tensor) []*tensor.Tensor {
+	layer.CheckInputs(l, inputs, 1)
+	return []*tensor.Tensor{l.Forward(inputs[0])}
+}
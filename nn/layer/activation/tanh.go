@@ -9,13 +9,15 @@ type Tanh struct {
 	*base
 }
 
+var _ layer.Module = &Tanh{}
+
 func NewTanh() *Tanh {
 	var layer Tanh
 	layer.base = new("tanh")
 	return &layer
 }
 
-func LoadTanh(name string, _ map[string]*tensor.Tensor, _ map[string]float32) layer.Layer {
+func LoadTanh(name string, _ map[string]*tensor.Tensor, _ map[string]float32, _ ...layer.LayerCreateOption) layer.Layer {
 	var layer Tanh
 	layer.base = new("tanh")
 	layer.name = name
@@ -25,3 +27,8 @@ func LoadTanh(name string, _ map[string]*tensor.Tensor, _ map[string]float32) la
 func (layer *Tanh) Forward(x *tensor.Tensor) *tensor.Tensor {
 	return x.Tanh()
 }
+
+func (l *Tanh) Call(_ *layer.Context, inputs ...*tensor.Tensor) []*tensor.Tensor {
+	layer.CheckInputs(l, inputs, 1)
+	return []*tensor.Tensor{l.Forward(inputs[0])}
+}
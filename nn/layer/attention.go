@@ -20,6 +20,8 @@ type Attention struct {
 	freqs *tensor.Tensor
 }
 
+var _ Module = &Attention{}
+
 func NewAttention(name string, dims, heads int, dropout float64, rope bool, opts ...LayerCreateOption) *Attention {
 	var layer Attention
 	layer.new("attention", name, opts...)
@@ -36,9 +38,14 @@ func NewAttention(name string, dims, heads int, dropout float64, rope bool, opts
 	return &layer
 }
 
-func LoadAttention(name string, params map[string]*tensor.Tensor, args map[string]float32) Layer {
+func LoadAttention(name string, params map[string]*tensor.Tensor, args map[string]float32, opts ...LayerCreateOption) Layer {
 	var layer Attention
-	layer.new("attention", name)
+	layer.load("attention", name, params, args, opts...)
+	return &layer
+}
+
+func (layer *Attention) load(class, name string, params map[string]*tensor.Tensor, args map[string]float32, opts ...LayerCreateOption) {
+	layer.new(class, name, opts...)
 	layer.dims = int(args["dims"])
 	layer.heads = int(args["heads"])
 	layer.dropout = float64(args["dropout"])
@@ -49,7 +56,6 @@ func LoadAttention(name string, params map[string]*tensor.Tensor, args map[strin
 	}
 	layer.w = params["w"]
 	layer.scale = layer.initN(math.Sqrt(float64(layer.dims)))
-	return &layer
 }
 
 func (layer *Attention) SetROPEBase(n int64) {
@@ -57,12 +63,16 @@ func (layer *Attention) SetROPEBase(n int64) {
 }
 
 func (layer *Attention) Forward(q, k, v, mask *tensor.Tensor, isCausal, train bool) *tensor.Tensor {
+	return layer.forward(layer.w, q, k, v, mask, isCausal, train)
+}
+
+func (layer *Attention) forward(w, q, k, v, mask *tensor.Tensor, isCausal, train bool) *tensor.Tensor {
 	if mask != nil && isCausal {
 		panic("unexpected mask")
 	}
 	inputShape := q.Shapes()
 	x := tensor.Cat([]*tensor.Tensor{q, k, v}, -1)           // (batch, seq, dims*3)
-	x = x.MatMul(layer.w.Transpose(0, 1))                    // (batch, seq, dims*3)
+	x = x.MatMul(w.Transpose(0, 1))                          // (batch, seq, dims*3)
 	q = x.NArrow(-1, 0, int64(layer.dims))                   // (batch, seq, dims)
 	k = x.NArrow(-1, int64(layer.dims), int64(layer.dims))   // (batch, seq, dims)
 	v = x.NArrow(-1, int64(layer.dims*2), int64(layer.dims)) // (batch, seq, dims)
@@ -85,12 +95,22 @@ func (layer *Attention) Forward(q, k, v, mask *tensor.Tensor, isCausal, train bo
 	return y
 }
 
+// Call inputs: q[, k, v[, mask]], q is used as k and v when only one input given
+func (layer *Attention) Call(ctx *Context, inputs ...*tensor.Tensor) []*tensor.Tensor {
+	q, k, v, mask := attentionInputs(layer, inputs)
+	return []*tensor.Tensor{layer.Forward(q, k, v, mask, mask == nil && ctx.causal(), ctx.train())}
+}
+
 func (layer *Attention) Score(q, k, v, mask *tensor.Tensor, isCausal, train bool) *tensor.Tensor {
+	return layer.score(layer.w, q, k, v, mask, isCausal)
+}
+
+func (layer *Attention) score(w, q, k, v, mask *tensor.Tensor, isCausal bool) *tensor.Tensor {
 	if mask != nil && isCausal {
 		panic("unexpected mask")
 	}
 	x := tensor.Cat([]*tensor.Tensor{q, k, v}, -1)         // (batch, seq, dims*3)
-	x = x.MatMul(layer.w)                                  // (batch, seq, dims*3)
+	x = x.MatMul(w)                                        // (batch, seq, dims*3)
 	q = x.NArrow(-1, 0, int64(layer.dims))                 // (batch, seq, dims)
 	k = x.NArrow(-1, int64(layer.dims), int64(layer.dims)) // (batch, seq, dims)
 	q = layer.split(q)                                     // (batch, seq, heads, dims/heads)
@@ -188,9 +208,11 @@ func (layer *Attention) Args() map[string]float32 {
 }
 
 func (layer *Attention) Freeze() {
+	layer.frozen = true
 	layer.w.SetRequiresGrad(false)
 }
 
 func (layer *Attention) Unfreeze() {
+	layer.frozen = false
 	layer.w.SetRequiresGrad(true)
 }
@@ -20,6 +20,8 @@ type Attention1 struct {
 	freqs *tensor.Tensor
 }
 
+var _ Module = &Attention1{}
+
 func NewAttention1(name string, dims, heads int, dropout float64, rope bool, opts ...LayerCreateOption) *Attention1 {
 	var layer Attention1
 	layer.new("attention1", name, opts...)
@@ -36,9 +38,9 @@ func NewAttention1(name string, dims, heads int, dropout float64, rope bool, opt
 	return &layer
 }
 
-func LoadAttention1(name string, params map[string]*tensor.Tensor, args map[string]float32) Layer {
+func LoadAttention1(name string, params map[string]*tensor.Tensor, args map[string]float32, opts ...LayerCreateOption) Layer {
 	var layer Attention1
-	layer.new("attention1", name)
+	layer.new("attention1", name, opts...)
 	layer.dims = int(args["dims"])
 	layer.heads = int(args["heads"])
 	layer.dropout = float64(args["dropout"])
@@ -89,6 +91,12 @@ func (layer *Attention1) Forward(q, k, v, mask *tensor.Tensor, isCausal, train b
 	return y
 }
 
+// Call inputs: q[, k, v[, mask]], q is used as k and v when only one input given
+func (layer *Attention1) Call(ctx *Context, inputs ...*tensor.Tensor) []*tensor.Tensor {
+	q, k, v, mask := attentionInputs(layer, inputs)
+	return []*tensor.Tensor{layer.Forward(q, k, v, mask, mask == nil && ctx.causal(), ctx.train())}
+}
+
 func (layer *Attention1) Score(q, k, v, mask *tensor.Tensor, isCausal, train bool) *tensor.Tensor {
 	if mask != nil && isCausal {
 		panic("unexpected mask")
@@ -151,7 +159,7 @@ func buildCausal(q, k *tensor.Tensor, device consts.DeviceType) *tensor.Tensor {
 	}
 	return tensor.FromFloat32(mask,
 		tensor.WithShapes(1, 1, l, s),
-		tensor.WithDevice(device))
+		tensor.WithDevice(device)).ToScalarType(q.ScalarType())
 }
 
 func (layer *Attention1) Params() map[string]*tensor.Tensor {
@@ -175,9 +183,25 @@ func (layer *Attention1) Args() map[string]float32 {
 }
 
 func (layer *Attention1) Freeze() {
+	layer.frozen = true
 	layer.w.SetRequiresGrad(false)
 }
 
 func (layer *Attention1) Unfreeze() {
+	layer.frozen = false
 	layer.w.SetRequiresGrad(true)
 }
+
+func attentionInputs(layer Layer, inputs []*tensor.Tensor) (*tensor.Tensor, *tensor.Tensor, *tensor.Tensor, *tensor.Tensor) {
+	CheckInputs(layer, inputs, 1)
+	switch len(inputs) {
+	case 1:
+		return inputs[0], inputs[0], inputs[0], nil
+	case 2:
+		panic(&InputError{layer.Class(), layer.Name(), "expect 1, 3 or 4 inputs, got 2"})
+	case 3:
+		return inputs[0], inputs[1], inputs[2], nil
+	default:
+		return inputs[0], inputs[1], inputs[2], inputs[3]
+	}
+}
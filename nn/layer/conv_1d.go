@@ -16,6 +16,8 @@ type Conv1D struct {
 	w *tensor.Tensor
 }
 
+var _ Module = &Conv1D{}
+
 func NewConv1D(name string, inC, outC, kernel int, opts ...LayerCreateOption) *Conv1D {
 	var layer Conv1D
 	layer.new("conv1d", name, opts...)
@@ -46,9 +48,9 @@ func (layer *Conv1D) SetGroups(groups int) {
 	layer.groups = groups
 }
 
-func LoadConv1D(name string, params map[string]*tensor.Tensor, args map[string]float32) Layer {
+func LoadConv1D(name string, params map[string]*tensor.Tensor, args map[string]float32, opts ...LayerCreateOption) Layer {
 	var layer Conv1D
-	layer.new("conv1d", name)
+	layer.new("conv1d", name, opts...)
 	layer.inC = int(args["inC"])
 	layer.outC = int(args["outC"])
 	layer.kernel = int(args["kernel"])
@@ -68,6 +70,11 @@ func (layer *Conv1D) Forward(x *tensor.Tensor) *tensor.Tensor {
 		tensor.Conv1DGroups(layer.groups))
 }
 
+func (layer *Conv1D) Call(_ *Context, inputs ...*tensor.Tensor) []*tensor.Tensor {
+	CheckInputs(layer, inputs, 1)
+	return []*tensor.Tensor{layer.Forward(inputs[0])}
+}
+
 func (layer *Conv1D) Params() map[string]*tensor.Tensor {
 	return map[string]*tensor.Tensor{
 		"w": layer.w,
@@ -87,9 +94,11 @@ func (layer *Conv1D) Args() map[string]float32 {
 }
 
 func (layer *Conv1D) Freeze() {
+	layer.frozen = true
 	layer.w.SetRequiresGrad(false)
 }
 
 func (layer *Conv1D) Unfreeze() {
+	layer.frozen = false
 	layer.w.SetRequiresGrad(true)
 }
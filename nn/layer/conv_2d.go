@@ -17,6 +17,8 @@ type Conv2D struct {
 	b *tensor.Tensor
 }
 
+var _ Module = &Conv2D{}
+
 func NewConv2D(name string, inC, outC int, kernel1, kernel2 int, opts ...LayerCreateOption) *Conv2D {
 	var layer Conv2D
 	layer.new("conv2d", name, opts...)
@@ -48,9 +50,9 @@ func (layer *Conv2D) SetGroups(groups int) {
 	layer.groups = groups
 }
 
-func LoadConv2D(name string, params map[string]*tensor.Tensor, args map[string]float32) Layer {
+func LoadConv2D(name string, params map[string]*tensor.Tensor, args map[string]float32, opts ...LayerCreateOption) Layer {
 	var layer Conv2D
-	layer.new("conv2d", name)
+	layer.new("conv2d", name, opts...)
 	layer.inC = int(args["inC"])
 	layer.outC = int(args["outC"])
 	layer.kernel = [2]int{int(args["kernel1"]), int(args["kernel2"])}
@@ -71,6 +73,11 @@ func (layer *Conv2D) Forward(x *tensor.Tensor) *tensor.Tensor {
 		tensor.Conv2DGroups(layer.groups))
 }
 
+func (layer *Conv2D) Call(_ *Context, inputs ...*tensor.Tensor) []*tensor.Tensor {
+	CheckInputs(layer, inputs, 1)
+	return []*tensor.Tensor{layer.Forward(inputs[0])}
+}
+
 func (layer *Conv2D) Params() map[string]*tensor.Tensor {
 	return map[string]*tensor.Tensor{
 		"w": layer.w,
@@ -94,11 +101,13 @@ func (layer *Conv2D) Args() map[string]float32 {
 }
 
 func (layer *Conv2D) Freeze() {
+	layer.frozen = true
 	layer.w.SetRequiresGrad(false)
 	layer.b.SetRequiresGrad(false)
 }
 
 func (layer *Conv2D) Unfreeze() {
+	layer.frozen = false
 	layer.w.SetRequiresGrad(true)
 	layer.b.SetRequiresGrad(true)
 }
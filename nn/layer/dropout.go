@@ -9,6 +9,8 @@ type Dropout struct {
 	keep float64
 }
 
+var _ Module = &Dropout{}
+
 func NewDropout(name string, keep float64) *Dropout {
 	var layer Dropout
 	layer.new("dropout", name)
@@ -16,9 +18,9 @@ func NewDropout(name string, keep float64) *Dropout {
 	return &layer
 }
 
-func LoadDropout(name string, _ map[string]*tensor.Tensor, args map[string]float32) Layer {
+func LoadDropout(name string, _ map[string]*tensor.Tensor, args map[string]float32, opts ...LayerCreateOption) Layer {
 	var layer Dropout
-	layer.new("dropout", name)
+	layer.new("dropout", name, opts...)
 	layer.keep = float64(args["keep"])
 	return &layer
 }
@@ -27,6 +29,11 @@ func (layer *Dropout) Forward(x *tensor.Tensor, train bool) *tensor.Tensor {
 	return x.Dropout(layer.keep, train)
 }
 
+func (layer *Dropout) Call(ctx *Context, inputs ...*tensor.Tensor) []*tensor.Tensor {
+	CheckInputs(layer, inputs, 1)
+	return []*tensor.Tensor{layer.Forward(inputs[0], ctx.train())}
+}
+
 func (layer *Dropout) Args() map[string]float32 {
 	return map[string]float32{
 		"keep": float32(layer.keep),
@@ -13,6 +13,8 @@ type Embedding struct {
 	w *tensor.Tensor
 }
 
+var _ Module = &Embedding{}
+
 func NewEmbedding(name string, num, dim int, opts ...LayerCreateOption) *Embedding {
 	var layer Embedding
 	layer.new("embedding", name, opts...)
@@ -23,9 +25,9 @@ func NewEmbedding(name string, num, dim int, opts ...LayerCreateOption) *Embeddi
 	return &layer
 }
 
-func LoadEmbedding(name string, params map[string]*tensor.Tensor, args map[string]float32) Layer {
+func LoadEmbedding(name string, params map[string]*tensor.Tensor, args map[string]float32, opts ...LayerCreateOption) Layer {
 	var layer Embedding
-	layer.new("embedding", name)
+	layer.new("embedding", name, opts...)
 	layer.num = int(args["num"])
 	layer.dim = int(args["dim"])
 	layer.padding = int64(args["padding"])
@@ -41,6 +43,11 @@ func (layer *Embedding) Forward(x *tensor.Tensor) *tensor.Tensor {
 	return tensor.Embedding(x, layer.w, layer.padding)
 }
 
+func (layer *Embedding) Call(_ *Context, inputs ...*tensor.Tensor) []*tensor.Tensor {
+	CheckInputs(layer, inputs, 1)
+	return []*tensor.Tensor{layer.Forward(inputs[0])}
+}
+
 func (layer *Embedding) Params() map[string]*tensor.Tensor {
 	return map[string]*tensor.Tensor{
 		"w": layer.w,
@@ -56,9 +63,11 @@ func (layer *Embedding) Args() map[string]float32 {
 }
 
 func (layer *Embedding) Freeze() {
+	layer.frozen = true
 	layer.w.SetRequiresGrad(false)
 }
 
 func (layer *Embedding) Unfreeze() {
+	layer.frozen = false
 	layer.w.SetRequiresGrad(true)
 }
@@ -8,15 +8,17 @@ type Flatten struct {
 	base
 }
 
+var _ Module = &Flatten{}
+
 func NewFlatten(name string) *Flatten {
 	var layer Flatten
 	layer.new("flatten", name)
 	return &layer
 }
 
-func LoadFlatten(name string, _ map[string]*tensor.Tensor, _ map[string]float32) Layer {
+func LoadFlatten(name string, _ map[string]*tensor.Tensor, _ map[string]float32, opts ...LayerCreateOption) Layer {
 	var layer Flatten
-	layer.new("flatten", name)
+	layer.new("flatten", name, opts...)
 	return &layer
 }
 
@@ -28,3 +30,8 @@ func (layer *Flatten) Forward(x *tensor.Tensor) *tensor.Tensor {
 	}
 	return x.Reshape(shape[0], cols)
 }
+
+func (layer *Flatten) Call(_ *Context, inputs ...*tensor.Tensor) []*tensor.Tensor {
+	CheckInputs(layer, inputs, 1)
+	return []*tensor.Tensor{layer.Forward(inputs[0])}
+}
@@ -25,6 +25,7 @@ type base struct {
 	class     string
 	device    consts.DeviceType
 	paramType consts.ScalarType
+	frozen    bool
 }
 
 type LayerCreateOption func(*base)
@@ -75,6 +76,14 @@ func (b *base) Name() string {
 	return b.name
 }
 
+func (b *base) Device() consts.DeviceType {
+	return b.device
+}
+
+func (b *base) ParamType() consts.ScalarType {
+	return b.paramType
+}
+
 func (b *base) Params() map[string]*tensor.Tensor {
 	return nil
 }
@@ -186,6 +195,11 @@ func (b *base) Freeze() {
 	panic("not implemented")
 }
 
+// Frozen check whether params of layer are frozen by Freeze
+func (b *base) Frozen() bool {
+	return b.frozen
+}
+
 func (b *base) Unfreeze() {
 	panic("not implemented")
 }
@@ -11,6 +11,8 @@ type LayerNorm struct {
 	a *tensor.Tensor
 }
 
+var _ Module = &LayerNorm{}
+
 func NewLayerNorm(name string, dims int64, opts ...LayerCreateOption) *LayerNorm {
 	var layer LayerNorm
 	layer.new("layer_norm", name, opts...)
@@ -20,9 +22,9 @@ func NewLayerNorm(name string, dims int64, opts ...LayerCreateOption) *LayerNorm
 	return &layer
 }
 
-func LoadLayerNorm(name string, params map[string]*tensor.Tensor, args map[string]float32) Layer {
+func LoadLayerNorm(name string, params map[string]*tensor.Tensor, args map[string]float32, opts ...LayerCreateOption) Layer {
 	var layer LayerNorm
-	layer.new("layer_norm", name)
+	layer.new("layer_norm", name, opts...)
 	layer.eps = layer.initN(1e-9)
 	layer.a = params["a"]
 	return &layer
@@ -37,6 +39,11 @@ func (layer *LayerNorm) Forward(x *tensor.Tensor) *tensor.Tensor {
 	return div.Mul(layer.a)
 }
 
+func (layer *LayerNorm) Call(_ *Context, inputs ...*tensor.Tensor) []*tensor.Tensor {
+	CheckInputs(layer, inputs, 1)
+	return []*tensor.Tensor{layer.Forward(inputs[0])}
+}
+
 func (layer *LayerNorm) Params() map[string]*tensor.Tensor {
 	return map[string]*tensor.Tensor{
 		"a": layer.a,
@@ -44,9 +51,11 @@ func (layer *LayerNorm) Params() map[string]*tensor.Tensor {
 }
 
 func (layer *LayerNorm) Freeze() {
+	layer.frozen = true
 	layer.a.SetRequiresGrad(false)
 }
 
 func (layer *LayerNorm) Unfreeze() {
+	layer.frozen = false
 	layer.a.SetRequiresGrad(true)
 }
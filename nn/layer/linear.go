@@ -11,6 +11,8 @@ type Linear struct {
 	w *tensor.Tensor
 }
 
+var _ Module = &Linear{}
+
 func NewLinear(name string, input, output int, opts ...LayerCreateOption) *Linear {
 	var layer Linear
 	layer.new("linear", name, opts...)
@@ -19,9 +21,9 @@ func NewLinear(name string, input, output int, opts ...LayerCreateOption) *Linea
 	return &layer
 }
 
-func LoadLinear(name string, params map[string]*tensor.Tensor, args map[string]float32) Layer {
+func LoadLinear(name string, params map[string]*tensor.Tensor, args map[string]float32, opts ...LayerCreateOption) Layer {
 	var layer Linear
-	layer.new("linear", name)
+	layer.new("linear", name, opts...)
 	layer.output = int(args["output"])
 	layer.w = params["w"]
 	return &layer
@@ -31,6 +33,11 @@ func (layer *Linear) Forward(x *tensor.Tensor) *tensor.Tensor {
 	return x.MatMul(layer.w.Transpose(0, 1))
 }
 
+func (layer *Linear) Call(_ *Context, inputs ...*tensor.Tensor) []*tensor.Tensor {
+	CheckInputs(layer, inputs, 1)
+	return []*tensor.Tensor{layer.Forward(inputs[0])}
+}
+
 func (layer *Linear) Params() map[string]*tensor.Tensor {
 	return map[string]*tensor.Tensor{
 		"w": layer.w,
@@ -44,9 +51,11 @@ func (layer *Linear) Args() map[string]float32 {
 }
 
 func (layer *Linear) Freeze() {
+	layer.frozen = true
 	layer.w.SetRequiresGrad(false)
 }
 
 func (layer *Linear) Unfreeze() {
+	layer.frozen = false
 	layer.w.SetRequiresGrad(true)
 }
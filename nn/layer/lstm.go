@@ -1,7 +1,6 @@
 package layer
 
 import (
-	"github.com/lwch/gotorch/consts"
 	"github.com/lwch/gotorch/tensor"
 )
 
@@ -15,6 +14,8 @@ type Lstm struct {
 	Wo, Bo             *tensor.Tensor
 }
 
+var _ Module = &Lstm{}
+
 func NewLstm(name string, featureSize, steps, hidden int, opts ...LayerCreateOption) *Lstm {
 	var layer Lstm
 	layer.new("lstm", name, opts...)
@@ -32,9 +33,9 @@ func NewLstm(name string, featureSize, steps, hidden int, opts ...LayerCreateOpt
 	return &layer
 }
 
-func LoadLstm(name string, params map[string]*tensor.Tensor, args map[string]float32) Layer {
+func LoadLstm(name string, params map[string]*tensor.Tensor, args map[string]float32, opts ...LayerCreateOption) Layer {
 	var layer Lstm
-	layer.new("lstm", name)
+	layer.new("lstm", name, opts...)
 	layer.featureSize = int(args["feature_size"])
 	layer.steps = int(args["steps"])
 	layer.hidden = int(args["hidden"])
@@ -52,12 +53,12 @@ func LoadLstm(name string, params map[string]*tensor.Tensor, args map[string]flo
 func (layer *Lstm) Forward(x, h, c *tensor.Tensor) (*tensor.Tensor, *tensor.Tensor, *tensor.Tensor) {
 	inputShape := x.Shapes()
 	if h == nil {
-		h = tensor.Zeros(consts.KFloat,
+		h = tensor.Zeros(layer.paramType,
 			tensor.WithShapes(int64(inputShape[0]), int64(layer.hidden)),
 			tensor.WithDevice(layer.device))
 	}
 	if c == nil {
-		c = tensor.Zeros(consts.KFloat,
+		c = tensor.Zeros(layer.paramType,
 			tensor.WithShapes(int64(inputShape[0]), int64(layer.hidden)),
 			tensor.WithDevice(layer.device))
 	}
@@ -90,6 +91,20 @@ func (layer *Lstm) Forward(x, h, c *tensor.Tensor) (*tensor.Tensor, *tensor.Tens
 		copyState(layer.name+".cell", c)
 }
 
+// Call inputs: x[, h[, c]], outputs: y, h, c
+func (layer *Lstm) Call(_ *Context, inputs ...*tensor.Tensor) []*tensor.Tensor {
+	CheckInputs(layer, inputs, 1)
+	var h, c *tensor.Tensor
+	if len(inputs) > 1 {
+		h = inputs[1]
+	}
+	if len(inputs) > 2 {
+		c = inputs[2]
+	}
+	y, h, c := layer.Forward(inputs[0], h, c)
+	return []*tensor.Tensor{y, h, c}
+}
+
 func (layer *Lstm) Params() map[string]*tensor.Tensor {
 	return map[string]*tensor.Tensor{
 		"Wi": layer.Wi,
@@ -112,6 +127,7 @@ func (layer *Lstm) Args() map[string]float32 {
 }
 
 func (layer *Lstm) Freeze() {
+	layer.frozen = true
 	layer.Wi.SetRequiresGrad(false)
 	layer.Wf.SetRequiresGrad(false)
 	layer.Wg.SetRequiresGrad(false)
@@ -123,6 +139,7 @@ func (layer *Lstm) Freeze() {
 }
 
 func (layer *Lstm) Unfreeze() {
+	layer.frozen = false
 	layer.Wi.SetRequiresGrad(true)
 	layer.Wf.SetRequiresGrad(true)
 	layer.Wg.SetRequiresGrad(true)
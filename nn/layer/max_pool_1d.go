@@ -13,6 +13,8 @@ type MaxPool1D struct {
 	ceil     bool
 }
 
+var _ Module = &MaxPool1D{}
+
 func NewMaxPool1D(name string, kernel int, opts ...LayerCreateOption) *MaxPool1D {
 	var layer MaxPool1D
 	layer.new("maxpool1d", name, opts...)
@@ -40,9 +42,9 @@ func (layer *MaxPool1D) SetCeil(ceil bool) {
 	layer.ceil = ceil
 }
 
-func LoadMaxPool1D(name string, _ map[string]*tensor.Tensor, args map[string]float32) Layer {
+func LoadMaxPool1D(name string, _ map[string]*tensor.Tensor, args map[string]float32, opts ...LayerCreateOption) Layer {
 	var layer MaxPool1D
-	layer.new("maxpool1d", name)
+	layer.new("maxpool1d", name, opts...)
 	layer.kernel = int(args["kernel"])
 	layer.stride = int(args["stride"])
 	layer.padding = int(args["padding"])
@@ -62,6 +64,11 @@ func (layer *MaxPool1D) Forward(x *tensor.Tensor) *tensor.Tensor {
 		tensor.PoolCeil(layer.ceil))
 }
 
+func (layer *MaxPool1D) Call(_ *Context, inputs ...*tensor.Tensor) []*tensor.Tensor {
+	CheckInputs(layer, inputs, 1)
+	return []*tensor.Tensor{layer.Forward(inputs[0])}
+}
+
 func (layer *MaxPool1D) Args() map[string]float32 {
 	var ceil float32
 	if layer.ceil {
@@ -0,0 +1,55 @@
+package layer
+
+import (
+	"fmt"
+
+	"github.com/lwch/gotorch/tensor"
+)
+
+// Context forward context
+type Context struct {
+	// Train run forward in train mode, dropout etc. only works in train mode
+	Train bool
+	// Causal use causal mask in attention layers when no mask given
+	Causal bool
+}
+
+// NewContext create forward context
+func NewContext(train bool) *Context {
+	return &Context{Train: train}
+}
+
+func (ctx *Context) train() bool {
+	return ctx != nil && ctx.Train
+}
+
+func (ctx *Context) causal() bool {
+	return ctx != nil && ctx.Causal
+}
+
+// Module layer with uniform forward contract,
+// inputs are the outputs of the previous module.
+type Module interface {
+	Layer
+	Call(ctx *Context, inputs ...*tensor.Tensor) []*tensor.Tensor
+}
+
+// InputError invalid inputs of layer, Call panics with it and Forward of
+// net returns it as error
+type InputError struct {
+	Class string
+	Name  string
+	Msg   string
+}
+
+func (e *InputError) Error() string {
+	return fmt.Sprintf("%s layer %s: %s", e.Class, e.Name, e.Msg)
+}
+
+// CheckInputs panic with InputError when inputs count less than n
+func CheckInputs(l Layer, inputs []*tensor.Tensor, n int) {
+	if len(inputs) < n {
+		panic(&InputError{l.Class(), l.Name(),
+			fmt.Sprintf("expect at least %d inputs, got %d", n, len(inputs))})
+	}
+}
@@ -0,0 +1,60 @@
+package layer
+
+import (
+	"github.com/lwch/gotorch/tensor"
+)
+
+// Q4Attention attention layer with 4 bits grouped weight, created by quantizing Attention
+type Q4Attention struct {
+	Attention
+	packed grouped
+}
+
+var _ Module = &Q4Attention{}
+var _ PackedLayer = &Q4Attention{}
+
+func LoadQ4Attention(name string, params map[string]*tensor.Tensor, args map[string]float32, opts ...LayerCreateOption) Layer {
+	var layer Q4Attention
+	layer.Attention.load("q4attention", name, params, args, opts...)
+	layer.Attention.w = nil
+	layer.packed.load(params, args)
+	return &layer
+}
+
+func (layer *Q4Attention) Forward(q, k, v, mask *tensor.Tensor, isCausal, train bool) *tensor.Tensor {
+	return layer.forward(layer.packed.dequantize(), q, k, v, mask, isCausal, train)
+}
+
+// Call inputs: q[, k, v[, mask]], q is used as k and v when only one input given
+func (layer *Q4Attention) Call(ctx *Context, inputs ...*tensor.Tensor) []*tensor.Tensor {
+	q, k, v, mask := attentionInputs(layer, inputs)
+	return []*tensor.Tensor{layer.Forward(q, k, v, mask, mask == nil && ctx.causal(), ctx.train())}
+}
+
+func (layer *Q4Attention) Score(q, k, v, mask *tensor.Tensor, isCausal, train bool) *tensor.Tensor {
+	return layer.score(layer.packed.dequantize(), q, k, v, mask, isCausal)
+}
+
+func (layer *Q4Attention) Params() map[string]*tensor.Tensor {
+	return layer.packed.params()
+}
+
+func (layer *Q4Attention) Packings() map[string]Packing {
+	return layer.packed.packings()
+}
+
+func (layer *Q4Attention) Args() map[string]float32 {
+	args := layer.Attention.Args()
+	args["group"] = float32(layer.packed.group)
+	return args
+}
+
+func (layer *Q4Attention) Freeze() {
+	layer.frozen = true
+	layer.packed.freeze()
+}
+
+func (layer *Q4Attention) Unfreeze() {
+	layer.frozen = false
+	layer.packed.unfreeze()
+}
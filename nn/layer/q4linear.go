@@ -0,0 +1,57 @@
+package layer
+
+import (
+	"github.com/lwch/gotorch/tensor"
+)
+
+// Q4Linear linear layer with 4 bits grouped weight, created by quantizing Linear
+type Q4Linear struct {
+	base
+	grouped
+	output int
+}
+
+var _ Module = &Q4Linear{}
+var _ PackedLayer = &Q4Linear{}
+
+func LoadQ4Linear(name string, params map[string]*tensor.Tensor, args map[string]float32, opts ...LayerCreateOption) Layer {
+	var layer Q4Linear
+	layer.new("q4linear", name, opts...)
+	layer.output = int(args["output"])
+	layer.load(params, args)
+	return &layer
+}
+
+func (layer *Q4Linear) Forward(x *tensor.Tensor) *tensor.Tensor {
+	return x.MatMul(layer.dequantize().Transpose(0, 1))
+}
+
+func (layer *Q4Linear) Call(_ *Context, inputs ...*tensor.Tensor) []*tensor.Tensor {
+	CheckInputs(layer, inputs, 1)
+	return []*tensor.Tensor{layer.Forward(inputs[0])}
+}
+
+func (layer *Q4Linear) Params() map[string]*tensor.Tensor {
+	return layer.params()
+}
+
+func (layer *Q4Linear) Packings() map[string]Packing {
+	return layer.packings()
+}
+
+func (layer *Q4Linear) Args() map[string]float32 {
+	return map[string]float32{
+		"output": float32(layer.output),
+		"group":  float32(layer.group),
+	}
+}
+
+func (layer *Q4Linear) Freeze() {
+	layer.frozen = true
+	layer.freeze()
+}
+
+func (layer *Q4Linear) Unfreeze() {
+	layer.frozen = false
+	layer.unfreeze()
+}
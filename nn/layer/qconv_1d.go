@@ -0,0 +1,72 @@
+package layer
+
+import (
+	"github.com/lwch/gotorch/tensor"
+)
+
+// QConv1D conv1d layer with int8 weight, created by quantizing Conv1D
+type QConv1D struct {
+	base
+	quantized
+	inC, outC int
+	kernel    int
+	stride    int
+	padding   int
+	dilation  int
+	groups    int
+}
+
+var _ Module = &QConv1D{}
+
+func LoadQConv1D(name string, params map[string]*tensor.Tensor, args map[string]float32, opts ...LayerCreateOption) Layer {
+	var layer QConv1D
+	layer.new("qconv1d", name, opts...)
+	layer.inC = int(args["inC"])
+	layer.outC = int(args["outC"])
+	layer.kernel = int(args["kernel"])
+	layer.stride = int(args["stride"])
+	layer.padding = int(args["padding"])
+	layer.dilation = int(args["dilation"])
+	layer.groups = int(args["groups"])
+	layer.load(params)
+	return &layer
+}
+
+func (layer *QConv1D) Forward(x *tensor.Tensor) *tensor.Tensor {
+	return x.Conv1D(layer.dequantize(), nil,
+		tensor.Conv1DStride(layer.stride),
+		tensor.Conv1DPadding(layer.padding),
+		tensor.Conv1DDilation(layer.dilation),
+		tensor.Conv1DGroups(layer.groups))
+}
+
+func (layer *QConv1D) Call(_ *Context, inputs ...*tensor.Tensor) []*tensor.Tensor {
+	CheckInputs(layer, inputs, 1)
+	return []*tensor.Tensor{layer.Forward(inputs[0])}
+}
+
+func (layer *QConv1D) Params() map[string]*tensor.Tensor {
+	return layer.params()
+}
+
+func (layer *QConv1D) Args() map[string]float32 {
+	return map[string]float32{
+		"inC":      float32(layer.inC),
+		"outC":     float32(layer.outC),
+		"kernel":   float32(layer.kernel),
+		"stride":   float32(layer.stride),
+		"padding":  float32(layer.padding),
+		"dilation": float32(layer.dilation),
+		"groups":   float32(layer.groups),
+	}
+}
+
+func (layer *QConv1D) Freeze() {
+	layer.frozen = true
+	layer.freeze()
+}
+
+func (layer *QConv1D) Unfreeze() {
+	layer.frozen = false
+	layer.unfreeze()
+}
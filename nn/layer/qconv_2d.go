@@ -0,0 +1,83 @@
+package layer
+
+import (
+	"github.com/lwch/gotorch/tensor"
+)
+
+// QConv2D conv2d layer with int8 weight, created by quantizing Conv2D,
+// bias is kept in float type
+type QConv2D struct {
+	base
+	quantized
+	inC, outC int
+	kernel    [2]int
+	stride    [2]int
+	padding   [2]int
+	dilation  int
+	groups    int
+	// params
+	b *tensor.Tensor
+}
+
+var _ Module = &QConv2D{}
+
+func LoadQConv2D(name string, params map[string]*tensor.Tensor, args map[string]float32, opts ...LayerCreateOption) Layer {
+	var layer QConv2D
+	layer.new("qconv2d", name, opts...)
+	layer.inC = int(args["inC"])
+	layer.outC = int(args["outC"])
+	layer.kernel = [2]int{int(args["kernel1"]), int(args["kernel2"])}
+	layer.stride = [2]int{int(args["stride1"]), int(args["stride2"])}
+	layer.padding = [2]int{int(args["padding1"]), int(args["padding2"])}
+	layer.dilation = int(args["dilation"])
+	layer.groups = int(args["groups"])
+	layer.load(params)
+	layer.b = params["b"]
+	return &layer
+}
+
+func (layer *QConv2D) Forward(x *tensor.Tensor) *tensor.Tensor {
+	return x.Conv2D(layer.dequantize(), layer.b,
+		tensor.Conv2DStride(layer.stride[0], layer.stride[1]),
+		tensor.Conv2DPadding(layer.padding[0], layer.padding[1]),
+		tensor.Conv2DDilation(layer.dilation),
+		tensor.Conv2DGroups(layer.groups))
+}
+
+func (layer *QConv2D) Call(_ *Context, inputs ...*tensor.Tensor) []*tensor.Tensor {
+	CheckInputs(layer, inputs, 1)
+	return []*tensor.Tensor{layer.Forward(inputs[0])}
+}
+
+func (layer *QConv2D) Params() map[string]*tensor.Tensor {
+	params := layer.params()
+	params["b"] = layer.b
+	return params
+}
+
+func (layer *QConv2D) Args() map[string]float32 {
+	return map[string]float32{
+		"inC":      float32(layer.inC),
+		"outC":     float32(layer.outC),
+		"kernel1":  float32(layer.kernel[0]),
+		"kernel2":  float32(layer.kernel[1]),
+		"stride1":  float32(layer.stride[0]),
+		"stride2":  float32(layer.stride[1]),
+		"padding1": float32(layer.padding[0]),
+		"padding2": float32(layer.padding[1]),
+		"dilation": float32(layer.dilation),
+		"groups":   float32(layer.groups),
+	}
+}
+
+func (layer *QConv2D) Freeze() {
+	layer.frozen = true
+	layer.freeze()
+	layer.b.SetRequiresGrad(false)
+}
+
+func (layer *QConv2D) Unfreeze() {
+	layer.frozen = false
+	layer.unfreeze()
+	layer.b.SetRequiresGrad(true)
+}
@@ -0,0 +1,63 @@
+package layer
+
+import (
+	"github.com/lwch/gotorch/tensor"
+)
+
+// QEmbedding embedding layer with int8 weight, created by quantizing Embedding,
+// only the looked up rows are dequantized
+type QEmbedding struct {
+	base
+	quantized
+	num     int
+	dim     int
+	padding int64
+}
+
+var _ Module = &QEmbedding{}
+
+func LoadQEmbedding(name string, params map[string]*tensor.Tensor, args map[string]float32, opts ...LayerCreateOption) Layer {
+	var layer QEmbedding
+	layer.new("qembedding", name, opts...)
+	layer.num = int(args["num"])
+	layer.dim = int(args["dim"])
+	layer.padding = int64(args["padding"])
+	layer.load(params)
+	return &layer
+}
+
+func (layer *QEmbedding) Forward(x *tensor.Tensor) *tensor.Tensor {
+	t := layer.scale.ScalarType()
+	// embedding is index_select on weight, so int8 rows are looked up before converting
+	w := tensor.Embedding(x, layer.w, layer.padding).ToScalarType(t)
+	zero := tensor.Embedding(x, layer.zero, layer.padding).ToScalarType(t)
+	scale := tensor.Embedding(x, layer.scale, layer.padding)
+	return w.Sub(zero).Mul(scale)
+}
+
+func (layer *QEmbedding) Call(_ *Context, inputs ...*tensor.Tensor) []*tensor.Tensor {
+	CheckInputs(layer, inputs, 1)
+	return []*tensor.Tensor{layer.Forward(inputs[0])}
+}
+
+func (layer *QEmbedding) Params() map[string]*tensor.Tensor {
+	return layer.params()
+}
+
+func (layer *QEmbedding) Args() map[string]float32 {
+	return map[string]float32{
+		"num":     float32(layer.num),
+		"dim":     float32(layer.dim),
+		"padding": float32(layer.padding),
+	}
+}
+
+func (layer *QEmbedding) Freeze() {
+	layer.frozen = true
+	layer.freeze()
+}
+
+func (layer *QEmbedding) Unfreeze() {
+	layer.frozen = false
+	layer.unfreeze()
+}
@@ -0,0 +1,51 @@
+package layer
+
+import (
+	"github.com/lwch/gotorch/tensor"
+)
+
+// QLinear linear layer with int8 weight, created by quantizing Linear
+type QLinear struct {
+	base
+	quantized
+	output int
+}
+
+var _ Module = &QLinear{}
+
+func LoadQLinear(name string, params map[string]*tensor.Tensor, args map[string]float32, opts ...LayerCreateOption) Layer {
+	var layer QLinear
+	layer.new("qlinear", name, opts...)
+	layer.output = int(args["output"])
+	layer.load(params)
+	return &layer
+}
+
+func (layer *QLinear) Forward(x *tensor.Tensor) *tensor.Tensor {
+	return x.MatMul(layer.dequantize().Transpose(0, 1))
+}
+
+func (layer *QLinear) Call(_ *Context, inputs ...*tensor.Tensor) []*tensor.Tensor {
+	CheckInputs(layer, inputs, 1)
+	return []*tensor.Tensor{layer.Forward(inputs[0])}
+}
+
+func (layer *QLinear) Params() map[string]*tensor.Tensor {
+	return layer.params()
+}
+
+func (layer *QLinear) Args() map[string]float32 {
+	return map[string]float32{
+		"output": float32(layer.output),
+	}
+}
+
+func (layer *QLinear) Freeze() {
+	layer.frozen = true
+	layer.freeze()
+}
+
+func (layer *QLinear) Unfreeze() {
+	layer.frozen = false
+	layer.unfreeze()
+}
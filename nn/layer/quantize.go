@@ -0,0 +1,131 @@
+package layer
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/lwch/gotorch/consts"
+	"github.com/lwch/gotorch/tensor"
+)
+
+// QuantizeInt8 quantize w to int8 per channel of the first dimension,
+// w = (q - zero) * scale, scale is in type of w and zero is int8,
+// both of them are shaped [channels, 1, ...] to broadcast with w,
+// returns error when w has less than 2 dimensions
+func QuantizeInt8(w *tensor.Tensor) (q, scale, zero *tensor.Tensor, err error) {
+	shapes := w.Shapes()
+	if len(shapes) < 2 {
+		return nil, nil, nil, fmt.Errorf("can not quantize %d-D tensor per channel", len(shapes))
+	}
+	data := w.ToDevice(consts.KCPU).ToScalarType(consts.KFloat).Float32Value()
+	channels := int(shapes[0])
+	size := len(data) / channels
+	qs := make([]int8, len(data))
+	scales := make([]float32, channels)
+	zeros := make([]int8, channels)
+	for c := 0; c < channels; c++ {
+		row := data[c*size : (c+1)*size]
+		// keep 0 representable
+		var min, max float32
+		for _, v := range row {
+			if v < min {
+				min = v
+			}
+			if v > max {
+				max = v
+			}
+		}
+		if max == min {
+			scales[c] = 1
+			continue
+		}
+		s := (max - min) / 255
+		zp := clampInt8(math.Round(float64(-128 - min/s)))
+		for i, v := range row {
+			qs[c*size+i] = clampInt8(math.Round(float64(v/s)) + float64(zp))
+		}
+		scales[c] = s
+		zeros[c] = zp
+	}
+	channelShapes := make([]int64, len(shapes))
+	channelShapes[0] = shapes[0]
+	for i := 1; i < len(shapes); i++ {
+		channelShapes[i] = 1
+	}
+	device := w.DeviceType()
+	q = tensor.FromInt8(qs,
+		tensor.WithShapes(shapes...),
+		tensor.WithDevice(device))
+	scale = fromFloat32(w.ScalarType(), scales,
+		tensor.WithShapes(channelShapes...),
+		tensor.WithDevice(device))
+	zero = tensor.FromInt8(zeros,
+		tensor.WithShapes(channelShapes...),
+		tensor.WithDevice(device))
+	return
+}
+
+func clampInt8(v float64) int8 {
+	if v < math.MinInt8 {
+		return math.MinInt8
+	}
+	if v > math.MaxInt8 {
+		return math.MaxInt8
+	}
+	return int8(v)
+}
+
+func fromFloat32(t consts.ScalarType, data []float32, opts ...tensor.Option) *tensor.Tensor {
+	switch t {
+	case consts.KBFloat16:
+		return tensor.FromBFloat16(data, opts...)
+	case consts.KHalf:
+		return tensor.FromHalf(data, opts...)
+	case consts.KFloat:
+		return tensor.FromFloat32(data, opts...)
+	case consts.KDouble:
+		f64 := make([]float64, len(data))
+		for i, v := range data {
+			f64[i] = float64(v)
+		}
+		return tensor.FromFloat64(f64, opts...)
+	default:
+		panic(fmt.Errorf("unsupported param type: %s", t.String()))
+	}
+}
+
+// quantized int8 weight with per channel scale and zero point
+type quantized struct {
+	w     *tensor.Tensor
+	scale *tensor.Tensor
+	zero  *tensor.Tensor
+}
+
+func (q *quantized) load(params map[string]*tensor.Tensor) {
+	q.w = params["w"]
+	q.scale = params["scale"]
+	q.zero = params["zero"]
+}
+
+func (q *quantized) params() map[string]*tensor.Tensor {
+	return map[string]*tensor.Tensor{
+		"w":     q.w,
+		"scale": q.scale,
+		"zero":  q.zero,
+	}
+}
+
+// dequantize build weight in type of scale
+func (q *quantized) dequantize() *tensor.Tensor {
+	t := q.scale.ScalarType()
+	return q.w.ToScalarType(t).Sub(q.zero.ToScalarType(t)).Mul(q.scale)
+}
+
+func (q *quantized) freeze() {
+	q.scale.SetRequiresGrad(false)
+}
+
+// unfreeze only scale is trainable, int8 weight can not require grad
+func (q *quantized) unfreeze() {
+	q.scale.SetRequiresGrad(true)
+}
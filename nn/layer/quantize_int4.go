@@ -0,0 +1,175 @@
+package layer
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/lwch/gotorch/consts"
+	"github.com/lwch/gotorch/tensor"
+)
+
+// Packing encoding of param packed by group-wise quantization
+type Packing struct {
+	Bits      int
+	GroupSize int
+	Shapes    []int64 // shapes of dequantized param
+}
+
+// PackedLayer layer with packed params, packings are recorded in model file
+type PackedLayer interface {
+	Layer
+	Packings() map[string]Packing
+}
+
+// QuantizeInt4 quantize 2-D weight to 4 bits by groups of each row,
+// w = (q - zero) * scale, two values are packed into one byte with the
+// lower 4 bits first, clip shrinks range of each row to reduce error of
+// the other values, nil clip means no clipping.
+// packed is shaped [rows, cols/2], scale and zero are shaped [rows, cols/group],
+// returns error when w is not 2-D or group does not divide the columns evenly
+func QuantizeInt4(w *tensor.Tensor, group int, clip []float32) (packed, scale, zero *tensor.Tensor, err error) {
+	shapes := w.Shapes()
+	if len(shapes) != 2 {
+		return nil, nil, nil, fmt.Errorf("can not quantize %d-D tensor by group", len(shapes))
+	}
+	rows, cols := int(shapes[0]), int(shapes[1])
+	if group <= 0 || group%2 != 0 || cols%group != 0 {
+		return nil, nil, nil, fmt.Errorf("invalid group size %d for %d columns", group, cols)
+	}
+	if clip != nil && len(clip) != rows {
+		return nil, nil, nil, fmt.Errorf("invalid clip size %d for %d rows", len(clip), rows)
+	}
+	data := w.ToDevice(consts.KCPU).ToScalarType(consts.KFloat).Float32Value()
+	groups := cols / group
+	qs := make([]uint8, rows*cols/2)
+	scales := make([]float32, rows*groups)
+	zeros := make([]uint8, rows*groups)
+	for r := 0; r < rows; r++ {
+		ratio := float32(1)
+		if clip != nil {
+			ratio = clip[r]
+		}
+		for g := 0; g < groups; g++ {
+			offset := r*cols + g*group
+			values := data[offset : offset+group]
+			var min, max float32
+			for _, v := range values {
+				if v < min {
+					min = v
+				}
+				if v > max {
+					max = v
+				}
+			}
+			min *= ratio
+			max *= ratio
+			idx := r*groups + g
+			if max == min {
+				scales[idx] = 1
+				continue
+			}
+			s := (max - min) / 15
+			zp := clampUint4(math.Round(float64(-min / s)))
+			for i, v := range values {
+				q := clampUint4(math.Round(float64(v/s)) + float64(zp))
+				if (offset+i)%2 == 0 {
+					qs[(offset+i)/2] |= q
+				} else {
+					qs[(offset+i)/2] |= q << 4
+				}
+			}
+			scales[idx] = s
+			zeros[idx] = zp
+		}
+	}
+	device := w.DeviceType()
+	packed = tensor.FromUint8(qs,
+		tensor.WithShapes(int64(rows), int64(cols/2)),
+		tensor.WithDevice(device))
+	scale = fromFloat32(w.ScalarType(), scales,
+		tensor.WithShapes(int64(rows), int64(groups)),
+		tensor.WithDevice(device))
+	zero = tensor.FromUint8(zeros,
+		tensor.WithShapes(int64(rows), int64(groups)),
+		tensor.WithDevice(device))
+	return
+}
+
+func clampUint4(v float64) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 15 {
+		return 15
+	}
+	return uint8(v)
+}
+
+// DequantizeInt4 build weight in type of scale from result of QuantizeInt4
+func DequantizeInt4(packed, scale, zero *tensor.Tensor, group int) *tensor.Tensor {
+	t := scale.ScalarType()
+	rows := packed.Shapes()[0]
+	cols := packed.Shapes()[1] * 2
+	sixteen := fromFloat32(t, []float32{16},
+		tensor.WithShapes(1),
+		tensor.WithDevice(packed.DeviceType()))
+	p := packed.ToScalarType(t)
+	// no bitwise op, casting to uint8 truncates the quotient
+	high := p.Div(sixteen).ToScalarType(consts.KUint8).ToScalarType(t)
+	low := p.Sub(high.Mul(sixteen))
+	w := tensor.Cat([]*tensor.Tensor{low.Unsqueeze(-1), high.Unsqueeze(-1)}, -1).
+		Reshape(rows, cols/int64(group), int64(group))
+	w = w.Sub(zero.ToScalarType(t).Unsqueeze(-1)).Mul(scale.Unsqueeze(-1))
+	return w.Reshape(rows, cols)
+}
+
+// grouped 4 bits packed weight with scale and zero point of each group
+type grouped struct {
+	w     *tensor.Tensor
+	scale *tensor.Tensor
+	zero  *tensor.Tensor
+	group int
+}
+
+func (g *grouped) load(params map[string]*tensor.Tensor, args map[string]float32) {
+	g.w = params["w"]
+	g.scale = params["scale"]
+	g.zero = params["zero"]
+	g.group = int(args["group"])
+	shapes := g.w.Shapes()
+	if len(shapes) != 2 || g.group <= 0 || (shapes[1]*2)%int64(g.group) != 0 {
+		panic(fmt.Errorf("invalid group size %d for packed shapes %v", g.group, shapes))
+	}
+}
+
+func (g *grouped) params() map[string]*tensor.Tensor {
+	return map[string]*tensor.Tensor{
+		"w":     g.w,
+		"scale": g.scale,
+		"zero":  g.zero,
+	}
+}
+
+func (g *grouped) packings() map[string]Packing {
+	shapes := g.w.Shapes()
+	return map[string]Packing{
+		"w": {
+			Bits:      4,
+			GroupSize: g.group,
+			Shapes:    []int64{shapes[0], shapes[1] * 2},
+		},
+	}
+}
+
+func (g *grouped) dequantize() *tensor.Tensor {
+	return DequantizeInt4(g.w, g.scale, g.zero, g.group)
+}
+
+func (g *grouped) freeze() {
+	g.scale.SetRequiresGrad(false)
+}
+
+// unfreeze only scale is trainable, packed weight can not require grad
+func (g *grouped) unfreeze() {
+	g.scale.SetRequiresGrad(true)
+}
@@ -0,0 +1,37 @@
+package layer
+
+import (
+	"testing"
+
+	"github.com/lwch/gotorch/tensor"
+)
+
+func TestQuantizeInt8Dims(t *testing.T) {
+	if _, _, _, err := QuantizeInt8(tensor.FromFloat32([]float32{1, 2, 3})); err == nil {
+		t.Fatal("expect error of 1-D tensor")
+	}
+	q, scale, zero, err := QuantizeInt8(tensor.FromFloat32([]float32{-1, 0, 1, 2}, tensor.WithShapes(2, 2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := (&quantized{w: q, scale: scale, zero: zero}).dequantize().Float32Value()
+	for i, v := range []float32{-1, 0, 1, 2} {
+		if d := w[i] - v; d > 0.01 || d < -0.01 {
+			t.Fatalf("expect %v, got %v", v, w)
+		}
+	}
+}
+
+func TestQuantizeInt4Invalid(t *testing.T) {
+	if _, _, _, err := QuantizeInt4(tensor.FromFloat32([]float32{1, 2, 3, 4}), 2, nil); err == nil {
+		t.Fatal("expect error of 1-D tensor")
+	}
+	w := tensor.FromFloat32([]float32{1, 2, 3, 4, 5, 6}, tensor.WithShapes(2, 3))
+	if _, _, _, err := QuantizeInt4(w, 2, nil); err == nil {
+		t.Fatal("expect error of group size")
+	}
+	w = tensor.FromFloat32([]float32{1, 2, 3, 4}, tensor.WithShapes(2, 2))
+	if _, _, _, err := QuantizeInt4(w, 2, []float32{1}); err == nil {
+		t.Fatal("expect error of clip size")
+	}
+}
@@ -10,6 +10,8 @@ type ReZero struct {
 	scale *tensor.Tensor
 }
 
+var _ Module = &ReZero{}
+
 func NewReZero(name string, opts ...LayerCreateOption) *ReZero {
 	var layer ReZero
 	layer.new("rezero", name, opts...)
@@ -18,9 +20,9 @@ func NewReZero(name string, opts ...LayerCreateOption) *ReZero {
 	return &layer
 }
 
-func LoadReZero(name string, params map[string]*tensor.Tensor, args map[string]float32) Layer {
+func LoadReZero(name string, params map[string]*tensor.Tensor, args map[string]float32, opts ...LayerCreateOption) Layer {
 	var layer ReZero
-	layer.new("rezero", name)
+	layer.new("rezero", name, opts...)
 	layer.scale = params["scale"]
 	return &layer
 }
@@ -29,6 +31,11 @@ func (layer *ReZero) Forward(x *tensor.Tensor) *tensor.Tensor {
 	return x.Mul(layer.scale)
 }
 
+func (layer *ReZero) Call(_ *Context, inputs ...*tensor.Tensor) []*tensor.Tensor {
+	CheckInputs(layer, inputs, 1)
+	return []*tensor.Tensor{layer.Forward(inputs[0])}
+}
+
 func (layer *ReZero) Params() map[string]*tensor.Tensor {
 	return map[string]*tensor.Tensor{
 		"scale": layer.scale,
@@ -40,9 +47,11 @@ func (layer *ReZero) Args() map[string]float32 {
 }
 
 func (layer *ReZero) Freeze() {
+	layer.frozen = true
 	layer.scale.SetRequiresGrad(false)
 }
 
 func (layer *ReZero) Unfreeze() {
+	layer.frozen = false
 	layer.scale.SetRequiresGrad(true)
 }
@@ -11,6 +11,8 @@ type RMSNorm struct {
 	a *tensor.Tensor
 }
 
+var _ Module = &RMSNorm{}
+
 func NewRMSNorm(name string, dims int64, opts ...LayerCreateOption) *RMSNorm {
 	var layer RMSNorm
 	layer.new("rms_norm", name, opts...)
@@ -20,9 +22,9 @@ func NewRMSNorm(name string, dims int64, opts ...LayerCreateOption) *RMSNorm {
 	return &layer
 }
 
-func LoadRMSNorm(name string, params map[string]*tensor.Tensor, args map[string]float32) Layer {
+func LoadRMSNorm(name string, params map[string]*tensor.Tensor, args map[string]float32, opts ...LayerCreateOption) Layer {
 	var layer RMSNorm
-	layer.new("rms_norm", name)
+	layer.new("rms_norm", name, opts...)
 	layer.paramType = params["a"].ScalarType()
 	layer.eps = layer.initN(1e-9)
 	layer.a = params["a"]
@@ -37,6 +39,11 @@ func (layer *RMSNorm) Forward(x *tensor.Tensor) *tensor.Tensor {
 	return layer.a.Mul(layer.norm(x))
 }
 
+func (layer *RMSNorm) Call(_ *Context, inputs ...*tensor.Tensor) []*tensor.Tensor {
+	CheckInputs(layer, inputs, 1)
+	return []*tensor.Tensor{layer.Forward(inputs[0])}
+}
+
 func (layer *RMSNorm) Params() map[string]*tensor.Tensor {
 	return map[string]*tensor.Tensor{
 		"a": layer.a,
@@ -44,9 +51,11 @@ func (layer *RMSNorm) Params() map[string]*tensor.Tensor {
 }
 
 func (layer *RMSNorm) Freeze() {
+	layer.frozen = true
 	layer.a.SetRequiresGrad(false)
 }
 
 func (layer *RMSNorm) Unfreeze() {
+	layer.frozen = false
 	layer.a.SetRequiresGrad(true)
 }
@@ -14,6 +14,8 @@ type Rnn struct {
 	b *tensor.Tensor
 }
 
+var _ Module = &Rnn{}
+
 func NewRnn(name string, featureSize, steps, hidden int, opts ...LayerCreateOption) *Rnn {
 	var layer Rnn
 	layer.new("rnn", name, opts...)
@@ -25,9 +27,9 @@ func NewRnn(name string, featureSize, steps, hidden int, opts ...LayerCreateOpti
 	return &layer
 }
 
-func LoadRnn(name string, params map[string]*tensor.Tensor, args map[string]float32) Layer {
+func LoadRnn(name string, params map[string]*tensor.Tensor, args map[string]float32, opts ...LayerCreateOption) Layer {
 	var layer Rnn
-	layer.new("rnn", name)
+	layer.new("rnn", name, opts...)
 	layer.featureSize = int(args["feature_size"])
 	layer.steps = int(args["steps"])
 	layer.hidden = int(args["hidden"])
@@ -36,14 +38,30 @@ func LoadRnn(name string, params map[string]*tensor.Tensor, args map[string]floa
 	return &layer
 }
 
+// copyState copy hidden state out of the graph, scalar type is kept
 func copyState(name string, s *tensor.Tensor) *tensor.Tensor {
-	return tensor.FromFloat32(s.Float32Value(), tensor.WithShapes(s.Shapes()...))
+	opts := []tensor.Option{
+		tensor.WithShapes(s.Shapes()...),
+		tensor.WithDevice(s.DeviceType()),
+	}
+	switch s.ScalarType() {
+	case consts.KBFloat16:
+		return tensor.FromBFloat16Raw(s.BFloat16Raw(), opts...)
+	case consts.KHalf:
+		return tensor.FromHalfRaw(s.HalfRaw(), opts...)
+	case consts.KDouble:
+		return tensor.FromFloat64(s.Float64Value(), opts...)
+	default:
+		return tensor.FromFloat32(s.Float32Value(), opts...)
+	}
 }
 
 func (layer *Rnn) Forward(x, h *tensor.Tensor) (*tensor.Tensor, *tensor.Tensor) {
 	inputShape := x.Shapes()
 	if h == nil {
-		h = tensor.Zeros(consts.KFloat, tensor.WithShapes(inputShape[0], int64(layer.hidden)))
+		h = tensor.Zeros(layer.paramType,
+			tensor.WithShapes(inputShape[0], int64(layer.hidden)),
+			tensor.WithDevice(layer.device))
 	}
 	x = x.Transpose(1, 0) // (steps, batch, feature)
 	var result *tensor.Tensor
@@ -63,6 +81,17 @@ func (layer *Rnn) Forward(x, h *tensor.Tensor) (*tensor.Tensor, *tensor.Tensor)
 		copyState(layer.name+".hidden", h)
 }
 
+// Call inputs: x[, h], outputs: y, h
+func (layer *Rnn) Call(_ *Context, inputs ...*tensor.Tensor) []*tensor.Tensor {
+	CheckInputs(layer, inputs, 1)
+	var h *tensor.Tensor
+	if len(inputs) > 1 {
+		h = inputs[1]
+	}
+	y, h := layer.Forward(inputs[0], h)
+	return []*tensor.Tensor{y, h}
+}
+
 func (layer *Rnn) Params() map[string]*tensor.Tensor {
 	return map[string]*tensor.Tensor{
 		"w": layer.w,
@@ -79,11 +108,13 @@ func (layer *Rnn) Args() map[string]float32 {
 }
 
 func (layer *Rnn) Freeze() {
+	layer.frozen = true
 	layer.w.SetRequiresGrad(false)
 	layer.b.SetRequiresGrad(false)
 }
 
 func (layer *Rnn) Unfreeze() {
+	layer.frozen = false
 	layer.w.SetRequiresGrad(true)
 	layer.b.SetRequiresGrad(true)
 }
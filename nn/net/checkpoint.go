@@ -0,0 +1,144 @@
+package net
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/lwch/gotorch/optimizer"
+	"github.com/lwch/tnn/internal/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+const checkpointFile = "CHECKPOINT"
+
+// Checkpoint training state saved together with the model
+type Checkpoint struct {
+	Epoch int64
+	Step  int64
+	Meta  map[string]string
+	Rand  *RandSource
+	// Optimizer only the learning rate is saved and restored, the internal
+	// state of gotorch optimizers (e.g. the moments of adam and adamw) lives
+	// in libtorch and can not be read, so loading a checkpoint of a trained
+	// step with a stateful optimizer fails unless ResetOptimizer is set
+	Optimizer optimizer.Optimizer
+	// ResetOptimizer allow loading with a stateful optimizer, its state starts
+	// from zero, so a resumed run is not identical to an uninterrupted one
+	ResetOptimizer bool
+}
+
+// SaveCheckpoint save model and training state into one file,
+// the file is replaced atomically so the last checkpoint is kept when crashed
+func (n *Net) SaveCheckpoint(dir string, cp *Checkpoint) error {
+	return atomicWrite(dir, func(w io.Writer) error {
+		zw := newZipWriter(w)
+		if _, err := n.writeTo(zw, zip.Deflate); err != nil {
+			return err
+		}
+		if err := cp.writeTo(zw); err != nil {
+			return err
+		}
+		return zw.Close()
+	})
+}
+
+// LoadCheckpoint load model and training state, Rand and Optimizer in cp are
+// restored in place when they are not nil
+func (n *Net) LoadCheckpoint(dir string, cp *Checkpoint, opts ...LoadOption) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	zr, err := newZipReader(f, fi.Size())
+	if err != nil {
+		return err
+	}
+	// check state before loading the model, so nothing is changed on error
+	spec, err := cp.readFrom(zr)
+	if err != nil {
+		return err
+	}
+	if _, err = n.readFrom(zr, fi.Size(), opts...); err != nil {
+		return err
+	}
+	cp.apply(spec)
+	return nil
+}
+
+func (cp *Checkpoint) writeTo(zw *zip.Writer) error {
+	var spec pb.Checkpoint
+	spec.Epoch = cp.Epoch
+	spec.Step = cp.Step
+	spec.Meta = cp.Meta
+	if cp.Rand != nil {
+		spec.RandState = cp.Rand.State()
+	}
+	if cp.Optimizer != nil {
+		spec.Lr = proto.Float64(cp.Optimizer.GetLr())
+	}
+	data, err := proto.Marshal(&spec)
+	if err != nil {
+		return err
+	}
+	f, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     checkpointFile,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+// readFrom read training state and check whether it can be restored into cp
+func (cp *Checkpoint) readFrom(zr *zip.Reader) (*pb.Checkpoint, error) {
+	f, err := zr.Open(checkpointFile)
+	if err != nil {
+		return nil, fmt.Errorf("read checkpoint: %v", err)
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	var spec pb.Checkpoint
+	if err = proto.Unmarshal(data, &spec); err != nil {
+		return nil, err
+	}
+	if cp.Optimizer != nil && stateful(cp.Optimizer) && spec.GetStep() > 0 && !cp.ResetOptimizer {
+		return nil, fmt.Errorf("state of optimizer %T can not be restored, set ResetOptimizer to resume without it", cp.Optimizer)
+	}
+	return &spec, nil
+}
+
+func (cp *Checkpoint) apply(spec *pb.Checkpoint) {
+	cp.Epoch = spec.GetEpoch()
+	cp.Step = spec.GetStep()
+	cp.Meta = spec.GetMeta()
+	if cp.Rand != nil {
+		cp.Rand.SetState(spec.GetRandState())
+	}
+	// lr of 0 is restored too, it is missing only in checkpoints without optimizer
+	if cp.Optimizer != nil && spec.Lr != nil {
+		cp.Optimizer.SetLr(spec.GetLr())
+	}
+}
+
+// stateful check whether optm keeps state between steps
+func stateful(optm optimizer.Optimizer) bool {
+	switch optm.(type) {
+	case *optimizer.Adam, *optimizer.AdamW:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package net
+
+import (
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/lwch/gotorch/optimizer"
+	"github.com/lwch/tnn/nn/layer"
+)
+
+func TestCheckpoint(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "test.ckpt")
+	var net Net
+	net.Add(layer.NewLinear("linear", 2, 3))
+	src := NewRandSource(42)
+	rand.New(src).Intn(100)
+	err := net.SaveCheckpoint(dir, &Checkpoint{
+		Epoch:     3,
+		Step:      100,
+		Meta:      map[string]string{"name": "test"},
+		Rand:      src,
+		Optimizer: optimizer.NewAdam(optimizer.WithAdamLr(0.01)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches, _ := filepath.Glob(dir + ".*.tmp")
+	if len(matches) > 0 {
+		t.Fatalf("temporary file not removed: %v", matches)
+	}
+	cp := Checkpoint{
+		Rand:      NewRandSource(0),
+		Optimizer: optimizer.NewAdam(),
+	}
+	var loaded Net
+	if err = loaded.LoadCheckpoint(dir, &cp); err == nil {
+		t.Fatal("expect error of optimizer state")
+	}
+	if len(loaded.Layers()) != 0 {
+		t.Fatal("model loaded on error")
+	}
+	cp.ResetOptimizer = true
+	if err = loaded.LoadCheckpoint(dir, &cp); err != nil {
+		t.Fatal(err)
+	}
+	if cp.Epoch != 3 || cp.Step != 100 || cp.Meta["name"] != "test" {
+		t.Fatalf("unexpected checkpoint: %+v", cp)
+	}
+	if cp.Rand.State() != src.State() {
+		t.Fatal("rand state not restored")
+	}
+	if cp.Optimizer.GetLr() != 0.01 {
+		t.Fatal("lr not restored")
+	}
+	if len(loaded.Layers()) != 1 {
+		t.Fatal("layers not loaded")
+	}
+}
+
+func TestCheckpointZeroLr(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "test.ckpt")
+	var net Net
+	net.Add(layer.NewLinear("linear", 2, 3))
+	err := net.SaveCheckpoint(dir, &Checkpoint{
+		Optimizer: optimizer.NewAdam(optimizer.WithAdamLr(0)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// no step is trained, so the state of adam is empty
+	cp := Checkpoint{Optimizer: optimizer.NewAdam(optimizer.WithAdamLr(0.01))}
+	var loaded Net
+	if err = loaded.LoadCheckpoint(dir, &cp); err != nil {
+		t.Fatal(err)
+	}
+	if cp.Optimizer.GetLr() != 0 {
+		t.Fatalf("lr of 0 not restored: %f", cp.Optimizer.GetLr())
+	}
+}
@@ -0,0 +1,414 @@
+package net
+
+import (
+	"fmt"
+
+	"github.com/lwch/gotorch/tensor"
+	"github.com/lwch/tnn/internal/pb"
+	"github.com/lwch/tnn/nn/layer"
+)
+
+type opFunc func(inputs ...*tensor.Tensor) []*tensor.Tensor
+
+type op struct {
+	inputs int // expected count of inputs
+	fn     opFunc
+}
+
+var ops = map[string]op{
+	"add": {2, func(inputs ...*tensor.Tensor) []*tensor.Tensor {
+		return []*tensor.Tensor{inputs[0].Add(inputs[1])}
+	}},
+	"sub": {2, func(inputs ...*tensor.Tensor) []*tensor.Tensor {
+		return []*tensor.Tensor{inputs[0].Sub(inputs[1])}
+	}},
+	"mul": {2, func(inputs ...*tensor.Tensor) []*tensor.Tensor {
+		return []*tensor.Tensor{inputs[0].Mul(inputs[1])}
+	}},
+	"div": {2, func(inputs ...*tensor.Tensor) []*tensor.Tensor {
+		return []*tensor.Tensor{inputs[0].Div(inputs[1])}
+	}},
+	"matmul": {2, func(inputs ...*tensor.Tensor) []*tensor.Tensor {
+		return []*tensor.Tensor{inputs[0].MatMul(inputs[1])}
+	}},
+}
+
+// RegisterOp register builtin op used by graph nodes, fn is always called
+// with the given count of inputs
+func RegisterOp(name string, inputs int, fn opFunc) {
+	ops[name] = op{inputs: inputs, fn: fn}
+}
+
+// Value output of a node or input of the graph
+type Value struct {
+	node  int // graph input when < 0
+	index int
+}
+
+// Output get the i-th output of the same node
+func (v Value) Output(i int) Value {
+	return Value{node: v.node, index: i}
+}
+
+type node struct {
+	name   string
+	layer  layer.Layer
+	op     string
+	module *Graph
+	inputs []Value
+}
+
+// Graph model topology, nodes are stored in topological order
+type Graph struct {
+	name    string
+	inputs  int
+	nodes   []node
+	outputs []Value
+}
+
+// NewGraph create graph with n inputs
+func NewGraph(name string, inputs int) *Graph {
+	return &Graph{name: name, inputs: inputs}
+}
+
+// Name get graph name
+func (g *Graph) Name() string {
+	return g.name
+}
+
+// Input get the i-th input of the graph
+func (g *Graph) Input(i int) Value {
+	if i < 0 || i >= g.inputs {
+		panic(fmt.Errorf("graph %s: input index %d out of range", g.name, i))
+	}
+	return Value{node: -1, index: i}
+}
+
+func (g *Graph) add(n node) Value {
+	for _, v := range n.inputs {
+		if v.node >= len(g.nodes) {
+			panic(fmt.Errorf("graph %s: node %s use value from another graph", g.name, n.name))
+		}
+	}
+	g.nodes = append(g.nodes, n)
+	return Value{node: len(g.nodes) - 1}
+}
+
+// Layer add layer node, returns the first output of the node
+func (g *Graph) Layer(l layer.Layer, inputs ...Value) Value {
+	return g.add(node{name: l.Name(), layer: l, inputs: inputs})
+}
+
+// Op add builtin op node, returns the first output of the node
+func (g *Graph) Op(op string, inputs ...Value) Value {
+	fn, ok := ops[op]
+	if !ok {
+		panic(fmt.Errorf("graph %s: unsupported op %s", g.name, op))
+	}
+	if len(inputs) != fn.inputs {
+		panic(fmt.Errorf("graph %s: op %s expect %d inputs, got %d",
+			g.name, op, fn.inputs, len(inputs)))
+	}
+	return g.add(node{name: op, op: op, inputs: inputs})
+}
+
+// Module add sub module node, returns the first output of the node
+func (g *Graph) Module(m *Graph, inputs ...Value) Value {
+	if len(inputs) != m.inputs {
+		panic(fmt.Errorf("graph %s: module %s expect %d inputs, got %d",
+			g.name, m.name, m.inputs, len(inputs)))
+	}
+	return g.add(node{name: m.name, module: m, inputs: inputs})
+}
+
+// SetOutputs set outputs of the graph
+func (g *Graph) SetOutputs(outputs ...Value) {
+	g.outputs = outputs
+}
+
+// Layers get all layers used by graph and its sub modules
+func (g *Graph) Layers() []layer.Layer {
+	var ret []layer.Layer
+	exists := make(map[layer.Layer]bool)
+	g.walk(func(g *Graph) {
+		for _, n := range g.nodes {
+			if n.layer != nil && !exists[n.layer] {
+				exists[n.layer] = true
+				ret = append(ret, n.layer)
+			}
+		}
+	})
+	return ret
+}
+
+// walk visit graph and all sub modules once
+func (g *Graph) walk(fn func(*Graph)) {
+	visited := make(map[*Graph]bool)
+	var visit func(*Graph)
+	visit = func(g *Graph) {
+		if visited[g] {
+			return
+		}
+		visited[g] = true
+		fn(g)
+		for _, n := range g.nodes {
+			if n.module != nil {
+				visit(n.module)
+			}
+		}
+	}
+	visit(g)
+}
+
+// observer called with inputs before each layer runs
+type observer func(l layer.Layer, inputs []*tensor.Tensor)
+
+func (g *Graph) forward(ctx *layer.Context, inputs []*tensor.Tensor, observe observer) ([]*tensor.Tensor, error) {
+	if len(inputs) != g.inputs {
+		return nil, fmt.Errorf("graph %s: expect %d inputs, got %d", g.name, g.inputs, len(inputs))
+	}
+	values := make([][]*tensor.Tensor, len(g.nodes))
+	get := func(v Value) (*tensor.Tensor, error) {
+		if v.node < 0 {
+			return inputs[v.index], nil
+		}
+		if v.index >= len(values[v.node]) {
+			return nil, fmt.Errorf("graph %s: node %d(%s) has no output %d",
+				g.name, v.node, g.nodes[v.node].name, v.index)
+		}
+		return values[v.node][v.index], nil
+	}
+	for i, n := range g.nodes {
+		args := make([]*tensor.Tensor, len(n.inputs))
+		for j, v := range n.inputs {
+			t, err := get(v)
+			if err != nil {
+				return nil, err
+			}
+			args[j] = t
+		}
+		switch {
+		case n.layer != nil:
+			m, ok := n.layer.(layer.Module)
+			if !ok {
+				return nil, fmt.Errorf("graph %s: node %d(%s): %s layer can not forward",
+					g.name, i, n.name, n.layer.Class())
+			}
+			if observe != nil {
+				observe(n.layer, args)
+			}
+			values[i] = m.Call(ctx, args...)
+		case n.module != nil:
+			outputs, err := n.module.forward(ctx, args, observe)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = outputs
+		default:
+			values[i] = ops[n.op].fn(args...)
+		}
+	}
+	ret := make([]*tensor.Tensor, len(g.outputs))
+	for i, v := range g.outputs {
+		t, err := get(v)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = t
+	}
+	return ret, nil
+}
+
+func encodeEdges(values []Value) []*pb.Edge {
+	ret := make([]*pb.Edge, len(values))
+	for i, v := range values {
+		ret[i] = &pb.Edge{Node: int32(v.node), Index: uint32(v.index)}
+	}
+	return ret
+}
+
+func (g *Graph) encode(layers map[layer.Layer]int) (*pb.Graph, error) {
+	ret := &pb.Graph{
+		Name:    g.name,
+		Inputs:  uint32(g.inputs),
+		Nodes:   make([]*pb.Node, len(g.nodes)),
+		Outputs: encodeEdges(g.outputs),
+	}
+	for i, n := range g.nodes {
+		var node pb.Node
+		node.Name = n.name
+		node.Inputs = encodeEdges(n.inputs)
+		switch {
+		case n.layer != nil:
+			idx, ok := layers[n.layer]
+			if !ok {
+				return nil, fmt.Errorf("graph %s: layer %s is not added to net", g.name, n.layer.Name())
+			}
+			node.Target = &pb.Node_Layer{Layer: uint32(idx)}
+		case n.module != nil:
+			node.Target = &pb.Node_Module{Module: n.module.name}
+		default:
+			node.Target = &pb.Node_Op{Op: n.op}
+		}
+		ret.Nodes[i] = &node
+	}
+	return ret, nil
+}
+
+// encodeGraph encode root graph and all sub modules
+func encodeGraph(root *Graph, layers []layer.Layer) (*pb.Graph, []*pb.Graph, error) {
+	idx := make(map[layer.Layer]int, len(layers))
+	for i, l := range layers {
+		idx[l] = i
+	}
+	var graphs []*Graph
+	names := make(map[string]bool)
+	var err error
+	root.walk(func(g *Graph) {
+		if g == root {
+			return
+		}
+		if names[g.name] {
+			err = fmt.Errorf("duplicate module name: %s", g.name)
+		}
+		names[g.name] = true
+		graphs = append(graphs, g)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	graph, err := root.encode(idx)
+	if err != nil {
+		return nil, nil, err
+	}
+	modules := make([]*pb.Graph, len(graphs))
+	for i, g := range graphs {
+		modules[i], err = g.encode(idx)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return graph, modules, nil
+}
+
+func decodeEdges(g *Graph, edges []*pb.Edge, nodes int) ([]Value, error) {
+	ret := make([]Value, len(edges))
+	for i, e := range edges {
+		v := Value{node: int(e.GetNode()), index: int(e.GetIndex())}
+		if v.node < 0 {
+			if v.index >= g.inputs {
+				return nil, fmt.Errorf("graph %s: input index %d out of range", g.name, v.index)
+			}
+			v.node = -1
+		} else if v.node >= nodes {
+			return nil, fmt.Errorf("graph %s: edge from node %d is not in topological order", g.name, v.node)
+		}
+		ret[i] = v
+	}
+	return ret, nil
+}
+
+// decodeGraph decode root graph and all sub modules
+func decodeGraph(root *pb.Graph, modules []*pb.Graph, layers []layer.Layer) (*Graph, error) {
+	graphs := make(map[string]*Graph, len(modules))
+	for _, m := range modules {
+		if _, ok := graphs[m.GetName()]; ok {
+			return nil, fmt.Errorf("duplicate module name: %s", m.GetName())
+		}
+		graphs[m.GetName()] = NewGraph(m.GetName(), int(m.GetInputs()))
+	}
+	decode := func(g *Graph, spec *pb.Graph) error {
+		for i, n := range spec.GetNodes() {
+			inputs, err := decodeEdges(g, n.GetInputs(), i)
+			if err != nil {
+				return err
+			}
+			node := node{name: n.GetName(), inputs: inputs}
+			switch target := n.GetTarget().(type) {
+			case *pb.Node_Layer:
+				if int(target.Layer) >= len(layers) {
+					return fmt.Errorf("graph %s: node %d(%s): layer index %d out of range",
+						g.name, i, n.GetName(), target.Layer)
+				}
+				// every layer takes at least one input
+				if len(inputs) == 0 {
+					return fmt.Errorf("graph %s: node %d(%s): layer expect at least 1 input",
+						g.name, i, n.GetName())
+				}
+				node.layer = layers[target.Layer]
+			case *pb.Node_Op:
+				op, ok := ops[target.Op]
+				if !ok {
+					return fmt.Errorf("graph %s: node %d(%s): unsupported op %s",
+						g.name, i, n.GetName(), target.Op)
+				}
+				if len(inputs) != op.inputs {
+					return fmt.Errorf("graph %s: node %d(%s): op %s expect %d inputs, got %d",
+						g.name, i, n.GetName(), target.Op, op.inputs, len(inputs))
+				}
+				node.op = target.Op
+			case *pb.Node_Module:
+				node.module = graphs[target.Module]
+				if node.module == nil {
+					return fmt.Errorf("graph %s: node %d(%s): module %s not found",
+						g.name, i, n.GetName(), target.Module)
+				}
+				if len(inputs) != node.module.inputs {
+					return fmt.Errorf("graph %s: node %d(%s): module %s expect %d inputs, got %d",
+						g.name, i, n.GetName(), target.Module, node.module.inputs, len(inputs))
+				}
+			default:
+				return fmt.Errorf("graph %s: node %d(%s): missing target", g.name, i, n.GetName())
+			}
+			g.nodes = append(g.nodes, node)
+		}
+		outputs, err := decodeEdges(g, spec.GetOutputs(), len(g.nodes))
+		if err != nil {
+			return err
+		}
+		g.outputs = outputs
+		return nil
+	}
+	for _, m := range modules {
+		if err := decode(graphs[m.GetName()], m); err != nil {
+			return nil, err
+		}
+	}
+	g := NewGraph(root.GetName(), int(root.GetInputs()))
+	if err := decode(g, root); err != nil {
+		return nil, err
+	}
+	if err := checkCycle(g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// checkCycle make sure no module calls itself
+func checkCycle(root *Graph) error {
+	const (
+		visiting = iota + 1
+		done
+	)
+	state := make(map[*Graph]int)
+	var visit func(*Graph) error
+	visit = func(g *Graph) error {
+		switch state[g] {
+		case visiting:
+			return fmt.Errorf("module %s is called recursively", g.name)
+		case done:
+			return nil
+		}
+		state[g] = visiting
+		for _, n := range g.nodes {
+			if n.module != nil {
+				if err := visit(n.module); err != nil {
+					return err
+				}
+			}
+		}
+		state[g] = done
+		return nil
+	}
+	return visit(root)
+}
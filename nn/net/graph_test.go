@@ -0,0 +1,76 @@
+package net
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/lwch/gotorch/tensor"
+	"github.com/lwch/tnn/internal/pb"
+	"github.com/lwch/tnn/nn/layer"
+	"github.com/lwch/tnn/nn/layer/activation"
+)
+
+func buildResidual() *Net {
+	block := NewGraph("block", 1)
+	x := block.Input(0)
+	y := block.Layer(layer.NewLinear("block.linear", 4, 4), x)
+	y = block.Layer(activation.NewReLU(), y)
+	block.SetOutputs(block.Op("add", y, x))
+
+	root := NewGraph("root", 1)
+	y = root.Module(block, root.Input(0))
+	y = root.Layer(layer.NewLinear("output", 4, 1), y)
+	root.SetOutputs(y)
+
+	var net Net
+	net.SetGraph(root)
+	return &net
+}
+
+func TestGraph(t *testing.T) {
+	net := buildResidual()
+	var buf bytes.Buffer
+	if _, err := net.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var loaded Net
+	if _, err := loaded.ReadFrom(bytes.NewReader(buf.Bytes()), int64(buf.Len())); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Graph() == nil {
+		t.Fatal("graph not loaded")
+	}
+	x := tensor.FromFloat32([]float32{1, 2, 3, 4, 5, 6, 7, 8}, tensor.WithShapes(2, 4))
+	want, err := net.Forward(nil, x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := loaded.Forward(nil, x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := want[0].Float32Value(), got[0].Float32Value()
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("unexpected output: %v != %v", a, b)
+		}
+	}
+}
+
+func TestGraphInvalidOp(t *testing.T) {
+	spec := &pb.Net{Graph: &pb.Graph{
+		Name:   "root",
+		Inputs: 1,
+		Nodes: []*pb.Node{{
+			Name:   "add",
+			Target: &pb.Node_Op{Op: "add"},
+			Inputs: []*pb.Edge{{Node: -1}},
+		}},
+		Outputs: []*pb.Edge{{Node: 0}},
+	}}
+	r := writeSpec(t, spec, nil)
+	var net Net
+	if _, err := net.ReadFrom(r, r.Size()); err == nil {
+		t.Fatal("expect error")
+	}
+}
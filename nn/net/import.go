@@ -0,0 +1,242 @@
+package net
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/lwch/gotorch/consts"
+	"github.com/lwch/gotorch/model"
+	"github.com/lwch/gotorch/tensor"
+	"github.com/lwch/tnn/internal/nnutil"
+	"github.com/lwch/tnn/internal/pb"
+)
+
+// MappingRule map tensors of pytorch state_dict to params of tnn layers,
+// Pattern must match the whole tensor name, Layer and Param may reference
+// submatches of Pattern by $1 or ${name}, e.g.
+//
+//	MappingRule{
+//	    Pattern:   `encoder\.layers\.(\d+)\.self_attn\.in_proj_weight`,
+//	    Layer:     "attn$1",
+//	    Param:     "w",
+//	    BlockDiag: true,
+//	}
+type MappingRule struct {
+	Pattern   string
+	Layer     string
+	Param     string
+	Transpose bool // transpose 2-D tensor before import
+	// BlockDiag expand (3d, d) tensor stacked by q, k and v projections, e.g.
+	// in_proj_weight of nn.MultiheadAttention, to (3d, 3d) block diagonal
+	// tensor used by attention layers, it is applied before Transpose
+	BlockDiag bool
+}
+
+// ImportReport result of importing, tensors which are not imported are
+// reported instead of being ignored silently
+type ImportReport struct {
+	// Imported tensor name => layer_name.param_name
+	Imported map[string]string
+	// Unmapped tensors which match no rule or whose target is not found
+	Unmapped []string
+	// Mismatched tensors whose shape or type mismatch the target param
+	Mismatched []string
+	// Missing params of layers which are not populated
+	Missing []string
+}
+
+// Err returns error when any tensor is not imported or any param is missing
+func (r *ImportReport) Err() error {
+	var errs []error
+	if len(r.Unmapped) > 0 {
+		errs = append(errs, fmt.Errorf("unmapped tensors: %s", strings.Join(r.Unmapped, ", ")))
+	}
+	for _, msg := range r.Mismatched {
+		errs = append(errs, errors.New(msg))
+	}
+	if len(r.Missing) > 0 {
+		errs = append(errs, fmt.Errorf("missing params: %s", strings.Join(r.Missing, ", ")))
+	}
+	return errors.Join(errs...)
+}
+
+type mappingRule struct {
+	MappingRule
+	re *regexp.Regexp
+}
+
+func compileRules(rules []MappingRule) ([]mappingRule, error) {
+	ret := make([]mappingRule, len(rules))
+	for i, rule := range rules {
+		re, err := regexp.Compile("^(?:" + rule.Pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i, err)
+		}
+		ret[i] = mappingRule{MappingRule: rule, re: re}
+	}
+	return ret, nil
+}
+
+// target find layer name and param name of tensor by the first matched rule
+func target(rules []mappingRule, name string) (string, string, *mappingRule) {
+	for i, rule := range rules {
+		m := rule.re.FindStringSubmatchIndex(name)
+		if m == nil {
+			continue
+		}
+		layer := rule.re.ExpandString(nil, rule.Layer, name, m)
+		param := rule.re.ExpandString(nil, rule.Param, name, m)
+		return string(layer), string(param), &rules[i]
+	}
+	return "", "", nil
+}
+
+// blockDiag expand (3d, d) tensor to (3d, 3d) with the three (d, d) blocks on diagonal
+func blockDiag(t *tensor.Tensor) *tensor.Tensor {
+	d := t.Shapes()[1]
+	zeros := func(cols int64) *tensor.Tensor {
+		return tensor.Zeros(t.ScalarType(),
+			tensor.WithShapes(d, cols),
+			tensor.WithDevice(t.DeviceType()))
+	}
+	rows := make([]*tensor.Tensor, 3)
+	for i := int64(0); i < 3; i++ {
+		var parts []*tensor.Tensor
+		if i > 0 {
+			parts = append(parts, zeros(i*d))
+		}
+		parts = append(parts, t.NArrow(0, i*d, d))
+		if i < 2 {
+			parts = append(parts, zeros((2-i)*d))
+		}
+		rows[i] = tensor.Cat(parts, 1)
+	}
+	return tensor.Cat(rows, 0).Contiguous()
+}
+
+// ImportTorch import params from pytorch state_dict file(.pt/.bin) by rules,
+// layers must be added before importing, params not in state_dict are kept
+func (n *Net) ImportTorch(dir string, rules []MappingRule, opts ...LoadOption) (report *ImportReport, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("load %s: %v", dir, e)
+		}
+	}()
+	m, err := model.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	return n.ImportTensors(m.Params(), rules, opts...)
+}
+
+// ImportTensors import params from named tensors by rules,
+// layers must be added before importing, params not in tensors are kept
+func (n *Net) ImportTensors(tensors map[string]*tensor.Tensor, rules []MappingRule, opts ...LoadOption) (*ImportReport, error) {
+	compiled, err := compileRules(rules)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := n.buildSpec(paramFile)
+	if err != nil {
+		return nil, err
+	}
+	layers := make(map[string]int, len(n.layers))
+	for i, l := range n.layers {
+		if _, ok := layers[l.Name()]; ok {
+			return nil, fmt.Errorf("duplicate layer name: %s", l.Name())
+		}
+		layers[l.Name()] = i
+	}
+	names := make([]string, 0, len(tensors))
+	for name := range tensors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	report := &ImportReport{Imported: make(map[string]string)}
+	imported := make(map[string]*tensor.Tensor)
+	for _, name := range names {
+		layerName, paramName, rule := target(compiled, name)
+		if rule == nil {
+			report.Unmapped = append(report.Unmapped, name)
+			continue
+		}
+		key := safetensorsKey(layerName, paramName)
+		idx, ok := layers[layerName]
+		if !ok {
+			report.Unmapped = append(report.Unmapped, fmt.Sprintf("%s(layer %s not found)", name, layerName))
+			continue
+		}
+		param := spec.Layers[idx].Params[paramName]
+		if param == nil {
+			report.Unmapped = append(report.Unmapped, fmt.Sprintf("%s(param %s not found)", name, key))
+			continue
+		}
+		t := tensors[name]
+		if rule.BlockDiag {
+			if t.Dims() != 2 || t.Shapes()[0] != t.Shapes()[1]*3 {
+				report.Mismatched = append(report.Mismatched,
+					fmt.Sprintf("%s: can not expand shape %v to block diagonal", name, t.Shapes()))
+				continue
+			}
+			t = blockDiag(t)
+		}
+		if rule.Transpose {
+			if t.Dims() != 2 {
+				report.Mismatched = append(report.Mismatched,
+					fmt.Sprintf("%s: can not transpose %d-D tensor", name, t.Dims()))
+				continue
+			}
+			t = t.Transpose(0, 1).Contiguous()
+		}
+		if !nnutil.SameShapes(t.Shapes(), param.GetShapes()) {
+			report.Mismatched = append(report.Mismatched,
+				fmt.Sprintf("%s: shape %v mismatch %s %v", name, t.Shapes(), key, param.GetShapes()))
+			continue
+		}
+		if nnutil.IsFloat(t.ScalarType()) != nnutil.IsFloat(consts.ScalarType(param.GetType())) {
+			report.Mismatched = append(report.Mismatched,
+				fmt.Sprintf("%s: type %s mismatch %s %s", name, t.ScalarType().String(),
+					key, consts.ScalarType(param.GetType()).String()))
+			continue
+		}
+		if _, ok := imported[param.GetFile()]; ok {
+			report.Mismatched = append(report.Mismatched,
+				fmt.Sprintf("%s: %s is already imported", name, key))
+			continue
+		}
+		imported[param.GetFile()] = t
+		report.Imported[name] = key
+	}
+	for i, l := range spec.Layers {
+		for name, param := range l.Params {
+			if _, ok := imported[param.GetFile()]; !ok {
+				report.Missing = append(report.Missing, safetensorsKey(n.layers[i].Name(), name))
+			}
+		}
+	}
+	sort.Strings(report.Missing)
+	current := make(map[string]*tensor.Tensor, len(spec.Layers))
+	for i, l := range n.layers {
+		for name, p := range l.Params() {
+			current[paramFile(i, name)] = p
+		}
+	}
+	err = n.build(spec, func(param *pb.Param, device consts.DeviceType) (*tensor.Tensor, error) {
+		t, ok := imported[param.GetFile()]
+		if !ok {
+			return current[param.GetFile()], nil
+		}
+		t = t.ToDevice(device)
+		if t.ScalarType() != consts.ScalarType(param.GetType()) {
+			t = t.ToScalarType(consts.ScalarType(param.GetType()))
+		}
+		return t, nil
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
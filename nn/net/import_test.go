@@ -0,0 +1,115 @@
+package net
+
+import (
+	"testing"
+
+	"github.com/lwch/gotorch/tensor"
+	"github.com/lwch/tnn/nn/layer"
+)
+
+func TestImportTensors(t *testing.T) {
+	var net Net
+	net.Add(layer.NewLinear("fc0", 2, 3))
+	net.Add(layer.NewLinear("fc1", 3, 1))
+	w := []float32{1, 2, 3, 4, 5, 6}
+	report, err := net.ImportTensors(map[string]*tensor.Tensor{
+		"layers.0.weight": tensor.FromFloat32(w, tensor.WithShapes(2, 3)),
+		"layers.1.weight": tensor.FromFloat32([]float32{1, 2}, tensor.WithShapes(1, 2)),
+		"layers.0.bias":   tensor.FromFloat32([]float32{0, 0, 0}, tensor.WithShapes(3)),
+	}, []MappingRule{
+		{Pattern: `layers\.(\d+)\.weight`, Layer: "fc$1", Param: "w", Transpose: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Imported["layers.0.weight"] != "fc0.w" {
+		t.Fatalf("invalid imported: %v", report.Imported)
+	}
+	if len(report.Unmapped) != 1 || report.Unmapped[0] != "layers.0.bias" {
+		t.Fatalf("invalid unmapped: %v", report.Unmapped)
+	}
+	if len(report.Mismatched) != 1 || len(report.Missing) != 1 || report.Missing[0] != "fc1.w" {
+		t.Fatalf("invalid report: %v, %v", report.Mismatched, report.Missing)
+	}
+	if report.Err() == nil {
+		t.Fatal("expect error of report")
+	}
+	got := net.Layers()[0].Params()["w"].Float32Value()
+	want := []float32{1, 4, 2, 5, 3, 6}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("invalid param: %v", got)
+		}
+	}
+}
+
+func TestImportLinear(t *testing.T) {
+	// nn.Linear(3, 2).weight of pytorch is (out, in), the same as linear layer,
+	// tensors stored as (in, out) need Transpose
+	w := []float32{1, 2, 3, 4, 5, 6}
+	var net Net
+	net.Add(layer.NewLinear("fc", 3, 2))
+	net.Add(layer.NewLinear("fc_t", 3, 2))
+	report, err := net.ImportTensors(map[string]*tensor.Tensor{
+		"fc.weight":   tensor.FromFloat32(w, tensor.WithShapes(2, 3)),
+		"fc_t.weight": tensor.FromFloat32([]float32{1, 4, 2, 5, 3, 6}, tensor.WithShapes(3, 2)),
+	}, []MappingRule{
+		{Pattern: `fc\.weight`, Layer: "fc", Param: "w"},
+		{Pattern: `fc_t\.weight`, Layer: "fc_t", Param: "w", Transpose: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = report.Err(); err != nil {
+		t.Fatal(err)
+	}
+	x := tensor.FromFloat32([]float32{1, 2, 3}, tensor.WithShapes(1, 3))
+	want := []float32{14, 32}
+	for _, l := range net.Layers() {
+		got := l.(*layer.Linear).Forward(x).Float32Value()
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("%s: expect %v, got %v", l.Name(), want, got)
+			}
+		}
+	}
+}
+
+func TestImportAttention(t *testing.T) {
+	var net Net
+	net.Add(layer.NewAttention("attn0", 2, 1, 0, false))
+	// rows of q, k and v projections
+	inProj := []float32{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+	report, err := net.ImportTensors(map[string]*tensor.Tensor{
+		"layers.0.self_attn.in_proj_weight": tensor.FromFloat32(inProj, tensor.WithShapes(6, 2)),
+	}, []MappingRule{
+		{Pattern: `layers\.(\d+)\.self_attn\.in_proj_weight`, Layer: "attn$1", Param: "w", BlockDiag: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = report.Err(); err != nil {
+		t.Fatal(err)
+	}
+	l := net.Layers()[0].(*layer.Attention)
+	want := []float32{
+		1, 2, 0, 0, 0, 0,
+		3, 4, 0, 0, 0, 0,
+		0, 0, 5, 6, 0, 0,
+		0, 0, 7, 8, 0, 0,
+		0, 0, 0, 0, 9, 10,
+		0, 0, 0, 0, 11, 12,
+	}
+	got := l.Params()["w"].Float32Value()
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("invalid param: %v", got)
+		}
+	}
+	// softmax of one key is 1, so the output is the v projection of x
+	x := tensor.FromFloat32([]float32{1, 2}, tensor.WithShapes(1, 1, 2))
+	y := l.Forward(x, x, x, nil, false, false).Float32Value()
+	if y[0] != 29 || y[1] != 35 {
+		t.Fatalf("expect [29 35], got %v", y)
+	}
+}
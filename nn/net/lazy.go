@@ -0,0 +1,165 @@
+package net
+
+import (
+	"fmt"
+
+	"github.com/lwch/tnn/internal/pb"
+	"github.com/lwch/tnn/nn/layer"
+)
+
+// lazyLoader load layers on first access, the file is kept open until
+// all layers are loaded, it is guarded by mu of Net
+type lazyLoader struct {
+	spec    *pb.Net
+	load    paramLoader
+	options loadOptions
+	close   func() error
+	err     error // last error of loading by methods which can not return error
+}
+
+// WithLazyLoad load params of each layer on first access instead of loading
+// all of them at once, only works with Load
+func WithLazyLoad() LoadOption {
+	return func(opts *loadOptions) {
+		opts.lazy = true
+	}
+}
+
+func (n *Net) loadLazy(spec *pb.Net, load paramLoader, options loadOptions, close func() error) error {
+	spec, err := filterSpec(spec, options.layers)
+	if err != nil {
+		return err
+	}
+	for i, l := range spec.GetLayers() {
+		if loadFuncs[l.GetClass()] == nil {
+			return fmt.Errorf("layer %d(%s): unsupported %s layer", i, l.GetName(), l.GetClass())
+		}
+		if err := checkParamNames(l); err != nil {
+			return fmt.Errorf("layer %d(%s): %w", i, l.GetName(), err)
+		}
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.release()
+	n.layers = make([]layer.Layer, len(spec.GetLayers()))
+	n.graph = nil
+	n.lazy = &lazyLoader{
+		spec:    spec,
+		load:    load,
+		options: options,
+		close:   close,
+	}
+	return nil
+}
+
+// release close file of lazily loaded net, n.mu must be held
+func (n *Net) release() {
+	if n.lazy != nil {
+		n.lazy.close()
+		n.lazy = nil
+	}
+}
+
+// loadAt load the i-th layer, n.mu must be held
+func (n *Net) loadAt(i int) (layer.Layer, error) {
+	if n.layers[i] != nil {
+		return n.layers[i], nil
+	}
+	spec := n.lazy.spec.GetLayers()[i]
+	l, err := n.loadLayer(spec, loadFuncs[spec.GetClass()], n.lazy.load, &n.lazy.options)
+	if err != nil {
+		return nil, fmt.Errorf("layer %d(%s): %w", i, spec.GetName(), err)
+	}
+	n.layers[i] = l
+	return l, nil
+}
+
+// layerAt get the i-th layer, load it first for lazily loaded net
+func (n *Net) layerAt(i int) (layer.Layer, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.lazy == nil {
+		return n.layers[i], nil
+	}
+	return n.loadAt(i)
+}
+
+// Materialize load all layers of lazily loaded net and close the file,
+// it does nothing when the net is not loaded with WithLazyLoad
+func (n *Net) Materialize() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.materialize()
+}
+
+// materialize load all layers, n.mu must be held
+func (n *Net) materialize() error {
+	lazy := n.lazy
+	if lazy == nil {
+		return nil
+	}
+	for i := range n.layers {
+		if _, err := n.loadAt(i); err != nil {
+			lazy.err = err
+			return err
+		}
+	}
+	if lazy.spec.GetGraph() != nil {
+		graph, err := decodeGraph(lazy.spec.GetGraph(), lazy.spec.GetModules(), n.layers)
+		if err != nil {
+			lazy.err = err
+			return err
+		}
+		n.graph = graph
+	}
+	n.lazy = nil
+	return lazy.close()
+}
+
+// Err get the last error of loading layers of lazily loaded net by Layers,
+// Params, Graph or SetGraph, which skip layers failed to load,
+// returns nil when all layers are loaded
+func (n *Net) Err() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.lazy == nil {
+		return nil
+	}
+	return n.lazy.err
+}
+
+// loadedLayers get layers which are loaded, n.mu must be held
+func (n *Net) loadedLayers() []layer.Layer {
+	if n.lazy == nil {
+		return n.layers
+	}
+	ret := make([]layer.Layer, 0, len(n.layers))
+	for _, l := range n.layers {
+		if l != nil {
+			ret = append(ret, l)
+		}
+	}
+	return ret
+}
+
+func (n *Net) lazyParamCount() (uint64, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.lazy == nil {
+		return 0, false
+	}
+	var ret uint64
+	specs := n.lazy.spec.GetLayers()
+	for i, l := range n.layers {
+		if l != nil {
+			for _, p := range l.Params() {
+				ret += uint64(p.ElemCount())
+			}
+			continue
+		}
+		for _, p := range specs[i].GetParams() {
+			ret += uint64(p.GetElemCount())
+		}
+	}
+	return ret, true
+}
@@ -4,25 +4,34 @@ import (
 	"archive/zip"
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"path/filepath"
+	"reflect"
 	"sync"
 	"time"
 
 	"github.com/klauspost/compress/zstd"
 	"github.com/lwch/gotorch/consts"
 	"github.com/lwch/gotorch/tensor"
-	"github.com/lwch/runtime"
+	"github.com/lwch/tnn/internal/mmap"
+	"github.com/lwch/tnn/internal/nnutil"
 	"github.com/lwch/tnn/internal/pb"
 	"github.com/lwch/tnn/nn/layer"
 	"github.com/lwch/tnn/nn/layer/activation"
+	"github.com/lwch/tnn/nn/preprocessing"
 	"google.golang.org/protobuf/proto"
 )
 
 type loadFunc func(name string, params map[string]*tensor.Tensor, args map[string]float32) layer.Layer
 
-var loadFuncs = map[string]loadFunc{
+// loadOptionFunc load function receives device and param type of the layer
+type loadOptionFunc func(name string, params map[string]*tensor.Tensor, args map[string]float32, opts ...layer.LayerCreateOption) layer.Layer
+
+var loadFuncs = map[string]loadOptionFunc{
 	"linear":     layer.LoadLinear,
 	"dropout":    layer.LoadDropout,
 	"conv1d":     layer.LoadConv1D,
@@ -37,6 +46,13 @@ var loadFuncs = map[string]loadFunc{
 	"flatten":    layer.LoadFlatten,
 	"embedding":  layer.LoadEmbedding,
 	"rezero":     layer.LoadReZero,
+	// quantized
+	"qlinear":     layer.LoadQLinear,
+	"qconv1d":     layer.LoadQConv1D,
+	"qconv2d":     layer.LoadQConv2D,
+	"qembedding":  layer.LoadQEmbedding,
+	"q4linear":    layer.LoadQ4Linear,
+	"q4attention": layer.LoadQ4Attention,
 	// activation
 	"sigmoid": activation.LoadSigmoid,
 	"tanh":    activation.LoadTanh,
@@ -44,30 +60,120 @@ var loadFuncs = map[string]loadFunc{
 	"gelu":    activation.LoadGelu,
 }
 
-func RegisterLoadFunc(class string, fn loadFunc) {
+// paramNames expected param names of each class, used to validate model file
+var paramNames = map[string][]string{
+	"linear":     {"w"},
+	"dropout":    {},
+	"conv1d":     {"w"},
+	"conv2d":     {"w", "b"},
+	"maxpool1d":  {},
+	"rnn":        {"w", "b"},
+	"lstm":       {"Wi", "Wf", "Wg", "Wo", "Bi", "Bf", "Bg", "Bo"},
+	"attention":  {"w"},
+	"attention1": {"w"},
+	"layer_norm": {"a"},
+	"rms_norm":   {"a"},
+	"flatten":    {},
+	"embedding":  {"w"},
+	"rezero":     {"scale"},
+	// quantized
+	"qlinear":     {"w", "scale", "zero"},
+	"qconv1d":     {"w", "scale", "zero"},
+	"qconv2d":     {"w", "scale", "zero", "b"},
+	"qembedding":  {"w", "scale", "zero"},
+	"q4linear":    {"w", "scale", "zero"},
+	"q4attention": {"w", "scale", "zero"},
+	// activation
+	"sigmoid": {},
+	"tanh":    {},
+	"relu":    {},
+	"gelu":    {},
+}
+
+// RegisterLoadFunc register load function of custom layer,
+// params is the expected param names used to validate model file,
+// validation is skipped when params is empty
+func RegisterLoadFunc(class string, fn loadFunc, params ...string) {
+	RegisterLoadOptionFunc(class, func(name string, params map[string]*tensor.Tensor, args map[string]float32, _ ...layer.LayerCreateOption) layer.Layer {
+		return fn(name, params, args)
+	}, params...)
+}
+
+// RegisterLoadOptionFunc register load function of custom layer, fn receives
+// device and param type of the layer by options, e.g. layer.WithDevice
+func RegisterLoadOptionFunc(class string, fn loadOptionFunc, params ...string) {
 	loadFuncs[class] = fn
+	if len(params) > 0 {
+		paramNames[class] = params
+	} else {
+		delete(paramNames, class)
+	}
 }
 
 type Net struct {
+	mu     sync.Mutex // guards layers, graph and lazy
 	layers []layer.Layer
+	graph  *Graph
 	device consts.DeviceType
+	lazy   *lazyLoader
+
+	preprocess *preprocessing.Pipeline
 }
 
 func New(device consts.DeviceType) *Net {
 	return &Net{device: device}
 }
 
+// SetDevice set default device, it is used when loading model file without device info
 func (n *Net) SetDevice(device consts.DeviceType) {
 	n.device = device
 }
 
+// Add append layers, layers of lazily loaded net are not loaded
 func (n *Net) Add(layers ...layer.Layer) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
 	n.layers = append(n.layers, layers...)
 }
 
+// SetGraph set topology of the net, layers used by graph will be added automatically,
+// the graph saved in file of lazily loaded net is replaced
+func (n *Net) SetGraph(g *Graph) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.materialize() // error is kept for Err
+	if n.lazy != nil {
+		n.lazy.spec = &pb.Net{Layers: n.lazy.spec.GetLayers()}
+	}
+	exists := make(map[layer.Layer]bool, len(n.layers))
+	for _, l := range n.layers {
+		exists[l] = true
+	}
+	for _, l := range g.Layers() {
+		if !exists[l] {
+			n.layers = append(n.layers, l)
+		}
+	}
+	n.graph = g
+}
+
+// Graph get topology of the net, returns nil for sequential net,
+// layers of lazily loaded net are loaded, use Err to check error of loading
+func (n *Net) Graph() *Graph {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.materialize() // error is kept for Err
+	return n.graph
+}
+
+// Params get params of all layers, layers of lazily loaded net are loaded,
+// layers failed to load are skipped, use Err to check error of loading
 func (n *Net) Params() []*tensor.Tensor {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.materialize() // error is kept for Err
 	var ret []*tensor.Tensor
-	for _, l := range n.layers {
+	for _, l := range n.loadedLayers() {
 		for _, p := range l.Params() {
 			ret = append(ret, p)
 		}
@@ -75,8 +181,40 @@ func (n *Net) Params() []*tensor.Tensor {
 	return ret
 }
 
+// MapParams rebuild layers with params returned by fn, fn gets index of layer,
+// name of param and the param currently used, it may be called concurrently.
+// Layers are updated in place, so layers got by Layers before keep valid,
+// frozen layers keep frozen
+func (n *Net) MapParams(fn func(i int, name string, t *tensor.Tensor) *tensor.Tensor, opts ...LoadOption) error {
+	spec, err := n.buildSpec(paramFile)
+	if err != nil {
+		return err
+	}
+	current := n.currentParams()
+	type key struct {
+		layer int
+		name  string
+	}
+	keys := make(map[string]key, len(current))
+	for i, l := range spec.GetLayers() {
+		for name := range l.GetParams() {
+			keys[paramFile(i, name)] = key{i, name}
+		}
+	}
+	return n.build(spec, func(param *pb.Param, device consts.DeviceType) (*tensor.Tensor, error) {
+		k := keys[param.GetFile()]
+		return fn(k.layer, k.name, current[param.GetFile()]).ToDevice(device), nil
+	}, append(opts, func(opts *loadOptions) {
+		opts.inPlace = true
+	})...)
+}
+
+// ParamCount get total elem count of params, layers are not loaded for lazily loaded net
 func (n *Net) ParamCount() uint64 {
 	var ret uint64
+	if cnt, ok := n.lazyParamCount(); ok {
+		return cnt
+	}
 	for _, l := range n.layers {
 		for _, p := range l.Params() {
 			ret += uint64(p.ElemCount())
@@ -85,51 +223,156 @@ func (n *Net) ParamCount() uint64 {
 	return ret
 }
 
-func (n *Net) Save(dir string) error {
-	f, err := os.Create(dir)
+type saveOptions struct {
+	method uint16
+}
+
+// SaveOption option of saving model
+type SaveOption func(*saveOptions)
+
+// WithStore store params without compression,
+// so they are copied from memory mapped file without decompression when loading
+func WithStore() SaveOption {
+	return func(opts *saveOptions) {
+		opts.method = zip.Store
+	}
+}
+
+// Save write model to file, the file is replaced atomically
+func (n *Net) Save(dir string, opts ...SaveOption) error {
+	options := saveOptions{method: zip.Deflate}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return atomicWrite(dir, func(w io.Writer) error {
+		zw := newZipWriter(w)
+		if _, err := n.writeTo(zw, options.method); err != nil {
+			return err
+		}
+		return zw.Close()
+	})
+}
+
+// atomicWrite write to a temporary file then rename it to dir,
+// so the old file is kept when crashed while writing
+func atomicWrite(dir string, fn func(w io.Writer) error) error {
+	f, err := os.CreateTemp(filepath.Dir(dir), filepath.Base(dir)+".*.tmp")
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	_, err = n.WriteTo(f)
-	return err
+	tmp := f.Name()
+	defer os.Remove(tmp)
+	if err = f.Chmod(0644); err != nil {
+		f.Close()
+		return err
+	}
+	if err = fn(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dir)
 }
 
-func (n *Net) WriteTo(w io.Writer) (int64, error) {
+func writeParam(zw *zip.Writer, file string, param *tensor.Tensor, method uint16) error {
+	f, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     file,
+		Method:   method,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	return writeTensor(f, binary.BigEndian, param)
+}
+
+func newZipWriter(w io.Writer) *zip.Writer {
 	zw := zip.NewWriter(w)
-	defer zw.Close()
 	zw.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
 		return zstd.NewWriter(w)
 	})
+	return zw
+}
+
+func (n *Net) WriteTo(w io.Writer) (int64, error) {
+	zw := newZipWriter(w)
+	defer zw.Close()
+	return n.writeTo(zw, zip.Deflate)
+}
+
+func paramFile(i int, name string) string {
+	return fmt.Sprintf("layer_%d_param_%s.bin", i, name)
+}
+
+func newParam(name, file string, t *tensor.Tensor) *pb.Param {
+	var param pb.Param
+	param.Type = uint32(t.ScalarType())
+	param.ElemCount = t.ElemCount()
+	param.Name = name
+	param.Shapes = make([]int64, t.Dims())
+	copy(param.Shapes, t.Shapes())
+	param.File = file
+	return &param
+}
+
+// buildSpec build SPEC of the net, file is the storage name of each param
+func (n *Net) buildSpec(file func(i int, name string) string) (*pb.Net, error) {
+	if err := n.Materialize(); err != nil {
+		return nil, err
+	}
 	var net pb.Net
 	net.Layers = make([]*pb.Layer, len(n.layers))
-	type layer struct {
-		names  []string
-		params []*tensor.Tensor
-	}
-	var layers []layer
 	for i := 0; i < len(n.layers); i++ {
 		net.Layers[i] = new(pb.Layer)
 		net.Layers[i].Class = n.layers[i].Class()
 		net.Layers[i].Name = n.layers[i].Name()
 		net.Layers[i].Params = make(map[string]*pb.Param)
-		var layer layer
 		for name, p := range n.layers[i].Params() {
-			var param pb.Param
-			param.Type = uint32(p.ScalarType())
-			param.ElemCount = p.ElemCount()
-			param.Name = name
-			param.Shapes = make([]int64, p.Dims())
-			copy(param.Shapes, p.Shapes())
-			param.File = fmt.Sprintf("layer_%d_param_%s.bin", i, name)
-			layer.names = append(layer.names, name)
-			layer.params = append(layer.params, p)
-			net.Layers[i].Params[name] = &param
-		}
-		layers = append(layers, layer)
+			net.Layers[i].Params[name] = newParam(name, file(i, name), p)
+		}
+		if l, ok := n.layers[i].(layer.PackedLayer); ok {
+			for name, packing := range l.Packings() {
+				param := net.Layers[i].Params[name]
+				if param == nil {
+					return nil, fmt.Errorf("layer %d(%s): packed param %s not found", i, l.Name(), name)
+				}
+				param.Quant = &pb.Quant{
+					Bits:      uint32(packing.Bits),
+					GroupSize: int64(packing.GroupSize),
+					Shapes:    packing.Shapes,
+				}
+			}
+		}
 		net.Layers[i].Args = n.layers[i].Args()
+		if l, ok := n.layers[i].(deviceLayer); ok {
+			device := uint32(l.Device())
+			paramType := uint32(l.ParamType())
+			net.Layers[i].Device = &device
+			net.Layers[i].ParamType = &paramType
+		}
 	}
-	data, err := proto.Marshal(&net)
+	if n.graph != nil {
+		var err error
+		net.Graph, net.Modules, err = encodeGraph(n.graph, n.layers)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &net, nil
+}
+
+func (n *Net) writeTo(zw *zip.Writer, method uint16) (int64, error) {
+	net, err := n.buildSpec(paramFile)
+	if err != nil {
+		return 0, err
+	}
+	data, err := proto.Marshal(net)
 	if err != nil {
 		return 0, err
 	}
@@ -145,45 +388,12 @@ func (n *Net) WriteTo(w io.Writer) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
-	for i, layer := range layers {
-		for j := 0; j < len(layer.names); j++ {
-			name := layer.names[j]
-			param := layer.params[j]
-			err = func() error {
-				f, err := zw.CreateHeader(&zip.FileHeader{
-					Name:     fmt.Sprintf("layer_%d_param_%s.bin", i, name),
-					Method:   zip.Deflate,
-					Modified: time.Now(),
-				})
-				if err != nil {
-					return err
-				}
-				param = param.ToDevice(consts.KCPU)
-				switch param.ScalarType() {
-				case consts.KUint8:
-					return binary.Write(f, binary.BigEndian, param.Uint8Value())
-				case consts.KInt8:
-					return binary.Write(f, binary.BigEndian, param.Int8Value())
-				case consts.KInt16:
-					return binary.Write(f, binary.BigEndian, param.Int16Value())
-				case consts.KInt32:
-					return binary.Write(f, binary.BigEndian, param.Int32Value())
-				case consts.KInt64:
-					return binary.Write(f, binary.BigEndian, param.Int64Value())
-				case consts.KHalf:
-					return binary.Write(f, binary.BigEndian, param.HalfRaw())
-				case consts.KFloat:
-					return binary.Write(f, binary.BigEndian, param.Float32Value())
-				case consts.KDouble:
-					return binary.Write(f, binary.BigEndian, param.Float64Value())
-				case consts.KBool:
-					return binary.Write(f, binary.BigEndian, param.BoolValue())
-				case consts.KBFloat16:
-					return binary.Write(f, binary.BigEndian, param.BFloat16Raw())
-				default:
-					panic(fmt.Errorf("unsupported scalar type: %s", param.ScalarType().String()))
-				}
-			}()
+	if err = n.writePreprocess(zw); err != nil {
+		return 0, err
+	}
+	for i, layer := range n.layers {
+		for name, param := range layer.Params() {
+			err = writeParam(zw, paramFile(i, name), param, method)
 			if err != nil {
 				return 0, err
 			}
@@ -193,18 +403,157 @@ func (n *Net) WriteTo(w io.Writer) (int64, error) {
 	return cnt, nil
 }
 
-func (n *Net) Load(dir string) error {
-	f, err := os.Open(dir)
+type deviceLayer interface {
+	Device() consts.DeviceType
+	ParamType() consts.ScalarType
+}
+
+type loadOptions struct {
+	deviceMap map[consts.DeviceType]consts.DeviceType
+	device    *consts.DeviceType
+	paramType *consts.ScalarType
+	lazy      bool
+	layers    []string
+	inPlace   bool // update layers of the same type in place instead of replacing them
+}
+
+// LoadOption option of loading model
+type LoadOption func(*loadOptions)
+
+// WithMapLocation load all layers onto device
+func WithMapLocation(device consts.DeviceType) LoadOption {
+	return func(opts *loadOptions) {
+		opts.device = &device
+	}
+}
+
+// WithDeviceMap remap recorded device of layers, e.g. {KCUDA: KCPU}
+func WithDeviceMap(m map[consts.DeviceType]consts.DeviceType) LoadOption {
+	return func(opts *loadOptions) {
+		opts.deviceMap = m
+	}
+}
+
+// WithLoadParamType convert floating point params to t while loading
+func WithLoadParamType(t consts.ScalarType) LoadOption {
+	return func(opts *loadOptions) {
+		opts.paramType = &t
+	}
+}
+
+// WithLayers only load layers with given names, e.g. for pipeline stages,
+// layers are kept in the order of model file and graph is not loaded
+func WithLayers(names ...string) LoadOption {
+	return func(opts *loadOptions) {
+		opts.layers = names
+	}
+}
+
+// filterSpec keep layers in names, all layers are kept when names is empty
+func filterSpec(spec *pb.Net, names []string) (*pb.Net, error) {
+	if len(names) == 0 {
+		return spec, nil
+	}
+	keep := make(map[string]bool, len(names))
+	for _, name := range names {
+		keep[name] = true
+	}
+	var ret pb.Net
+	for _, l := range spec.GetLayers() {
+		if keep[l.GetName()] {
+			ret.Layers = append(ret.Layers, l)
+			delete(keep, l.GetName())
+		}
+	}
+	for _, name := range names {
+		if keep[name] {
+			return nil, fmt.Errorf("layer %s not found", name)
+		}
+	}
+	return &ret, nil
+}
+
+// getDevice get target device of layer
+func (opts *loadOptions) getDevice(spec *pb.Layer, def consts.DeviceType) consts.DeviceType {
+	if opts.device != nil {
+		return *opts.device
+	}
+	device := def
+	if spec.Device != nil {
+		device = consts.DeviceType(spec.GetDevice())
+	}
+	if to, ok := opts.deviceMap[device]; ok {
+		return to
+	}
+	return device
+}
+
+// getParamType get target param type of layer, returns false when not recorded
+func (opts *loadOptions) getParamType(spec *pb.Layer) (consts.ScalarType, bool) {
+	if opts.paramType != nil {
+		return *opts.paramType, true
+	}
+	if spec.ParamType != nil {
+		return consts.ScalarType(spec.GetParamType()), true
+	}
+	return 0, false
+}
+
+// Load load model from file or index of sharded files, the file is memory mapped
+// while loading, params stored by WithStore are copied from the mapped memory without decompression
+func (n *Net) Load(dir string, opts ...LoadOption) error {
+	data, unmap, err := mmap.Open(dir)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	fi, err := f.Stat()
+	var spec *pb.Net
+	var load paramLoader
+	var preprocess []byte
+	cleanup := unmap
+	if isShardIndex(data) {
+		var shards *shardReader
+		spec, preprocess, shards, err = openShardIndex(dir, data)
+		unmap()
+		if err != nil {
+			return fmt.Errorf("open %s: %v", dir, err)
+		}
+		load, cleanup = shards.load, shards.Close
+	} else {
+		zr, err := newZipReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			unmap()
+			return fmt.Errorf("open %s: %v", dir, err)
+		}
+		spec, err = n.readSpec(zr)
+		if err == nil {
+			preprocess, err = readPreprocess(zr)
+		}
+		if err != nil {
+			unmap()
+			return err
+		}
+		load = zipLoader(zr)
+	}
+	pipeline, err := decodePreprocess(preprocess)
+	if err != nil {
+		cleanup()
+		return err
+	}
+	var options loadOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if !options.lazy {
+		defer cleanup()
+		err = n.build(spec, load, opts...)
+	} else if err = n.loadLazy(spec, load, options, cleanup); err != nil {
+		cleanup()
+	}
 	if err != nil {
 		return err
 	}
-	_, err = n.ReadFrom(f, fi.Size())
-	return err
+	n.preprocess = pipeline
+	return nil
 }
 
 func (n *Net) readSpec(r *zip.Reader) (*pb.Net, error) {
@@ -224,94 +573,360 @@ func (n *Net) readSpec(r *zip.Reader) (*pb.Net, error) {
 	return &net, nil
 }
 
-func buildParam[T uint8 | int8 | int16 | uint16 | int32 | int64 |
-	float32 | float64 | bool](r io.Reader, cnt int64, shapes []int64, device consts.DeviceType,
-	fn func(data []T, opts ...tensor.Option) *tensor.Tensor) (*tensor.Tensor, error) {
-	data := make([]T, cnt)
-	if err := binary.Read(r, binary.BigEndian, data); err != nil {
-		return nil, err
+func elemSize(t consts.ScalarType) int64 {
+	switch t {
+	case consts.KUint8, consts.KInt8, consts.KBool:
+		return 1
+	case consts.KInt16, consts.KHalf, consts.KBFloat16:
+		return 2
+	case consts.KInt32, consts.KFloat:
+		return 4
+	case consts.KInt64, consts.KDouble:
+		return 8
+	default:
+		return 0
 	}
-	t := fn(data,
-		tensor.WithShapes(shapes...),
-		tensor.WithDevice(device))
-	t.SetRequiresGrad(true)
-	return t, nil
 }
 
-func (n *Net) loadParam(r *zip.Reader, file string, t consts.ScalarType, cnt int64, shapes []int64) (*tensor.Tensor, error) {
+func checkShapes(cnt int64, shapes []int64) error {
+	if cnt < 0 {
+		return fmt.Errorf("invalid elem count: %d", cnt)
+	}
+	size := int64(1)
+	for _, s := range shapes {
+		if s < 0 {
+			return fmt.Errorf("invalid shapes: %v", shapes)
+		}
+		if s > 0 && size > math.MaxInt64/s {
+			return fmt.Errorf("shapes %v overflow", shapes)
+		}
+		size *= s
+	}
+	if size != cnt {
+		return fmt.Errorf("shapes %v mismatch elem count %d", shapes, cnt)
+	}
+	return nil
+}
+
+func loadParam(r *zip.Reader, file string, t consts.ScalarType, cnt int64, shapes []int64, device consts.DeviceType) (*tensor.Tensor, error) {
+	size := elemSize(t)
+	if size == 0 {
+		return nil, fmt.Errorf("unsupported scalar type: %s", t.String())
+	}
+	if err := checkShapes(cnt, shapes); err != nil {
+		return nil, err
+	}
 	f, err := r.Open(file)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("open %s: %v", file, err)
 	}
 	defer f.Close()
-	switch t {
-	case consts.KUint8:
-		return buildParam[uint8](f, cnt, shapes, n.device, tensor.FromUint8)
-	case consts.KInt8:
-		return buildParam[int8](f, cnt, shapes, n.device, tensor.FromInt8)
-	case consts.KInt16:
-		return buildParam[int16](f, cnt, shapes, n.device, tensor.FromInt16)
-	case consts.KInt32:
-		return buildParam[int32](f, cnt, shapes, n.device, tensor.FromInt32)
-	case consts.KInt64:
-		return buildParam[int64](f, cnt, shapes, n.device, tensor.FromInt64)
-	case consts.KHalf:
-		return buildParam[uint16](f, cnt, shapes, n.device, tensor.FromHalfRaw)
-	case consts.KFloat:
-		return buildParam[float32](f, cnt, shapes, n.device, tensor.FromFloat32)
-	case consts.KDouble:
-		return buildParam[float64](f, cnt, shapes, n.device, tensor.FromFloat64)
-	case consts.KBool:
-		return buildParam[bool](f, cnt, shapes, n.device, tensor.FromBool)
-	case consts.KBFloat16:
-		return buildParam[uint16](f, cnt, shapes, n.device, tensor.FromBFloat16Raw)
-	default:
-		panic(fmt.Errorf("unsupported scalar type: %s", t.String()))
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
 	}
+	if fi.Size() != cnt*size {
+		return nil, fmt.Errorf("invalid size of %s: expect %d bytes, got %d",
+			file, cnt*size, fi.Size())
+	}
+	return readTensor(f, binary.BigEndian, t, cnt, shapes, device)
 }
 
-func (n *Net) ReadFrom(r io.ReaderAt, size int64) (int64, error) {
+func newZipReader(r io.ReaderAt, size int64) (*zip.Reader, error) {
 	zr, err := zip.NewReader(r, size)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	zr.RegisterDecompressor(zip.Deflate, func(r io.Reader) io.ReadCloser {
 		zr, err := zstd.NewReader(r)
-		runtime.Assert(err)
-		return io.NopCloser(zr)
+		if err != nil {
+			return io.NopCloser(errReader{err})
+		}
+		return zr.IOReadCloser()
 	})
+	return zr, nil
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func (n *Net) ReadFrom(r io.ReaderAt, size int64, opts ...LoadOption) (int64, error) {
+	zr, err := newZipReader(r, size)
+	if err != nil {
+		return 0, err
+	}
+	return n.readFrom(zr, size, opts...)
+}
+
+func (n *Net) readFrom(zr *zip.Reader, size int64, opts ...LoadOption) (int64, error) {
 	spec, err := n.readSpec(zr)
 	if err != nil {
 		return 0, err
 	}
-	layers := spec.GetLayers()
-	n.layers = make([]layer.Layer, len(layers))
+	data, err := readPreprocess(zr)
+	if err != nil {
+		return 0, err
+	}
+	pipeline, err := decodePreprocess(data)
+	if err != nil {
+		return 0, err
+	}
+	err = n.build(spec, zipLoader(zr), opts...)
+	if err != nil {
+		return 0, err
+	}
+	n.preprocess = pipeline
+	return size, nil
+}
+
+type paramLoader func(param *pb.Param, device consts.DeviceType) (*tensor.Tensor, error)
+
+func zipLoader(zr *zip.Reader) paramLoader {
+	return func(param *pb.Param, device consts.DeviceType) (*tensor.Tensor, error) {
+		return loadParam(zr,
+			param.GetFile(),
+			consts.ScalarType(param.GetType()),
+			param.GetElemCount(),
+			param.GetShapes(),
+			device)
+	}
+}
+
+// build create layers and graph from SPEC, params are loaded by load
+func (n *Net) build(spec *pb.Net, load paramLoader, opts ...LoadOption) error {
+	var options loadOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	spec, err := filterSpec(spec, options.layers)
+	if err != nil {
+		return err
+	}
+	specs := spec.GetLayers()
+	layers := make([]layer.Layer, len(specs))
+	errs := make([]error, len(specs))
 	var wg sync.WaitGroup
-	wg.Add(len(layers))
-	for i := 0; i < len(layers); i++ {
-		class := layers[i].GetClass()
-		fn := loadFuncs[class]
+	for i := 0; i < len(specs); i++ {
+		fn := loadFuncs[specs[i].GetClass()]
 		if fn == nil {
-			panic("unsupported " + class + " layer")
+			errs[i] = fmt.Errorf("layer %d(%s): unsupported %s layer",
+				i, specs[i].GetName(), specs[i].GetClass())
+			continue
 		}
+		wg.Add(1)
 		go func(i int) {
 			defer wg.Done()
-			params := make(map[string]*tensor.Tensor)
-			for _, param := range layers[i].GetParams() {
-				params[param.GetName()], err = n.loadParam(zr,
-					param.GetFile(),
-					consts.ScalarType(param.GetType()),
-					param.GetElemCount(),
-					param.GetShapes())
-				runtime.Assert(err)
+			var err error
+			layers[i], err = n.loadLayer(specs[i], fn, load, &options)
+			if err != nil {
+				errs[i] = fmt.Errorf("layer %d(%s): %w", i, specs[i].GetName(), err)
 			}
-			n.layers[i] = fn(layers[i].GetName(), params, layers[i].GetArgs())
 		}(i)
 	}
 	wg.Wait()
-	return size, nil
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	if options.inPlace {
+		n.mu.Lock()
+		for i, l := range layers {
+			// rebuilt layers are unfrozen by loadLayer
+			frozen := isFrozen(n.layers, i)
+			layers[i] = reuseLayer(n.layers, i, l)
+			if frozen {
+				layers[i].Freeze()
+			}
+		}
+		n.mu.Unlock()
+	}
+	var graph *Graph
+	if spec.GetGraph() != nil {
+		var err error
+		graph, err = decodeGraph(spec.GetGraph(), spec.GetModules(), layers)
+		if err != nil {
+			return err
+		}
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.release()
+	n.layers = layers
+	n.graph = graph
+	return nil
 }
 
+// reuseLayer copy l into the i-th layer of old when they are in the same type
+// and returns the old one, so pointers to it keep valid, otherwise returns l
+func reuseLayer(old []layer.Layer, i int, l layer.Layer) layer.Layer {
+	if i >= len(old) || old[i] == nil {
+		return l
+	}
+	dst, src := reflect.ValueOf(old[i]), reflect.ValueOf(l)
+	if dst.Type() != src.Type() || dst.Kind() != reflect.Pointer || dst.IsNil() {
+		return l
+	}
+	dst.Elem().Set(src.Elem())
+	return old[i]
+}
+
+// isFrozen check whether old[i] is frozen by Freeze
+func isFrozen(old []layer.Layer, i int) bool {
+	if i >= len(old) || old[i] == nil {
+		return false
+	}
+	f, ok := old[i].(interface{ Frozen() bool })
+	return ok && f.Frozen()
+}
+
+func checkParamNames(spec *pb.Layer) error {
+	names, ok := paramNames[spec.GetClass()]
+	if !ok {
+		return nil
+	}
+	params := spec.GetParams()
+	for _, name := range names {
+		if _, ok := params[name]; !ok {
+			return fmt.Errorf("missing param %s", name)
+		}
+	}
+	if len(params) != len(names) {
+		expected := make(map[string]bool, len(names))
+		for _, name := range names {
+			expected[name] = true
+		}
+		for name := range params {
+			if !expected[name] {
+				return fmt.Errorf("unexpected param %s", name)
+			}
+		}
+	}
+	return nil
+}
+
+func (n *Net) loadLayer(spec *pb.Layer, fn loadOptionFunc, load paramLoader, opts *loadOptions) (l layer.Layer, err error) {
+	if err = checkParamNames(spec); err != nil {
+		return nil, err
+	}
+	device := opts.getDevice(spec, n.device)
+	createOpts := []layer.LayerCreateOption{layer.WithDevice(device)}
+	paramType, ok := opts.getParamType(spec)
+	if ok {
+		if !nnutil.IsFloat(paramType) {
+			return nil, fmt.Errorf("unsupported param type: %s", paramType.String())
+		}
+		createOpts = append(createOpts, layer.WithParamType(paramType))
+	}
+	params := make(map[string]*tensor.Tensor)
+	for key, param := range spec.GetParams() {
+		if param.GetName() != key {
+			return nil, fmt.Errorf("param %s: mismatched name %s", key, param.GetName())
+		}
+		t, err := load(param, device)
+		if err != nil {
+			return nil, fmt.Errorf("param %s: %w", key, err)
+		}
+		if ok && nnutil.IsFloat(t.ScalarType()) && t.ScalarType() != paramType {
+			t = t.ToScalarType(paramType)
+		}
+		if nnutil.IsFloat(t.ScalarType()) {
+			// only floating point params require grad, int8 and packed 4 bits
+			// weights of quantized layers are frozen
+			t.SetRequiresGrad(true)
+		}
+		params[key] = t
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("load %s layer: %v", spec.GetClass(), e)
+		}
+	}()
+	l = fn(spec.GetName(), params, spec.GetArgs(), createOpts...)
+	return l, checkPackings(spec, l)
+}
+
+// checkPackings check quant encoding recorded in SPEC matches the packed params of layer
+func checkPackings(spec *pb.Layer, l layer.Layer) error {
+	var packings map[string]layer.Packing
+	if pl, ok := l.(layer.PackedLayer); ok {
+		packings = pl.Packings()
+	}
+	for name, param := range spec.GetParams() {
+		quant := param.GetQuant()
+		packing, ok := packings[name]
+		switch {
+		case quant == nil && !ok:
+			continue
+		case quant == nil:
+			return fmt.Errorf("param %s: missing quant encoding", name)
+		case !ok:
+			return fmt.Errorf("param %s: unexpected quant encoding", name)
+		}
+		if quant.GetBits() != uint32(packing.Bits) ||
+			quant.GetGroupSize() != int64(packing.GroupSize) ||
+			!nnutil.SameShapes(quant.GetShapes(), packing.Shapes) {
+			return fmt.Errorf("param %s: mismatched quant encoding", name)
+		}
+	}
+	return nil
+}
+
+// Layers get all layers, layers of lazily loaded net are loaded,
+// layers failed to load are skipped, use Err to check error of loading
 func (n *Net) Layers() []layer.Layer {
-	return n.layers
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.materialize() // error is kept for Err
+	return n.loadedLayers()
+}
+
+// Forward run the graph of the net, or run all layers in order when graph is not set,
+// outputs of each layer are the inputs of the next one
+func (n *Net) Forward(ctx *layer.Context, inputs ...*tensor.Tensor) ([]*tensor.Tensor, error) {
+	return n.forward(ctx, inputs, nil)
+}
+
+func (n *Net) forward(ctx *layer.Context, inputs []*tensor.Tensor, observe observer) (_ []*tensor.Tensor, err error) {
+	defer func() {
+		// invalid inputs of layers are returned as error
+		if r := recover(); r != nil {
+			e, ok := r.(*layer.InputError)
+			if !ok {
+				panic(r)
+			}
+			err = e
+		}
+	}()
+	n.mu.Lock()
+	if n.lazy != nil && n.lazy.spec.GetGraph() != nil {
+		if err := n.materialize(); err != nil {
+			n.mu.Unlock()
+			return nil, err
+		}
+	}
+	graph, size := n.graph, len(n.layers)
+	n.mu.Unlock()
+	if graph != nil {
+		return graph.forward(ctx, inputs, observe)
+	}
+	outputs := inputs
+	for i := 0; i < size; i++ {
+		l, err := n.layerAt(i)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := l.(layer.Module)
+		if !ok {
+			return nil, fmt.Errorf("layer %d(%s): %s layer can not forward", i, l.Name(), l.Class())
+		}
+		if observe != nil {
+			observe(l, outputs)
+		}
+		outputs = m.Call(ctx, outputs...)
+	}
+	return outputs, nil
 }
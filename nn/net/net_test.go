@@ -1,9 +1,22 @@
 package net
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
 	"testing"
 
+	"github.com/lwch/gotorch/consts"
+	"github.com/lwch/gotorch/tensor"
+	"github.com/lwch/tnn/internal/pb"
 	"github.com/lwch/tnn/nn/layer"
+	"github.com/lwch/tnn/nn/layer/activation"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestSave(t *testing.T) {
@@ -22,3 +35,359 @@ func TestLoad(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestForward(t *testing.T) {
+	var net Net
+	net.Add(layer.NewLinear("hidden", 2, 3))
+	net.Add(activation.NewReLU())
+	net.Add(layer.NewLinear("output", 3, 1))
+	x := tensor.FromFloat32([]float32{0, 0, 0, 1, 1, 0, 1, 1}, tensor.WithShapes(4, 2))
+	y, err := net.Forward(layer.NewContext(false), x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(y) != 1 {
+		t.Fatal("invalid outputs")
+	}
+	shapes := y[0].Shapes()
+	if len(shapes) != 2 || shapes[0] != 4 || shapes[1] != 1 {
+		t.Fatalf("invalid output shapes: %v", shapes)
+	}
+}
+
+func TestForwardInputs(t *testing.T) {
+	var net Net
+	net.Add(layer.NewLinear("linear", 2, 1))
+	_, err := net.Forward(layer.NewContext(false))
+	var e *layer.InputError
+	if !errors.As(err, &e) || e.Name != "linear" {
+		t.Fatalf("expect input error of linear layer, got %v", err)
+	}
+	var attn Net
+	attn.Add(layer.NewAttention1("attn", 4, 1, 0, false))
+	x := tensor.FromFloat32(make([]float32, 8), tensor.WithShapes(1, 2, 4))
+	if _, err = attn.Forward(layer.NewContext(false), x, x); !errors.As(err, &e) {
+		t.Fatalf("expect input error of attention layer, got %v", err)
+	}
+}
+
+func writeSpec(t *testing.T, spec *pb.Net, files map[string][]byte) *bytes.Reader {
+	var buf bytes.Buffer
+	zw := newZipWriter(&buf)
+	data, err := proto.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := zw.Create("SPEC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(data)
+	for name, data := range files {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(data)
+	}
+	if err = zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestLoadInvalid(t *testing.T) {
+	param := func(shapes ...int64) map[string]*pb.Param {
+		return map[string]*pb.Param{"w": {
+			Type:      uint32(consts.KFloat),
+			ElemCount: 6,
+			Name:      "w",
+			Shapes:    shapes,
+			File:      "layer_0_param_w.bin",
+		}}
+	}
+	cases := map[string]struct {
+		spec  *pb.Net
+		files map[string][]byte
+	}{
+		"unsupported class": {
+			spec: &pb.Net{Layers: []*pb.Layer{{Class: "unknown", Name: "x"}}},
+		},
+		"missing param": {
+			spec: &pb.Net{Layers: []*pb.Layer{{Class: "linear", Name: "x"}}},
+		},
+		"missing file": {
+			spec: &pb.Net{Layers: []*pb.Layer{{Class: "linear", Name: "x", Params: param(2, 3)}}},
+		},
+		"shape mismatch": {
+			spec:  &pb.Net{Layers: []*pb.Layer{{Class: "linear", Name: "x", Params: param(2, 2)}}},
+			files: map[string][]byte{"layer_0_param_w.bin": make([]byte, 24)},
+		},
+		"truncated": {
+			spec:  &pb.Net{Layers: []*pb.Layer{{Class: "linear", Name: "x", Params: param(2, 3)}}},
+			files: map[string][]byte{"layer_0_param_w.bin": make([]byte, 10)},
+		},
+		"shapes overflow": {
+			spec:  &pb.Net{Layers: []*pb.Layer{{Class: "linear", Name: "x", Params: param(1<<62, 4)}}},
+			files: map[string][]byte{"layer_0_param_w.bin": make([]byte, 24)},
+		},
+		"layer without inputs": {
+			spec: &pb.Net{
+				Layers: []*pb.Layer{{Class: "relu", Name: "relu"}},
+				Graph: &pb.Graph{Name: "root", Inputs: 1,
+					Nodes:   []*pb.Node{{Name: "relu", Target: &pb.Node_Layer{Layer: 0}}},
+					Outputs: []*pb.Edge{{Node: 0}}},
+			},
+		},
+		"module inputs mismatch": {
+			spec: &pb.Net{
+				Graph: &pb.Graph{Name: "root", Inputs: 1,
+					Nodes: []*pb.Node{{Name: "block", Target: &pb.Node_Module{Module: "block"},
+						Inputs: []*pb.Edge{{Node: -1}}}},
+					Outputs: []*pb.Edge{{Node: 0}}},
+				Modules: []*pb.Graph{{Name: "block", Inputs: 2,
+					Outputs: []*pb.Edge{{Node: -1}}}},
+			},
+		},
+	}
+	for name, c := range cases {
+		r := writeSpec(t, c.spec, c.files)
+		var net Net
+		if _, err := net.ReadFrom(r, r.Size()); err == nil {
+			t.Fatalf("%s: expect error", name)
+		}
+	}
+}
+
+func TestRegisterLoadFunc(t *testing.T) {
+	RegisterLoadFunc("custom_relu", func(name string, _ map[string]*tensor.Tensor, _ map[string]float32) layer.Layer {
+		return activation.NewReLU()
+	})
+	defer delete(loadFuncs, "custom_relu")
+	r := writeSpec(t, &pb.Net{Layers: []*pb.Layer{{Class: "custom_relu", Name: "relu"}}}, nil)
+	var net Net
+	if _, err := net.ReadFrom(r, r.Size()); err != nil {
+		t.Fatal(err)
+	}
+	if len(net.Layers()) != 1 {
+		t.Fatal("layer not loaded")
+	}
+}
+
+func TestMapParams(t *testing.T) {
+	var net Net
+	net.Add(layer.NewLinear("linear", 2, 1))
+	l := net.Layers()[0]
+	w := tensor.FromFloat32([]float32{1, 2}, tensor.WithShapes(1, 2))
+	err := net.MapParams(func(i int, name string, t *tensor.Tensor) *tensor.Tensor {
+		return w
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if net.Layers()[0] != l {
+		t.Fatal("layer not updated in place")
+	}
+	if got := l.Params()["w"].Float32Value(); got[0] != 1 || got[1] != 2 {
+		t.Fatalf("unexpected param: %v", got)
+	}
+}
+
+func TestLoadParamType(t *testing.T) {
+	var net Net
+	net.Add(layer.NewLinear("linear", 2, 3, layer.WithParamType(consts.KHalf)))
+	var buf bytes.Buffer
+	if _, err := net.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var loaded Net
+	if _, err := loaded.ReadFrom(bytes.NewReader(buf.Bytes()), int64(buf.Len())); err != nil {
+		t.Fatal(err)
+	}
+	l := loaded.Layers()[0].(*layer.Linear)
+	if l.ParamType() != consts.KHalf || l.Params()["w"].ScalarType() != consts.KHalf {
+		t.Fatal("param type not restored")
+	}
+	if _, err := loaded.ReadFrom(bytes.NewReader(buf.Bytes()), int64(buf.Len()),
+		WithMapLocation(consts.KCPU), WithLoadParamType(consts.KFloat)); err != nil {
+		t.Fatal(err)
+	}
+	l = loaded.Layers()[0].(*layer.Linear)
+	if l.ParamType() != consts.KFloat || l.Params()["w"].ScalarType() != consts.KFloat {
+		t.Fatal("param type not converted")
+	}
+	if l.Device() != consts.KCPU {
+		t.Fatal("invalid device")
+	}
+}
+
+func TestLoadLazy(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "test.model")
+	var net Net
+	net.Add(layer.NewLinear("hidden", 2, 3))
+	net.Add(layer.NewLinear("output", 3, 1))
+	if err := net.Save(dir, WithStore()); err != nil {
+		t.Fatal(err)
+	}
+	var loaded Net
+	if err := loaded.Load(dir, WithLazyLoad()); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.ParamCount() != net.ParamCount() {
+		t.Fatalf("invalid param count: %d", loaded.ParamCount())
+	}
+	if loaded.layers[0] != nil {
+		t.Fatal("layer loaded before access")
+	}
+	x := tensor.FromFloat32([]float32{0, 1}, tensor.WithShapes(1, 2))
+	if _, err := loaded.Forward(layer.NewContext(false), x); err != nil {
+		t.Fatal(err)
+	}
+	want := net.Layers()[1].Params()["w"].Float32Value()
+	got := loaded.Layers()[1].Params()["w"].Float32Value()
+	if loaded.lazy != nil {
+		t.Fatal("file not released")
+	}
+	for i := range want {
+		if want[i] != got[i] {
+			t.Fatalf("param mismatch at %d", i)
+		}
+	}
+}
+
+func TestFixOrder(t *testing.T) {
+	data := []uint16{0x0102}
+	other := binary.ByteOrder(binary.BigEndian)
+	if nativeOrder == binary.BigEndian {
+		other = binary.LittleEndian
+	}
+	fixOrder(data, other)
+	if data[0] != 0x0201 {
+		t.Fatalf("invalid order: %x", data[0])
+	}
+	flags := []bool{false, true}
+	asBytes(flags)[1] = 2
+	fixOrder(flags, other)
+	if asBytes(flags)[1] != 1 {
+		t.Fatal("bool not normalized")
+	}
+}
+
+func TestLoadLazyError(t *testing.T) {
+	r := writeSpec(t, &pb.Net{Layers: []*pb.Layer{{Class: "linear", Name: "x", Params: map[string]*pb.Param{"w": {
+		Type:      uint32(consts.KFloat),
+		ElemCount: 6,
+		Name:      "w",
+		Shapes:    []int64{2, 3},
+		File:      "layer_0_param_w.bin",
+	}}}}}, nil)
+	data := make([]byte, r.Size())
+	r.ReadAt(data, 0)
+	dir := filepath.Join(t.TempDir(), "test.model")
+	if err := os.WriteFile(dir, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	var net Net
+	if err := net.Load(dir, WithLazyLoad()); err != nil {
+		t.Fatal(err)
+	}
+	if len(net.Layers()) != 0 || len(net.Params()) != 0 {
+		t.Fatal("expect no layers loaded")
+	}
+	if net.Err() == nil {
+		t.Fatal("expect error of loading")
+	}
+	if net.Materialize() == nil {
+		t.Fatal("expect error of materialize")
+	}
+}
+
+func TestLoadLazyConcurrent(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "test.model")
+	var net Net
+	net.Add(layer.NewLinear("hidden", 2, 3))
+	net.Add(layer.NewLinear("output", 3, 1))
+	if err := net.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	var loaded Net
+	if err := loaded.Load(dir, WithLazyLoad()); err != nil {
+		t.Fatal(err)
+	}
+	x := tensor.FromFloat32([]float32{0, 1}, tensor.WithShapes(1, 2))
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := loaded.Forward(layer.NewContext(false), x); err != nil {
+				t.Error(err)
+			}
+			loaded.Layers()
+		}()
+	}
+	wg.Wait()
+	if loaded.Err() != nil || len(loaded.Layers()) != 2 {
+		t.Fatal("layers not loaded")
+	}
+}
+
+func TestReadTensorChunks(t *testing.T) {
+	cnt := int64(chunkSize/4 + 2) // 3 rows not aligned to chunk
+	data := make([]float32, cnt)
+	for i := range data {
+		data[i] = float32(i)
+	}
+	var buf bytes.Buffer
+	src := tensor.FromFloat32(data, tensor.WithShapes(cnt/3, 3))
+	if err := writeTensor(&buf, binary.BigEndian, src); err != nil {
+		t.Fatal(err)
+	}
+	dst, err := readTensor(&buf, binary.BigEndian, consts.KFloat, cnt, []int64{cnt / 3, 3}, consts.KCPU)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shapes := dst.Shapes()
+	if len(shapes) != 2 || shapes[0] != cnt/3 || shapes[1] != 3 {
+		t.Fatalf("invalid shapes: %v", shapes)
+	}
+	got := dst.Float32Value()
+	for i := range data {
+		if got[i] != data[i] {
+			t.Fatalf("mismatch at %d: %v", i, got[i])
+		}
+	}
+}
+
+func TestBuildParamOnce(t *testing.T) {
+	cnt := int64(chunkSize/4*3 + 1) // 4 chunks
+	raw := make([]byte, cnt*4)
+	for i := int64(0); i < cnt; i++ {
+		binary.BigEndian.PutUint32(raw[i*4:], math.Float32bits(float32(i)))
+	}
+	var calls int
+	var got []float32
+	fn := func(data []float32, _ ...tensor.Option) *tensor.Tensor {
+		calls++
+		got = data
+		return nil
+	}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if _, err := buildParam(bytes.NewReader(raw), binary.BigEndian, cnt, []int64{cnt}, consts.KCPU, fn); err != nil {
+		t.Fatal(err)
+	}
+	runtime.ReadMemStats(&after)
+	if calls != 1 {
+		t.Fatalf("expect one tensor created, got %d", calls)
+	}
+	// only the buffer of param is allocated
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > uint64(len(raw))*3/2 {
+		t.Fatalf("expect about %d bytes allocated, got %d", len(raw), alloc)
+	}
+	for i, v := range got {
+		if v != float32(i) {
+			t.Fatalf("mismatch at %d: %v", i, v)
+		}
+	}
+}
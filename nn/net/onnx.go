@@ -0,0 +1,780 @@
+package net
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/lwch/gotorch/consts"
+	"github.com/lwch/gotorch/tensor"
+	"github.com/lwch/tnn/internal/onnx"
+	"github.com/lwch/tnn/nn/layer"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	onnxIRVersion = 8
+	onnxOpset     = 17
+)
+
+var onnxTypes = map[consts.ScalarType]onnx.TensorProto_DataType{
+	consts.KUint8:    onnx.TensorProto_UINT8,
+	consts.KInt8:     onnx.TensorProto_INT8,
+	consts.KInt16:    onnx.TensorProto_INT16,
+	consts.KInt32:    onnx.TensorProto_INT32,
+	consts.KInt64:    onnx.TensorProto_INT64,
+	consts.KHalf:     onnx.TensorProto_FLOAT16,
+	consts.KFloat:    onnx.TensorProto_FLOAT,
+	consts.KDouble:   onnx.TensorProto_DOUBLE,
+	consts.KBool:     onnx.TensorProto_BOOL,
+	consts.KBFloat16: onnx.TensorProto_BFLOAT16,
+}
+
+var onnxOps = map[string]string{
+	"add":    "Add",
+	"sub":    "Sub",
+	"mul":    "Mul",
+	"div":    "Div",
+	"matmul": "MatMul",
+}
+
+// onnxFunc build nodes of layer, returns names of outputs
+type onnxFunc func(b *onnxBuilder, l layer.Layer, inputs []string) ([]string, error)
+
+var onnxFuncs = map[string]onnxFunc{
+	"linear":     onnxLinear,
+	"dropout":    onnxIdentity,
+	"conv1d":     onnxConv1D,
+	"conv2d":     onnxConv2D,
+	"maxpool1d":  onnxMaxPool1D,
+	"attention":  onnxAttention,
+	"attention1": onnxAttention,
+	"layer_norm": onnxLayerNorm,
+	"rms_norm":   onnxRMSNorm,
+	"flatten":    onnxFlatten,
+	"embedding":  onnxEmbedding,
+	"rezero":     onnxReZero,
+	// activation
+	"sigmoid": onnxUnary("Sigmoid"),
+	"tanh":    onnxUnary("Tanh"),
+	"relu":    onnxUnary("Relu"),
+	"gelu":    onnxGelu,
+}
+
+type onnxOptions struct {
+	causal     bool
+	inputTypes []consts.ScalarType
+}
+
+// ONNXOption option of onnx exporting
+type ONNXOption func(*onnxOptions)
+
+// WithONNXCausal use causal mask in attention layers when no mask given
+func WithONNXCausal(causal bool) ONNXOption {
+	return func(opts *onnxOptions) {
+		opts.causal = causal
+	}
+}
+
+// WithONNXInputTypes set scalar type of inputs, by default inputs of embedding
+// layers are int64 and others are the same as param type of the net
+func WithONNXInputTypes(types ...consts.ScalarType) ONNXOption {
+	return func(opts *onnxOptions) {
+		opts.inputTypes = types
+	}
+}
+
+type onnxBuilder struct {
+	opts   onnxOptions
+	dtype  consts.ScalarType // default floating point type
+	nodes  []*onnx.NodeProto
+	inits  []*onnx.TensorProto
+	params map[*tensor.Tensor]string
+	names  map[string]int
+}
+
+// name get unique value name
+func (b *onnxBuilder) name(prefix string) string {
+	b.names[prefix]++
+	if b.names[prefix] == 1 {
+		return prefix
+	}
+	return fmt.Sprintf("%s_%d", prefix, b.names[prefix]-1)
+}
+
+// node add node with single output
+func (b *onnxBuilder) node(prefix, op string, inputs []string, attrs ...*onnx.AttributeProto) string {
+	output := b.name(prefix + "/" + op)
+	b.nodes = append(b.nodes, &onnx.NodeProto{
+		Name:      proto.String(output),
+		OpType:    proto.String(op),
+		Input:     inputs,
+		Output:    []string{output},
+		Attribute: attrs,
+	})
+	return output
+}
+
+func onnxTensor(name string, t *tensor.Tensor) (*onnx.TensorProto, error) {
+	dtype, ok := onnxTypes[t.ScalarType()]
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported scalar type: %s", name, t.ScalarType().String())
+	}
+	var buf bytes.Buffer
+	if err := writeTensor(&buf, binary.LittleEndian, t); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return &onnx.TensorProto{
+		Name:     proto.String(name),
+		DataType: proto.Int32(int32(dtype)),
+		Dims:     t.Shapes(),
+		RawData:  buf.Bytes(),
+	}, nil
+}
+
+// param add param of layer as initializer, shared params are added once
+func (b *onnxBuilder) param(l layer.Layer, name string) (string, error) {
+	t := l.Params()[name]
+	if t == nil {
+		return "", fmt.Errorf("missing param %s", name)
+	}
+	if ret, ok := b.params[t]; ok {
+		return ret, nil
+	}
+	init, err := onnxTensor(b.name(safetensorsKey(l.Name(), name)), t)
+	if err != nil {
+		return "", err
+	}
+	b.inits = append(b.inits, init)
+	b.params[t] = init.GetName()
+	return init.GetName(), nil
+}
+
+// ints add int64 constant
+func (b *onnxBuilder) ints(prefix string, values ...int64) string {
+	name := b.name(prefix)
+	b.inits = append(b.inits, &onnx.TensorProto{
+		Name:      proto.String(name),
+		DataType:  proto.Int32(int32(onnx.TensorProto_INT64)),
+		Dims:      []int64{int64(len(values))},
+		Int64Data: values,
+	})
+	return name
+}
+
+// scalar add int64 scalar constant
+func (b *onnxBuilder) scalar(prefix string, v int64) string {
+	name := b.name(prefix)
+	b.inits = append(b.inits, &onnx.TensorProto{
+		Name:      proto.String(name),
+		DataType:  proto.Int32(int32(onnx.TensorProto_INT64)),
+		Int64Data: []int64{v},
+	})
+	return name
+}
+
+// floats add floating point constant of type t
+func (b *onnxBuilder) floats(prefix string, t consts.ScalarType, values []float32, shapes ...int64) (string, error) {
+	init, err := onnxTensor(b.name(prefix),
+		tensor.FromFloat32(values, tensor.WithShapes(shapes...)).ToScalarType(t))
+	if err != nil {
+		return "", err
+	}
+	b.inits = append(b.inits, init)
+	return init.GetName(), nil
+}
+
+func attrInt(name string, v int64) *onnx.AttributeProto {
+	return &onnx.AttributeProto{
+		Name: proto.String(name),
+		Type: onnx.AttributeProto_INT.Enum(),
+		I:    proto.Int64(v),
+	}
+}
+
+func attrInts(name string, v ...int64) *onnx.AttributeProto {
+	return &onnx.AttributeProto{
+		Name: proto.String(name),
+		Type: onnx.AttributeProto_INTS.Enum(),
+		Ints: v,
+	}
+}
+
+func attrFloat(name string, v float32) *onnx.AttributeProto {
+	return &onnx.AttributeProto{
+		Name: proto.String(name),
+		Type: onnx.AttributeProto_FLOAT.Enum(),
+		F:    proto.Float32(v),
+	}
+}
+
+func layerParamType(l layer.Layer) consts.ScalarType {
+	if l, ok := l.(deviceLayer); ok {
+		return l.ParamType()
+	}
+	return consts.KFloat
+}
+
+// ExportONNX export net in onnx format, the file is replaced atomically
+func (n *Net) ExportONNX(dir string, opts ...ONNXOption) error {
+	return atomicWrite(dir, func(w io.Writer) error {
+		return n.WriteONNX(w, opts...)
+	})
+}
+
+// WriteONNX write ModelProto of the net, params are stored as initializers,
+// only inference is supported, dropout is exported as Identity
+func (n *Net) WriteONNX(w io.Writer, opts ...ONNXOption) error {
+	model, err := n.buildONNX(opts...)
+	if err != nil {
+		return err
+	}
+	data, err := proto.Marshal(model)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (n *Net) checkONNX() error {
+	var unsupported []string
+	exists := make(map[string]bool)
+	for _, l := range n.layers {
+		if onnxFuncs[l.Class()] != nil {
+			continue
+		}
+		desc := fmt.Sprintf("%s(%s)", l.Class(), l.Name())
+		if !exists[desc] {
+			exists[desc] = true
+			unsupported = append(unsupported, desc)
+		}
+	}
+	if n.graph != nil {
+		n.graph.walk(func(g *Graph) {
+			for _, node := range g.nodes {
+				if node.layer == nil && node.module == nil && onnxOps[node.op] == "" {
+					desc := fmt.Sprintf("op %s", node.op)
+					if !exists[desc] {
+						exists[desc] = true
+						unsupported = append(unsupported, desc)
+					}
+				}
+			}
+		})
+	}
+	if len(unsupported) > 0 {
+		sort.Strings(unsupported)
+		return fmt.Errorf("unsupported layers for onnx: %s", strings.Join(unsupported, ", "))
+	}
+	return nil
+}
+
+// floatType get param type of the first layer which has params
+func (n *Net) floatType() consts.ScalarType {
+	for _, l := range n.layers {
+		if l, ok := l.(deviceLayer); ok {
+			return l.ParamType()
+		}
+	}
+	return consts.KFloat
+}
+
+// inputTypes get scalar types of inputs, inputs of embedding layers are int64
+func (n *Net) inputTypes(count int) []consts.ScalarType {
+	ret := make([]consts.ScalarType, count)
+	for i := range ret {
+		ret[i] = n.floatType()
+	}
+	if n.graph == nil {
+		if len(n.layers) > 0 && n.layers[0].Class() == "embedding" {
+			ret[0] = consts.KInt64
+		}
+		return ret
+	}
+	for _, node := range n.graph.nodes {
+		if node.layer == nil || node.layer.Class() != "embedding" {
+			continue
+		}
+		for _, v := range node.inputs {
+			if v.node < 0 {
+				ret[v.index] = consts.KInt64
+			}
+		}
+	}
+	return ret
+}
+
+func onnxValueInfo(name string, t consts.ScalarType) *onnx.ValueInfoProto {
+	return &onnx.ValueInfoProto{
+		Name: proto.String(name),
+		Type: &onnx.TypeProto{
+			Value: &onnx.TypeProto_TensorType{
+				TensorType: &onnx.TypeProto_Tensor{
+					ElemType: proto.Int32(int32(onnxTypes[t])),
+				},
+			},
+		},
+	}
+}
+
+func (n *Net) buildONNX(opts ...ONNXOption) (*onnx.ModelProto, error) {
+	if err := n.Materialize(); err != nil {
+		return nil, err
+	}
+	if err := n.checkONNX(); err != nil {
+		return nil, err
+	}
+	b := &onnxBuilder{
+		dtype:  n.floatType(),
+		params: make(map[*tensor.Tensor]string),
+		names:  make(map[string]int),
+	}
+	for _, opt := range opts {
+		opt(&b.opts)
+	}
+	count := 1
+	if n.graph != nil {
+		count = n.graph.inputs
+	}
+	types := n.inputTypes(count)
+	copy(types, b.opts.inputTypes)
+	inputs := make([]string, count)
+	var graph onnx.GraphProto
+	graph.Name = proto.String("tnn")
+	for i := range inputs {
+		inputs[i] = b.name(fmt.Sprintf("input_%d", i))
+		graph.Input = append(graph.Input, onnxValueInfo(inputs[i], types[i]))
+	}
+	var outputs []string
+	var err error
+	if n.graph != nil {
+		graph.Name = proto.String(n.graph.name)
+		outputs, err = b.graph(n.graph, inputs)
+	} else {
+		outputs, err = b.sequential(n.layers, inputs)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, output := range outputs {
+		// outputs are renamed by Identity, graph input may be used as output directly
+		name := b.name(fmt.Sprintf("output_%d", i))
+		b.nodes = append(b.nodes, &onnx.NodeProto{
+			Name:   proto.String(name),
+			OpType: proto.String("Identity"),
+			Input:  []string{output},
+			Output: []string{name},
+		})
+		graph.Output = append(graph.Output, onnxValueInfo(name, b.dtype))
+	}
+	graph.Node = b.nodes
+	graph.Initializer = b.inits
+	return &onnx.ModelProto{
+		IrVersion:    proto.Int64(onnxIRVersion),
+		ProducerName: proto.String("tnn"),
+		OpsetImport: []*onnx.OperatorSetIdProto{{
+			Domain:  proto.String(""),
+			Version: proto.Int64(onnxOpset),
+		}},
+		Graph: &graph,
+	}, nil
+}
+
+func (b *onnxBuilder) layer(l layer.Layer, inputs []string) ([]string, error) {
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("%s layer %s: missing inputs", l.Class(), l.Name())
+	}
+	outputs, err := onnxFuncs[l.Class()](b, l, inputs)
+	if err != nil {
+		return nil, fmt.Errorf("%s layer %s: %v", l.Class(), l.Name(), err)
+	}
+	return outputs, nil
+}
+
+func (b *onnxBuilder) sequential(layers []layer.Layer, inputs []string) ([]string, error) {
+	var err error
+	for _, l := range layers {
+		inputs, err = b.layer(l, inputs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return inputs, nil
+}
+
+// graph inline graph and its sub modules
+func (b *onnxBuilder) graph(g *Graph, inputs []string) ([]string, error) {
+	values := make([][]string, len(g.nodes))
+	get := func(v Value) (string, error) {
+		if v.node < 0 {
+			return inputs[v.index], nil
+		}
+		if v.index >= len(values[v.node]) {
+			return "", fmt.Errorf("graph %s: node %d(%s) has no output %d",
+				g.name, v.node, g.nodes[v.node].name, v.index)
+		}
+		return values[v.node][v.index], nil
+	}
+	for i, n := range g.nodes {
+		args := make([]string, len(n.inputs))
+		for j, v := range n.inputs {
+			name, err := get(v)
+			if err != nil {
+				return nil, err
+			}
+			args[j] = name
+		}
+		var err error
+		switch {
+		case n.layer != nil:
+			values[i], err = b.layer(n.layer, args)
+		case n.module != nil:
+			values[i], err = b.graph(n.module, args)
+		default:
+			values[i] = []string{b.node(g.name+"/"+n.name, onnxOps[n.op], args)}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	ret := make([]string, len(g.outputs))
+	for i, v := range g.outputs {
+		name, err := get(v)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = name
+	}
+	return ret, nil
+}
+
+func onnxIdentity(b *onnxBuilder, l layer.Layer, inputs []string) ([]string, error) {
+	return []string{b.node(l.Name(), "Identity", inputs[:1])}, nil
+}
+
+func onnxUnary(op string) onnxFunc {
+	return func(b *onnxBuilder, l layer.Layer, inputs []string) ([]string, error) {
+		return []string{b.node(l.Name(), op, inputs[:1])}, nil
+	}
+}
+
+func onnxLinear(b *onnxBuilder, l layer.Layer, inputs []string) ([]string, error) {
+	w, err := b.param(l, "w")
+	if err != nil {
+		return nil, err
+	}
+	w = b.node(l.Name(), "Transpose", []string{w}, attrInts("perm", 1, 0))
+	return []string{b.node(l.Name(), "MatMul", []string{inputs[0], w})}, nil
+}
+
+func onnxConv1D(b *onnxBuilder, l layer.Layer, inputs []string) ([]string, error) {
+	w, err := b.param(l, "w")
+	if err != nil {
+		return nil, err
+	}
+	args := l.Args()
+	padding := int64(args["padding"])
+	return []string{b.node(l.Name(), "Conv", []string{inputs[0], w},
+		attrInts("kernel_shape", int64(args["kernel"])),
+		attrInts("strides", int64(args["stride"])),
+		attrInts("pads", padding, padding),
+		attrInts("dilations", int64(args["dilation"])),
+		attrInt("group", int64(args["groups"])))}, nil
+}
+
+func onnxConv2D(b *onnxBuilder, l layer.Layer, inputs []string) ([]string, error) {
+	w, err := b.param(l, "w")
+	if err != nil {
+		return nil, err
+	}
+	bias, err := b.param(l, "b")
+	if err != nil {
+		return nil, err
+	}
+	args := l.Args()
+	padding1, padding2 := int64(args["padding1"]), int64(args["padding2"])
+	dilation := int64(args["dilation"])
+	return []string{b.node(l.Name(), "Conv", []string{inputs[0], w, bias},
+		attrInts("kernel_shape", int64(args["kernel1"]), int64(args["kernel2"])),
+		attrInts("strides", int64(args["stride1"]), int64(args["stride2"])),
+		attrInts("pads", padding1, padding2, padding1, padding2),
+		attrInts("dilations", dilation, dilation),
+		attrInt("group", int64(args["groups"])))}, nil
+}
+
+func onnxMaxPool1D(b *onnxBuilder, l layer.Layer, inputs []string) ([]string, error) {
+	args := l.Args()
+	kernel := int64(args["kernel"])
+	stride := int64(args["stride"])
+	if stride < 0 {
+		stride = kernel
+	}
+	padding := int64(args["padding"])
+	var ceil int64
+	if args["ceil"] > 0 {
+		ceil = 1
+	}
+	return []string{b.node(l.Name(), "MaxPool", inputs[:1],
+		attrInts("kernel_shape", kernel),
+		attrInts("strides", stride),
+		attrInts("pads", padding, padding),
+		attrInts("dilations", int64(args["dilation"])),
+		attrInt("ceil_mode", ceil))}, nil
+}
+
+func onnxFlatten(b *onnxBuilder, l layer.Layer, inputs []string) ([]string, error) {
+	return []string{b.node(l.Name(), "Flatten", inputs[:1], attrInt("axis", 1))}, nil
+}
+
+func onnxEmbedding(b *onnxBuilder, l layer.Layer, inputs []string) ([]string, error) {
+	w, err := b.param(l, "w")
+	if err != nil {
+		return nil, err
+	}
+	return []string{b.node(l.Name(), "Gather", []string{w, inputs[0]}, attrInt("axis", 0))}, nil
+}
+
+func onnxReZero(b *onnxBuilder, l layer.Layer, inputs []string) ([]string, error) {
+	scale, err := b.param(l, "scale")
+	if err != nil {
+		return nil, err
+	}
+	return []string{b.node(l.Name(), "Mul", []string{inputs[0], scale})}, nil
+}
+
+func onnxLayerNorm(b *onnxBuilder, l layer.Layer, inputs []string) ([]string, error) {
+	a, err := b.param(l, "a")
+	if err != nil {
+		return nil, err
+	}
+	return []string{b.node(l.Name(), "LayerNormalization", []string{inputs[0], a},
+		attrInt("axis", -1),
+		attrFloat("epsilon", 1e-9))}, nil
+}
+
+func onnxRMSNorm(b *onnxBuilder, l layer.Layer, inputs []string) ([]string, error) {
+	a, err := b.param(l, "a")
+	if err != nil {
+		return nil, err
+	}
+	eps, err := b.floats(l.Name()+"/eps", layerParamType(l), []float32{1e-9}, 1)
+	if err != nil {
+		return nil, err
+	}
+	x := inputs[0]
+	y := b.node(l.Name(), "Mul", []string{x, x})
+	y = b.node(l.Name(), "ReduceMean", []string{y}, attrInts("axes", -1), attrInt("keepdims", 1))
+	y = b.node(l.Name(), "Add", []string{y, eps})
+	y = b.node(l.Name(), "Sqrt", []string{y})
+	y = b.node(l.Name(), "Reciprocal", []string{y})
+	y = b.node(l.Name(), "Mul", []string{x, y})
+	return []string{b.node(l.Name(), "Mul", []string{a, y})}, nil
+}
+
+func onnxGelu(b *onnxBuilder, l layer.Layer, inputs []string) ([]string, error) {
+	name := l.Name()
+	scalars := func(values ...float32) ([]string, error) {
+		ret := make([]string, len(values))
+		for i, v := range values {
+			var err error
+			ret[i], err = b.floats(name+"/const", b.dtype, []float32{v}, 1)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return ret, nil
+	}
+	x := inputs[0]
+	var y string
+	if l.Args()["tanh"] > 0 {
+		// 0.5 * x * (1 + tanh(sqrt(2/pi) * (x + 0.044715 * x^3)))
+		c, err := scalars(0.5, 1, float32(math.Sqrt(2/math.Pi)), 0.044715)
+		if err != nil {
+			return nil, err
+		}
+		y = b.node(name, "Mul", []string{x, x})
+		y = b.node(name, "Mul", []string{y, x})
+		y = b.node(name, "Mul", []string{y, c[3]})
+		y = b.node(name, "Add", []string{x, y})
+		y = b.node(name, "Mul", []string{y, c[2]})
+		y = b.node(name, "Tanh", []string{y})
+		y = b.node(name, "Add", []string{y, c[1]})
+		y = b.node(name, "Mul", []string{y, x})
+		return []string{b.node(name, "Mul", []string{y, c[0]})}, nil
+	}
+	// 0.5 * x * (1 + erf(x / sqrt(2)))
+	c, err := scalars(0.5, 1, float32(math.Sqrt2))
+	if err != nil {
+		return nil, err
+	}
+	y = b.node(name, "Div", []string{x, c[2]})
+	y = b.node(name, "Erf", []string{y})
+	y = b.node(name, "Add", []string{y, c[1]})
+	y = b.node(name, "Mul", []string{y, x})
+	return []string{b.node(name, "Mul", []string{y, c[0]})}, nil
+}
+
+// onnxAttention decompose attention and attention1 layers into
+// MatMul/Softmax etc. to match the forward of tnn
+func onnxAttention(b *onnxBuilder, l layer.Layer, inputs []string) ([]string, error) {
+	name := l.Name()
+	args := l.Args()
+	dims := int64(args["dims"])
+	heads := int64(args["heads"])
+	if dims <= 0 || heads <= 0 || dims%heads != 0 {
+		return nil, fmt.Errorf("invalid dims %d or heads %d", dims, heads)
+	}
+	t := layerParamType(l)
+	var q, k, v, mask string
+	switch len(inputs) {
+	case 1:
+		q, k, v = inputs[0], inputs[0], inputs[0]
+	case 3:
+		q, k, v = inputs[0], inputs[1], inputs[2]
+	case 4:
+		q, k, v, mask = inputs[0], inputs[1], inputs[2], inputs[3]
+	default:
+		return nil, fmt.Errorf("expect 1, 3 or 4 inputs, got %d", len(inputs))
+	}
+	w, err := b.param(l, "w")
+	if err != nil {
+		return nil, err
+	}
+	w = b.node(name, "Transpose", []string{w}, attrInts("perm", 1, 0))
+	x := b.node(name, "Concat", []string{q, k, v}, attrInt("axis", -1)) // (batch, seq, dims*3)
+	x = b.node(name, "MatMul", []string{x, w})                          // (batch, seq, dims*3)
+	axes := b.ints(name+"/axes", -1)
+	split := b.ints(name+"/split", 0, 0, heads, dims/heads)
+	narrow := func(i int64) string {
+		starts := b.ints(name+"/starts", dims*i)
+		ends := b.ints(name+"/ends", dims*(i+1))
+		y := b.node(name, "Slice", []string{x, starts, ends, axes}) // (batch, seq, dims)
+		return b.node(name, "Reshape", []string{y, split})          // (batch, seq, heads, dims/heads)
+	}
+	q, k, v = narrow(0), narrow(1), narrow(2)
+	// sequence length in 1-D tensor
+	var seq string
+	if args["rope"] != 0 || (mask == "" && b.opts.causal) {
+		shape := b.node(name, "Shape", []string{q})
+		seq = b.node(name, "Slice", []string{shape,
+			b.ints(name+"/starts", 1), b.ints(name+"/ends", 2), b.ints(name+"/axes", 0)})
+	}
+	if args["rope"] != 0 {
+		base := int64(args["rope_base"])
+		if base <= 0 {
+			base = 10000
+		}
+		q, k, err = b.rope(name, t, q, k, seq, base, dims/heads)
+		if err != nil {
+			return nil, err
+		}
+	}
+	q = b.node(name, "Transpose", []string{q}, attrInts("perm", 0, 2, 1, 3)) // (batch, heads, seq, dims/heads)
+	k = b.node(name, "Transpose", []string{k}, attrInts("perm", 0, 2, 3, 1)) // (batch, heads, dims/heads, seq)
+	v = b.node(name, "Transpose", []string{v}, attrInts("perm", 0, 2, 1, 3)) // (batch, heads, seq, dims/heads)
+	score := b.node(name, "MatMul", []string{q, k})                          // (batch, heads, seq, seq)
+	if l.Class() == "attention1" {
+		scale, err := b.floats(name+"/scale", t, []float32{float32(math.Sqrt(float64(dims)))}, 1)
+		if err != nil {
+			return nil, err
+		}
+		score = b.node(name, "Div", []string{score, scale})
+	} else {
+		scale, err := b.floats(name+"/scale", t, []float32{float32(1 / math.Sqrt(float64(dims/heads)))}, 1)
+		if err != nil {
+			return nil, err
+		}
+		score = b.node(name, "Mul", []string{score, scale})
+	}
+	if mask == "" && b.opts.causal {
+		mask, err = b.causal(name, t, seq)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if mask != "" {
+		score = b.node(name, "Add", []string{score, mask})
+	}
+	if l.Class() == "attention1" {
+		// exp(x - max) / (1 + sum(exp(x - max)))
+		one, err := b.floats(name+"/one", t, []float32{1}, 1)
+		if err != nil {
+			return nil, err
+		}
+		max := b.node(name, "ReduceMax", []string{score}, attrInts("axes", -1), attrInt("keepdims", 1))
+		score = b.node(name, "Sub", []string{score, max})
+		score = b.node(name, "Exp", []string{score})
+		sum := b.node(name, "ReduceSum", []string{score, b.ints(name+"/axes", -1)}, attrInt("keepdims", 1))
+		sum = b.node(name, "Add", []string{sum, one})
+		score = b.node(name, "Div", []string{score, sum})
+	} else {
+		score = b.node(name, "Softmax", []string{score}, attrInt("axis", -1))
+	}
+	y := b.node(name, "MatMul", []string{score, v})                          // (batch, heads, seq, dims/heads)
+	y = b.node(name, "Transpose", []string{y}, attrInts("perm", 0, 2, 1, 3)) // (batch, seq, heads, dims/heads)
+	y = b.node(name, "Reshape", []string{y, b.ints(name+"/shape", 0, 0, dims)})
+	return []string{y}, nil
+}
+
+// rope apply rotary position embedding on q and k in shape (batch, seq, heads, dim),
+// pairs of (x[2i], x[2i+1]) are rotated by position * base^(-2i/dim)
+func (b *onnxBuilder) rope(name string, t consts.ScalarType, q, k, seq string, base, dim int64) (string, string, error) {
+	data := make([]float32, dim/2)
+	for i := range data {
+		data[i] = float32(1 / math.Pow(float64(base), float64(2*i)/float64(dim)))
+	}
+	freqs, err := b.floats(name+"/rope_freqs", consts.KFloat, data, dim/2)
+	if err != nil {
+		return "", "", err
+	}
+	limit := b.node(name, "Squeeze", []string{seq})
+	pos := b.node(name, "Range", []string{b.scalar(name+"/start", 0), limit, b.scalar(name+"/delta", 1)})
+	pos = b.node(name, "Cast", []string{pos}, attrInt("to", int64(onnx.TensorProto_FLOAT)))
+	pos = b.node(name, "Unsqueeze", []string{pos, b.ints(name+"/axes", 1)}) // (seq, 1)
+	theta := b.node(name, "Mul", []string{pos, freqs})                      // (seq, dim/2)
+	theta = b.node(name, "Reshape", []string{theta, b.ints(name+"/shape", -1, 1, dim/2, 1)})
+	to := attrInt("to", int64(onnxTypes[t]))
+	cos := b.node(name, "Cast", []string{b.node(name, "Cos", []string{theta})}, to) // (seq, 1, dim/2, 1)
+	sin := b.node(name, "Cast", []string{b.node(name, "Sin", []string{theta})}, to) // (seq, 1, dim/2, 1)
+	pairs := b.ints(name+"/pairs", 0, 0, 0, dim/2, 2)
+	shape := b.ints(name+"/shape", 0, 0, 0, dim)
+	last := b.ints(name+"/axes", -1)
+	zero := b.ints(name+"/starts", 0)
+	one := b.ints(name+"/one", 1)
+	two := b.ints(name+"/two", 2)
+	apply := func(x string) string {
+		x = b.node(name, "Reshape", []string{x, pairs})             // (batch, seq, heads, dim/2, 2)
+		even := b.node(name, "Slice", []string{x, zero, one, last}) // (batch, seq, heads, dim/2, 1)
+		odd := b.node(name, "Slice", []string{x, one, two, last})   // (batch, seq, heads, dim/2, 1)
+		y1 := b.node(name, "Sub", []string{
+			b.node(name, "Mul", []string{even, cos}),
+			b.node(name, "Mul", []string{odd, sin})})
+		y2 := b.node(name, "Add", []string{
+			b.node(name, "Mul", []string{even, sin}),
+			b.node(name, "Mul", []string{odd, cos})})
+		y := b.node(name, "Concat", []string{y1, y2}, attrInt("axis", -1))
+		return b.node(name, "Reshape", []string{y, shape}) // (batch, seq, heads, dim)
+	}
+	return apply(q), apply(k), nil
+}
+
+// causal build additive causal mask in shape (seq, seq)
+func (b *onnxBuilder) causal(name string, t consts.ScalarType, seq string) (string, error) {
+	value, err := onnxTensor("value",
+		tensor.FromFloat32([]float32{float32(math.Inf(-1))}, tensor.WithShapes(1)).ToScalarType(t))
+	if err != nil {
+		return "", err
+	}
+	shape := b.node(name, "Concat", []string{seq, seq}, attrInt("axis", 0))
+	mask := b.node(name, "ConstantOfShape", []string{shape}, &onnx.AttributeProto{
+		Name: proto.String("value"),
+		Type: onnx.AttributeProto_TENSOR.Enum(),
+		T:    value,
+	})
+	return b.node(name, "Trilu", []string{mask, b.scalar(name+"/k", 1)}, attrInt("upper", 1)), nil
+}
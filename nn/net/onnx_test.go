@@ -0,0 +1,133 @@
+package net
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/lwch/gotorch/tensor"
+	"github.com/lwch/tnn/internal/onnx"
+	"github.com/lwch/tnn/nn/layer"
+	"github.com/lwch/tnn/nn/layer/activation"
+)
+
+func TestONNX(t *testing.T) {
+	var net Net
+	net.Add(layer.NewEmbedding("embedding", 10, 8))
+	net.Add(layer.NewAttention("attn", 8, 2, 0, true))
+	net.Add(layer.NewRMSNorm("norm", 8))
+	net.Add(layer.NewLinear("output", 8, 2))
+	net.Add(activation.NewGeLU(false))
+	model, err := net.buildONNX(WithONNXCausal(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph := model.GetGraph()
+	if graph.GetInput()[0].GetType().GetTensorType().GetElemType() != int32(onnx.TensorProto_INT64) {
+		t.Fatal("input of embedding must be int64")
+	}
+	ops := make(map[string]bool)
+	for _, node := range graph.GetNode() {
+		ops[node.GetOpType()] = true
+	}
+	for _, op := range []string{"Gather", "MatMul", "Softmax", "Trilu", "Cos", "Erf"} {
+		if !ops[op] {
+			t.Fatalf("missing op %s", op)
+		}
+	}
+	inits := make(map[string]bool)
+	for _, init := range graph.GetInitializer() {
+		inits[init.GetName()] = true
+	}
+	for _, name := range []string{"embedding.w", "attn.w", "norm.a", "output.w"} {
+		if !inits[name] {
+			t.Fatalf("missing initializer %s", name)
+		}
+	}
+}
+
+func TestONNXInitializers(t *testing.T) {
+	var net Net
+	net.Add(layer.NewEmbedding("embedding", 4, 3))
+	net.Add(layer.NewRMSNorm("norm", 3))
+	// w is [output, input], x*w^T in onnx is MatMul(x, Transpose(w))
+	w := tensor.FromFloat32([]float32{1, 2, 3, 4, 5, 6}, tensor.WithShapes(2, 3))
+	net.Add(layer.LoadLinear("output", map[string]*tensor.Tensor{"w": w},
+		map[string]float32{"output": 2}))
+	model, err := net.buildONNX()
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph := model.GetGraph()
+	inits := make(map[string]*onnx.TensorProto)
+	for _, init := range graph.GetInitializer() {
+		inits[init.GetName()] = init
+	}
+	checkInit := func(name string, shapes []int64, values []float32) {
+		init, ok := inits[name]
+		if !ok {
+			t.Fatalf("missing initializer %s", name)
+		}
+		if init.GetDataType() != int32(onnx.TensorProto_FLOAT) {
+			t.Fatalf("%s: unexpected data type %d", name, init.GetDataType())
+		}
+		dims := init.GetDims()
+		if len(dims) != len(shapes) {
+			t.Fatalf("%s: expect dims %v, got %v", name, shapes, dims)
+		}
+		for i := range dims {
+			if dims[i] != shapes[i] {
+				t.Fatalf("%s: expect dims %v, got %v", name, shapes, dims)
+			}
+		}
+		raw := init.GetRawData()
+		if len(raw) != len(values)*4 {
+			t.Fatalf("%s: expect %d bytes, got %d", name, len(values)*4, len(raw))
+		}
+		for i, v := range values {
+			if got := math.Float32frombits(binary.LittleEndian.Uint32(raw[i*4:])); got != v {
+				t.Fatalf("%s: value %d: expect %v, got %v", name, i, v, got)
+			}
+		}
+	}
+	// golden value of linear weight in row major order of [output, input]
+	checkInit("output.w", []int64{2, 3}, []float32{1, 2, 3, 4, 5, 6})
+	for _, l := range net.Layers() {
+		for name, p := range l.Params() {
+			checkInit(safetensorsKey(l.Name(), name), p.Shapes(), p.Float32Value())
+		}
+	}
+	// linear weight is transposed before MatMul
+	var transposed string
+	for _, node := range graph.GetNode() {
+		switch node.GetOpType() {
+		case "Transpose":
+			if node.GetInput()[0] != "output.w" {
+				continue
+			}
+			perm := node.GetAttribute()[0].GetInts()
+			if len(perm) != 2 || perm[0] != 1 || perm[1] != 0 {
+				t.Fatalf("unexpected perm of linear weight: %v", perm)
+			}
+			transposed = node.GetOutput()[0]
+		case "MatMul":
+			if transposed != "" && node.GetInput()[1] != transposed {
+				t.Fatalf("linear weight is not transposed for MatMul: %v", node.GetInput())
+			}
+		}
+	}
+	if transposed == "" {
+		t.Fatal("linear weight not transposed")
+	}
+}
+
+func TestONNXUnsupported(t *testing.T) {
+	var net Net
+	net.Add(layer.NewLstm("lstm", 2, 4, 3))
+	net.Add(layer.NewLinear("linear", 3, 1))
+	err := net.WriteONNX(nil)
+	if err == nil || !strings.Contains(err.Error(), "lstm(lstm)") {
+		t.Fatalf("expect unsupported error, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package net
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+
+	"github.com/lwch/tnn/nn/preprocessing"
+)
+
+const preprocessFile = "PREPROCESS"
+
+// SetPreprocessing set fitted preprocessing pipeline of features, it is saved
+// into model file alongside SPEC, nil to remove it
+func (n *Net) SetPreprocessing(p *preprocessing.Pipeline) {
+	n.preprocess = p
+}
+
+// Preprocessing get preprocessing pipeline saved with the model, returns nil when not set
+func (n *Net) Preprocessing() *preprocessing.Pipeline {
+	return n.preprocess
+}
+
+func (n *Net) writePreprocess(zw *zip.Writer) error {
+	if n.preprocess == nil {
+		return nil
+	}
+	data, err := n.preprocess.Marshal()
+	if err != nil {
+		return fmt.Errorf("preprocessing: %v", err)
+	}
+	f, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     preprocessFile,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+// readPreprocess read encoded pipeline from model file, returns nil when not exists
+func readPreprocess(zr *zip.Reader) ([]byte, error) {
+	f, err := zr.Open(preprocessFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// decodePreprocess decode pipeline, returns nil for empty data
+func decodePreprocess(data []byte) (*preprocessing.Pipeline, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	p, err := preprocessing.Unmarshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("preprocessing: %v", err)
+	}
+	return p, nil
+}
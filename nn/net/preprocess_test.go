@@ -0,0 +1,53 @@
+package net
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/lwch/tnn/nn/layer"
+	"github.com/lwch/tnn/nn/preprocessing"
+)
+
+func TestPreprocessing(t *testing.T) {
+	p := preprocessing.New(preprocessing.StandardScaler(0), preprocessing.OneHot(1))
+	err := p.Fit([][]float32{{1, 0}, {3, 1}, {5, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var net Net
+	net.Add(layer.NewLinear("linear", 4, 1))
+	net.SetPreprocessing(p)
+	dir := t.TempDir()
+	if err = net.Save(filepath.Join(dir, "model")); err != nil {
+		t.Fatal(err)
+	}
+	if err = net.SaveSharded(filepath.Join(dir, "model.index.json"), 1024); err != nil {
+		t.Fatal(err)
+	}
+	if err = net.SaveSafetensors(filepath.Join(dir, "model.safetensors")); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"model", "model.index.json", "model.safetensors"} {
+		var loaded Net
+		load := loaded.Load
+		if filepath.Ext(name) == ".safetensors" {
+			load = loaded.LoadSafetensors
+		}
+		if err = load(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+		if loaded.Preprocessing() == nil {
+			t.Fatalf("%s: preprocessing not loaded", name)
+		}
+		row, err := loaded.Preprocessing().Transform([]float32{3, 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := []float32{0, 0, 0, 1}
+		for i := range expect {
+			if row[i] != expect[i] {
+				t.Fatalf("%s: expect %v, got %v", name, expect, row)
+			}
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package net
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/lwch/gotorch/consts"
+	"github.com/lwch/gotorch/tensor"
+	"github.com/lwch/tnn/internal/pb"
+	"github.com/lwch/tnn/nn/layer"
+	"github.com/lwch/tnn/nn/sample"
+)
+
+// quantizeClasses class of layers which can be quantized to int8 => class of quantized layer
+var quantizeClasses = map[string]string{
+	"linear":    "qlinear",
+	"conv1d":    "qconv1d",
+	"conv2d":    "qconv2d",
+	"embedding": "qembedding",
+}
+
+// quantize4Classes class of layers which can be quantized to 4 bits => class of quantized layer
+var quantize4Classes = map[string]string{
+	"linear":    "q4linear",
+	"attention": "q4attention",
+}
+
+const defaultGroupSize = 128
+
+// maxCalibrationRows max input rows of each layer kept for calibration
+const maxCalibrationRows = 4096
+
+// clipRatios candidates of clipping ratio searched by calibration
+var clipRatios = []float32{1, 0.95, 0.9, 0.85, 0.8, 0.75, 0.7, 0.65, 0.6, 0.55, 0.5}
+
+type quantizeOptions struct {
+	layers      []string
+	group       int
+	calibration *Calibration
+}
+
+// QuantizeOption option of quantizing
+type QuantizeOption func(*quantizeOptions)
+
+// WithQuantizeLayers only quantize layers of given names
+func WithQuantizeLayers(names ...string) QuantizeOption {
+	return func(opts *quantizeOptions) {
+		opts.layers = names
+	}
+}
+
+// WithGroupSize set group size of 4 bits quantization, default is 128,
+// the whole row is one group when it is shorter than group size
+func WithGroupSize(n int) QuantizeOption {
+	return func(opts *quantizeOptions) {
+		opts.group = n
+	}
+}
+
+// Calibration samples to calibrate 4 bits quantization
+type Calibration struct {
+	Reader *sample.Reader
+	// BatchSize samples of each forward, default is 16
+	BatchSize int
+	// Samples max samples to use, default is all samples of Reader
+	Samples int
+	// Inputs build inputs of the net from features of batch samples
+	Inputs func(features []float32, batch int) []*tensor.Tensor
+}
+
+// WithCalibration run samples through the net before quantizing, the clipping
+// range of each row is searched to minimize the error of layer outputs
+func WithCalibration(c Calibration) QuantizeOption {
+	return func(opts *quantizeOptions) {
+		opts.calibration = &c
+	}
+}
+
+// selectLayers get index of layers to quantize
+func (n *Net) selectLayers(classes map[string]string, names []string) ([]int, error) {
+	selected := make(map[string]bool, len(names))
+	for _, name := range names {
+		selected[name] = false
+	}
+	var ret []int
+	for i, l := range n.layers {
+		_, ok := classes[l.Class()]
+		if len(names) > 0 {
+			if _, found := selected[l.Name()]; !found {
+				continue
+			}
+			if !ok {
+				return nil, fmt.Errorf("layer %d(%s): can not quantize %s layer", i, l.Name(), l.Class())
+			}
+			selected[l.Name()] = true
+		}
+		if ok {
+			ret = append(ret, i)
+		}
+	}
+	for name, found := range selected {
+		if !found {
+			return nil, fmt.Errorf("layer %s not found", name)
+		}
+	}
+	return ret, nil
+}
+
+func (n *Net) currentParams() map[string]*tensor.Tensor {
+	current := make(map[string]*tensor.Tensor)
+	for i, l := range n.layers {
+		for name, p := range l.Params() {
+			current[paramFile(i, name)] = p
+		}
+	}
+	return current
+}
+
+func (n *Net) rebuild(spec *pb.Net, current map[string]*tensor.Tensor) error {
+	return n.build(spec, func(param *pb.Param, device consts.DeviceType) (*tensor.Tensor, error) {
+		return current[param.GetFile()].ToDevice(device), nil
+	})
+}
+
+// QuantizeInt8 convert weight of linear, conv1d, conv2d and embedding layers
+// to int8 per output channel (per row for embedding) with scale and zero point,
+// layers are replaced by quantized layers which dequantize weight on forward
+func (n *Net) QuantizeInt8(opts ...QuantizeOption) error {
+	var options quantizeOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	spec, err := n.buildSpec(paramFile)
+	if err != nil {
+		return err
+	}
+	targets, err := n.selectLayers(quantizeClasses, options.layers)
+	if err != nil {
+		return err
+	}
+	current := n.currentParams()
+	for _, i := range targets {
+		l := n.layers[i]
+		q, scale, zero, err := layer.QuantizeInt8(l.Params()["w"])
+		if err != nil {
+			return fmt.Errorf("layer %d(%s): %v", i, l.Name(), err)
+		}
+		params := spec.Layers[i].Params
+		for name, t := range map[string]*tensor.Tensor{"w": q, "scale": scale, "zero": zero} {
+			params[name] = newParam(name, paramFile(i, name), t)
+			current[paramFile(i, name)] = t
+		}
+		spec.Layers[i].Class = quantizeClasses[l.Class()]
+	}
+	return n.rebuild(spec, current)
+}
+
+// QuantizeInt4 convert weight of linear and attention layers to 4 bits by
+// groups of each row, two values are packed into one uint8,
+// the encoding is recorded in quant of param
+func (n *Net) QuantizeInt4(opts ...QuantizeOption) error {
+	options := quantizeOptions{group: defaultGroupSize}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	spec, err := n.buildSpec(paramFile)
+	if err != nil {
+		return err
+	}
+	targets, err := n.selectLayers(quantize4Classes, options.layers)
+	if err != nil {
+		return err
+	}
+	groups := make(map[int]int, len(targets))
+	for _, i := range targets {
+		l := n.layers[i]
+		cols := int(l.Params()["w"].Shapes()[1])
+		group := options.group
+		if group > cols {
+			group = cols
+		}
+		if group <= 0 || group%2 != 0 || cols%group != 0 {
+			return fmt.Errorf("layer %d(%s): invalid group size %d for %d columns", i, l.Name(), group, cols)
+		}
+		groups[i] = group
+	}
+	var inputs map[layer.Layer]*tensor.Tensor
+	if options.calibration != nil {
+		inputs, err = n.calibrate(targets, options.calibration)
+		if err != nil {
+			return err
+		}
+	}
+	current := n.currentParams()
+	for _, i := range targets {
+		l := n.layers[i]
+		w := l.Params()["w"]
+		var clip []float32
+		if x, ok := inputs[l]; ok {
+			clip, err = searchClip(w, x, groups[i])
+			if err != nil {
+				return fmt.Errorf("layer %d(%s): %v", i, l.Name(), err)
+			}
+		}
+		packed, scale, zero, err := layer.QuantizeInt4(w, groups[i], clip)
+		if err != nil {
+			return fmt.Errorf("layer %d(%s): %v", i, l.Name(), err)
+		}
+		params := spec.Layers[i].Params
+		for name, t := range map[string]*tensor.Tensor{"w": packed, "scale": scale, "zero": zero} {
+			params[name] = newParam(name, paramFile(i, name), t)
+			current[paramFile(i, name)] = t
+		}
+		params["w"].Quant = &pb.Quant{
+			Bits:      4,
+			GroupSize: int64(groups[i]),
+			Shapes:    w.Shapes(),
+		}
+		spec.Layers[i].Class = quantize4Classes[l.Class()]
+		spec.Layers[i].Args["group"] = float32(groups[i])
+	}
+	return n.rebuild(spec, current)
+}
+
+// layerInput get input of weight multiplication from inputs of layer
+func layerInput(l layer.Layer, inputs []*tensor.Tensor) *tensor.Tensor {
+	if _, ok := l.(*layer.Attention); ok && len(inputs) >= 3 {
+		return tensor.Cat(inputs[:3], -1)
+	} else if ok {
+		return tensor.Cat([]*tensor.Tensor{inputs[0], inputs[0], inputs[0]}, -1)
+	}
+	return inputs[0]
+}
+
+// calibrate run samples through the net and collect inputs of target layers
+func (n *Net) calibrate(targets []int, c *Calibration) (map[layer.Layer]*tensor.Tensor, error) {
+	if c.Reader == nil || c.Inputs == nil {
+		return nil, fmt.Errorf("calibration: missing reader or inputs")
+	}
+	collected := make(map[layer.Layer][]*tensor.Tensor, len(targets))
+	rows := make(map[layer.Layer]int64, len(targets))
+	for _, i := range targets {
+		collected[n.layers[i]] = nil
+	}
+	observe := func(l layer.Layer, inputs []*tensor.Tensor) {
+		if _, ok := collected[l]; !ok || rows[l] >= maxCalibrationRows {
+			return
+		}
+		cols := l.Params()["w"].Shapes()[1]
+		x := layerInput(l, inputs).Reshape(-1, cols).ToScalarType(consts.KFloat)
+		collected[l] = append(collected[l], x)
+		rows[l] += x.Shapes()[0]
+	}
+	batchSize := c.BatchSize
+	if batchSize <= 0 {
+		batchSize = 16
+	}
+	total := int(c.Reader.BatchSize())
+	if c.Samples > 0 && c.Samples < total {
+		total = c.Samples
+	}
+	featureSize := int(c.Reader.FeatureSize())
+	labels := make([]float32, c.Reader.LabelSize())
+	for start := 0; start < total; start += batchSize {
+		batch := batchSize
+		if start+batch > total {
+			batch = total - start
+		}
+		features := make([]float32, batch*featureSize)
+		for j := 0; j < batch; j++ {
+			err := c.Reader.ReadSample(uint32(start+j), features[j*featureSize:(j+1)*featureSize], labels)
+			if err != nil {
+				return nil, fmt.Errorf("calibration: read sample %d: %v", start+j, err)
+			}
+		}
+		if _, err := n.forward(layer.NewContext(false), c.Inputs(features, batch), observe); err != nil {
+			return nil, fmt.Errorf("calibration: %v", err)
+		}
+	}
+	ret := make(map[layer.Layer]*tensor.Tensor, len(collected))
+	for l, list := range collected {
+		if len(list) == 0 {
+			continue
+		}
+		x := tensor.Cat(list, 0)
+		if rows[l] > maxCalibrationRows {
+			x = x.NArrow(0, 0, maxCalibrationRows)
+		}
+		ret[l] = x
+	}
+	return ret, nil
+}
+
+// searchClip choose clipping ratio of each row which minimizes
+// squared error of x*w^T after quantizing
+func searchClip(w, x *tensor.Tensor, group int) ([]float32, error) {
+	w = w.ToScalarType(consts.KFloat).ToDevice(x.DeviceType())
+	rows := int(w.Shapes()[0])
+	best := make([]float32, rows)
+	bestErr := make([]float64, rows)
+	for i := range bestErr {
+		bestErr[i] = math.Inf(1)
+	}
+	clip := make([]float32, rows)
+	for _, ratio := range clipRatios {
+		for i := range clip {
+			clip[i] = ratio
+		}
+		packed, scale, zero, err := layer.QuantizeInt4(w, group, clip)
+		if err != nil {
+			return nil, err
+		}
+		diff := w.Sub(layer.DequantizeInt4(packed, scale, zero, group))
+		errs := x.MatMul(diff.Transpose(0, 1)).Pow(2).Sum(0, false).
+			ToDevice(consts.KCPU).Float32Value()
+		for i, e := range errs {
+			if float64(e) < bestErr[i] {
+				bestErr[i] = float64(e)
+				best[i] = ratio
+			}
+		}
+	}
+	return best, nil
+}
@@ -0,0 +1,212 @@
+package net
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lwch/gotorch/consts"
+	"github.com/lwch/gotorch/tensor"
+	"github.com/lwch/tnn/internal/nnutil"
+	"github.com/lwch/tnn/nn/layer"
+	"github.com/lwch/tnn/nn/layer/activation"
+	"github.com/lwch/tnn/nn/sample"
+)
+
+func TestQuantizeInt8(t *testing.T) {
+	var net Net
+	net.Add(layer.NewLinear("hidden", 4, 8))
+	net.Add(activation.NewReLU())
+	net.Add(layer.NewLinear("output", 8, 2))
+	x := tensor.FromFloat32([]float32{0, 1, 2, 3, 3, 2, 1, 0}, tensor.WithShapes(2, 4))
+	want, err := net.Forward(layer.NewContext(false), x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = net.QuantizeInt8(); err != nil {
+		t.Fatal(err)
+	}
+	l := net.Layers()[0]
+	if l.Class() != "qlinear" {
+		t.Fatalf("invalid class: %s", l.Class())
+	}
+	if l.Params()["w"].ScalarType() != consts.KInt8 {
+		t.Fatalf("invalid type: %s", l.Params()["w"].ScalarType().String())
+	}
+	dir := filepath.Join(t.TempDir(), "quantized.model")
+	if err = net.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	var loaded Net
+	if err = loaded.Load(dir); err != nil {
+		t.Fatal(err)
+	}
+	got, err := loaded.Forward(layer.NewContext(false), x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := want[0].Float32Value(), got[0].Float32Value()
+	for i := range a {
+		if math.Abs(float64(a[i]-b[i])) > 0.05*(1+math.Abs(float64(a[i]))) {
+			t.Fatalf("invalid output: %v, want %v", b, a)
+		}
+	}
+}
+
+func TestQuantizeLayers(t *testing.T) {
+	var net Net
+	net.Add(layer.NewEmbedding("embedding", 10, 4))
+	net.Add(layer.NewLinear("output", 4, 2))
+	if err := net.QuantizeInt8(WithQuantizeLayers("output")); err != nil {
+		t.Fatal(err)
+	}
+	if net.Layers()[0].Class() != "embedding" || net.Layers()[1].Class() != "qlinear" {
+		t.Fatal("invalid quantized layers")
+	}
+	if err := net.QuantizeInt8(WithQuantizeLayers("unknown")); err == nil {
+		t.Fatal("expect error of unknown layer")
+	}
+}
+
+func TestQuantizeInt4(t *testing.T) {
+	var net Net
+	net.Add(layer.NewLinear("hidden", 8, 16))
+	net.Add(activation.NewReLU())
+	net.Add(layer.NewLinear("output", 16, 2))
+	dir := filepath.Join(t.TempDir(), "samples")
+	f, err := os.Create(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := sample.NewWriter(f)
+	for i := 0; i < 32; i++ {
+		features := make([]float32, 8)
+		for j := range features {
+			features[j] = float32((i+j)%5) / 5
+		}
+		if err = w.WriteSample(features, []float32{0}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+	f.Close()
+	f, err = os.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := sample.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := tensor.FromFloat32([]float32{0, 0.2, 0.4, 0.6, 0.8, 0, 0.2, 0.4}, tensor.WithShapes(1, 8))
+	want, err := net.Forward(layer.NewContext(false), x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = net.QuantizeInt4(WithGroupSize(4), WithCalibration(Calibration{
+		Reader:    r,
+		BatchSize: 8,
+		Inputs: func(features []float32, batch int) []*tensor.Tensor {
+			return []*tensor.Tensor{tensor.FromFloat32(features, tensor.WithShapes(int64(batch), 8))}
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := net.Layers()[0]
+	if l.Class() != "q4linear" {
+		t.Fatalf("invalid class: %s", l.Class())
+	}
+	shapes := l.Params()["w"].Shapes()
+	if l.Params()["w"].ScalarType() != consts.KUint8 || shapes[0] != 16 || shapes[1] != 4 {
+		t.Fatalf("invalid packed param: %s %v", l.Params()["w"].ScalarType().String(), shapes)
+	}
+	model := filepath.Join(t.TempDir(), "q4.model")
+	if err = net.Save(model); err != nil {
+		t.Fatal(err)
+	}
+	var loaded Net
+	if err = loaded.Load(model); err != nil {
+		t.Fatal(err)
+	}
+	got, err := loaded.Forward(layer.NewContext(false), x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := want[0].Float32Value(), got[0].Float32Value()
+	for i := range a {
+		if math.Abs(float64(a[i]-b[i])) > 0.2*(1+math.Abs(float64(a[i]))) {
+			t.Fatalf("invalid output: %v, want %v", b, a)
+		}
+	}
+	spec, err := loaded.buildSpec(paramFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	quant := spec.Layers[0].Params["w"].GetQuant()
+	if quant.GetBits() != 4 || quant.GetGroupSize() != 4 || !nnutil.SameShapes(quant.GetShapes(), []int64{16, 8}) {
+		t.Fatalf("invalid quant encoding: %v", quant)
+	}
+}
+
+func TestQuantizeInt8Layers(t *testing.T) {
+	seq := func(n int) []float32 {
+		ret := make([]float32, n)
+		for i := range ret {
+			ret[i] = float32(i%7) / 7
+		}
+		return ret
+	}
+	tests := []struct {
+		layer layer.Layer
+		class string
+		x     *tensor.Tensor
+	}{
+		{layer.NewEmbedding("embedding", 10, 4), "qembedding",
+			tensor.FromInt64([]int64{1, 3, 5}, tensor.WithShapes(3))},
+		{layer.NewConv1D("conv1d", 2, 4, 3), "qconv1d",
+			tensor.FromFloat32(seq(10), tensor.WithShapes(1, 2, 5))},
+		{layer.NewConv2D("conv2d", 2, 4, 3, 3), "qconv2d",
+			tensor.FromFloat32(seq(32), tensor.WithShapes(1, 2, 4, 4))},
+	}
+	for _, tt := range tests {
+		var net Net
+		net.Add(tt.layer)
+		want, err := net.Forward(layer.NewContext(false), tt.x)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = net.QuantizeInt8(); err != nil {
+			t.Fatal(err)
+		}
+		if class := net.Layers()[0].Class(); class != tt.class {
+			t.Fatalf("invalid class: %s, want %s", class, tt.class)
+		}
+		dir := filepath.Join(t.TempDir(), tt.class+".model")
+		if err = net.Save(dir); err != nil {
+			t.Fatal(err)
+		}
+		var loaded Net
+		if err = loaded.Load(dir); err != nil {
+			t.Fatal(err)
+		}
+		if w := loaded.Layers()[0].Params()["w"]; w.ScalarType() != consts.KInt8 {
+			t.Fatalf("%s: invalid type: %s", tt.class, w.ScalarType().String())
+		}
+		got, err := loaded.Forward(layer.NewContext(false), tt.x)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, b := want[0].Float32Value(), got[0].Float32Value()
+		if len(a) != len(b) {
+			t.Fatalf("%s: expect %d values, got %d", tt.class, len(a), len(b))
+		}
+		for i := range a {
+			if math.Abs(float64(a[i]-b[i])) > 0.05*(1+math.Abs(float64(a[i]))) {
+				t.Fatalf("%s: invalid output: %v, want %v", tt.class, b, a)
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package net
+
+import "math/rand"
+
+// RandSource random source which state can be saved in checkpoint,
+// use rand.New(src) to create *rand.Rand for shuffling etc.
+type RandSource struct {
+	state uint64
+}
+
+var _ rand.Source64 = &RandSource{}
+
+// NewRandSource create random source
+func NewRandSource(seed int64) *RandSource {
+	return &RandSource{state: uint64(seed)}
+}
+
+// Seed reset state by seed
+func (src *RandSource) Seed(seed int64) {
+	src.state = uint64(seed)
+}
+
+// Uint64 splitmix64 algorithm
+func (src *RandSource) Uint64() uint64 {
+	src.state += 0x9e3779b97f4a7c15
+	z := src.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func (src *RandSource) Int63() int64 {
+	return int64(src.Uint64() >> 1)
+}
+
+// State get current state
+func (src *RandSource) State() uint64 {
+	return src.state
+}
+
+// SetState restore state
+func (src *RandSource) SetState(state uint64) {
+	src.state = state
+}
@@ -0,0 +1,384 @@
+package net
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"unsafe"
+
+	"github.com/lwch/gotorch/consts"
+	"github.com/lwch/gotorch/tensor"
+	"github.com/lwch/tnn/internal/mmap"
+	"github.com/lwch/tnn/internal/nnutil"
+	"github.com/lwch/tnn/internal/pb"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// metadata key of safetensors file which stores SPEC, the value is one
+// protojson encoded string which is opaque to other safetensors tools
+const safetensorsSpec = "tnn.spec"
+
+// metadata key of safetensors file which stores the base64 encoded preprocessing pipeline
+const safetensorsPreprocess = "tnn.preprocess"
+
+// maxSafetensorsHeader limit header size to avoid huge allocation on invalid file
+const maxSafetensorsHeader = 100 << 20
+
+var safetensorsTypes = map[consts.ScalarType]string{
+	consts.KUint8:    "U8",
+	consts.KInt8:     "I8",
+	consts.KInt16:    "I16",
+	consts.KInt32:    "I32",
+	consts.KInt64:    "I64",
+	consts.KHalf:     "F16",
+	consts.KFloat:    "F32",
+	consts.KDouble:   "F64",
+	consts.KBool:     "BOOL",
+	consts.KBFloat16: "BF16",
+}
+
+// SafetensorsInfo tensor info in safetensors header
+type SafetensorsInfo struct {
+	DType       string   `json:"dtype"`
+	Shape       []int64  `json:"shape"`
+	DataOffsets [2]int64 `json:"data_offsets"`
+}
+
+func safetensorsType(dtype string) (consts.ScalarType, bool) {
+	for t, name := range safetensorsTypes {
+		if name == dtype {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+func safetensorsKey(name, param string) string {
+	return name + "." + param
+}
+
+// SaveSafetensors save model in safetensors format, params are named as
+// layer_name.param_name, so other tools can read the params by name. Class,
+// args and graph of layers are stored in __metadata__ as one protojson
+// encoded SPEC under key tnn.spec, the preprocessing pipeline is stored
+// under key tnn.preprocess, only LoadSafetensors understands them
+func (n *Net) SaveSafetensors(dir string) error {
+	keys := make(map[string]bool)
+	var dupErr error
+	spec, err := n.buildSpec(func(i int, name string) string {
+		key := safetensorsKey(n.layers[i].Name(), name)
+		if keys[key] {
+			dupErr = fmt.Errorf("duplicate param name: %s", key)
+		}
+		keys[key] = true
+		return key
+	})
+	if err != nil {
+		return err
+	}
+	if dupErr != nil {
+		return dupErr
+	}
+	data, err := protojson.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	tensors := make(map[string]*tensor.Tensor)
+	var names []string
+	for i, l := range n.layers {
+		params := l.Params()
+		var paramNames []string
+		for name := range params {
+			paramNames = append(paramNames, name)
+		}
+		sort.Strings(paramNames)
+		for _, name := range paramNames {
+			key := spec.Layers[i].Params[name].GetFile()
+			tensors[key] = params[name]
+			names = append(names, key)
+		}
+	}
+	metadata := map[string]string{
+		"format":        "pt",
+		safetensorsSpec: string(data),
+	}
+	if n.preprocess != nil {
+		data, err := n.preprocess.Marshal()
+		if err != nil {
+			return fmt.Errorf("preprocessing: %v", err)
+		}
+		metadata[safetensorsPreprocess] = base64.StdEncoding.EncodeToString(data)
+	}
+	return atomicWrite(dir, func(w io.Writer) error {
+		return WriteSafetensors(w, names, tensors, metadata)
+	})
+}
+
+// WriteSafetensors write tensors in order of names with metadata
+func WriteSafetensors(w io.Writer, names []string, tensors map[string]*tensor.Tensor, metadata map[string]string) error {
+	header := make(map[string]interface{}, len(tensors)+1)
+	if len(metadata) > 0 {
+		header["__metadata__"] = metadata
+	}
+	var offset int64
+	for _, name := range names {
+		t := tensors[name]
+		dtype, ok := safetensorsTypes[t.ScalarType()]
+		if !ok {
+			return fmt.Errorf("%s: unsupported scalar type: %s", name, t.ScalarType().String())
+		}
+		size := t.ElemCount() * elemSize(t.ScalarType())
+		header[name] = SafetensorsInfo{
+			DType:       dtype,
+			Shape:       t.Shapes(),
+			DataOffsets: [2]int64{offset, offset + size},
+		}
+		offset += size
+	}
+	data, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	// align data to 8 bytes
+	if pad := len(data) % 8; pad != 0 {
+		data = append(data, bytes.Repeat([]byte{' '}, 8-pad)...)
+	}
+	bw := bufio.NewWriter(w)
+	if err = binary.Write(bw, binary.LittleEndian, uint64(len(data))); err != nil {
+		return err
+	}
+	if _, err = bw.Write(data); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err = writeTensor(bw, binary.LittleEndian, tensors[name]); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return bw.Flush()
+}
+
+// Safetensors memory mapped safetensors file
+type Safetensors struct {
+	data     []byte
+	header   map[string]SafetensorsInfo
+	metadata map[string]string
+	close    func() error
+}
+
+// OpenSafetensors open safetensors file by mmap
+func OpenSafetensors(dir string) (*Safetensors, error) {
+	data, close, err := mmap.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	st, err := parseSafetensors(data)
+	if err != nil {
+		close()
+		return nil, err
+	}
+	st.close = close
+	return st, nil
+}
+
+func parseSafetensors(data []byte) (*Safetensors, error) {
+	if len(data) < 8 {
+		return nil, errors.New("invalid safetensors file: too small")
+	}
+	size := binary.LittleEndian.Uint64(data)
+	if size > maxSafetensorsHeader || size > uint64(len(data)-8) {
+		return nil, fmt.Errorf("invalid safetensors header size: %d", size)
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data[8:8+size], &raw); err != nil {
+		return nil, fmt.Errorf("invalid safetensors header: %v", err)
+	}
+	st := &Safetensors{
+		data:   data[8+size:],
+		header: make(map[string]SafetensorsInfo, len(raw)),
+	}
+	for name, v := range raw {
+		if name == "__metadata__" {
+			if err := json.Unmarshal(v, &st.metadata); err != nil {
+				return nil, fmt.Errorf("invalid safetensors metadata: %v", err)
+			}
+			continue
+		}
+		var info SafetensorsInfo
+		if err := json.Unmarshal(v, &info); err != nil {
+			return nil, fmt.Errorf("invalid safetensors header of %s: %v", name, err)
+		}
+		t, ok := safetensorsType(info.DType)
+		if !ok {
+			return nil, fmt.Errorf("%s: unsupported dtype %s", name, info.DType)
+		}
+		// cnt*size never exceeds end-begin, so it can not overflow
+		cnt, size := int64(1), elemSize(t)
+		for _, s := range info.Shape {
+			if s < 0 {
+				return nil, fmt.Errorf("%s: invalid shape %v", name, info.Shape)
+			}
+			if s > 0 && cnt > math.MaxInt64/size/s {
+				return nil, fmt.Errorf("%s: shape %v overflow", name, info.Shape)
+			}
+			cnt *= s
+		}
+		begin, end := info.DataOffsets[0], info.DataOffsets[1]
+		if begin < 0 || end < begin || end > int64(len(st.data)) ||
+			end-begin != cnt*size {
+			return nil, fmt.Errorf("%s: invalid data offsets %v", name, info.DataOffsets)
+		}
+		st.header[name] = info
+	}
+	return st, nil
+}
+
+// Close unmap file
+func (st *Safetensors) Close() error {
+	if st.close == nil {
+		return nil
+	}
+	return st.close()
+}
+
+// Metadata get __metadata__ of file
+func (st *Safetensors) Metadata() map[string]string {
+	return st.metadata
+}
+
+// Names get all tensor names in order of offset
+func (st *Safetensors) Names() []string {
+	names := make([]string, 0, len(st.header))
+	for name := range st.header {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return st.header[names[i]].DataOffsets[0] < st.header[names[j]].DataOffsets[0]
+	})
+	return names
+}
+
+// Info get tensor info by name
+func (st *Safetensors) Info(name string) (SafetensorsInfo, bool) {
+	info, ok := st.header[name]
+	return info, ok
+}
+
+// Tensor create tensor on device by name
+func (st *Safetensors) Tensor(name string, device consts.DeviceType) (*tensor.Tensor, error) {
+	info, ok := st.header[name]
+	if !ok {
+		return nil, fmt.Errorf("tensor %s not found", name)
+	}
+	t, _ := safetensorsType(info.DType)
+	data := st.data[info.DataOffsets[0]:info.DataOffsets[1]]
+	return decodeTensor(data, binary.LittleEndian, t, info.Shape, device)
+}
+
+// castSlice reinterpret data without an intermediate copy when byte order and
+// alignment permits, note that tensor.FromXXX still copies it into the tensor
+func castSlice[T elemType](data []byte, order binary.ByteOrder) []T {
+	var zero T
+	cnt := len(data) / int(unsafe.Sizeof(zero))
+	if cnt == 0 {
+		return nil
+	}
+	ptr := unsafe.Pointer(unsafe.SliceData(data))
+	if order == nativeOrder && uintptr(ptr)%unsafe.Alignof(zero) == 0 {
+		if _, ok := any(zero).(bool); !ok {
+			return unsafe.Slice((*T)(ptr), cnt)
+		}
+	}
+	ret := make([]T, cnt)
+	copy(asBytes(ret), data)
+	fixOrder(ret, order)
+	return ret
+}
+
+func fromBytes[T elemType](data []byte, order binary.ByteOrder, shapes []int64, device consts.DeviceType,
+	fn func(data []T, opts ...tensor.Option) *tensor.Tensor) (*tensor.Tensor, error) {
+	return fn(castSlice[T](data, order),
+		tensor.WithShapes(shapes...),
+		tensor.WithDevice(device)), nil
+}
+
+func decodeTensor(data []byte, order binary.ByteOrder, t consts.ScalarType, shapes []int64, device consts.DeviceType) (*tensor.Tensor, error) {
+	switch t {
+	case consts.KUint8:
+		return fromBytes[uint8](data, order, shapes, device, tensor.FromUint8)
+	case consts.KInt8:
+		return fromBytes[int8](data, order, shapes, device, tensor.FromInt8)
+	case consts.KInt16:
+		return fromBytes[int16](data, order, shapes, device, tensor.FromInt16)
+	case consts.KInt32:
+		return fromBytes[int32](data, order, shapes, device, tensor.FromInt32)
+	case consts.KInt64:
+		return fromBytes[int64](data, order, shapes, device, tensor.FromInt64)
+	case consts.KHalf:
+		return fromBytes[uint16](data, order, shapes, device, tensor.FromHalfRaw)
+	case consts.KFloat:
+		return fromBytes[float32](data, order, shapes, device, tensor.FromFloat32)
+	case consts.KDouble:
+		return fromBytes[float64](data, order, shapes, device, tensor.FromFloat64)
+	case consts.KBool:
+		return fromBytes[bool](data, order, shapes, device, tensor.FromBool)
+	case consts.KBFloat16:
+		return fromBytes[uint16](data, order, shapes, device, tensor.FromBFloat16Raw)
+	default:
+		return nil, fmt.Errorf("unsupported scalar type: %s", t.String())
+	}
+}
+
+// LoadSafetensors load model saved by SaveSafetensors
+func (n *Net) LoadSafetensors(dir string, opts ...LoadOption) error {
+	st, err := OpenSafetensors(dir)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	data, ok := st.Metadata()[safetensorsSpec]
+	if !ok {
+		return fmt.Errorf("missing %s in metadata, not saved by tnn", safetensorsSpec)
+	}
+	var spec pb.Net
+	if err = protojson.Unmarshal([]byte(data), &spec); err != nil {
+		return fmt.Errorf("invalid %s in metadata: %v", safetensorsSpec, err)
+	}
+	preprocess, err := base64.StdEncoding.DecodeString(st.Metadata()[safetensorsPreprocess])
+	if err != nil {
+		return fmt.Errorf("invalid %s in metadata: %v", safetensorsPreprocess, err)
+	}
+	pipeline, err := decodePreprocess(preprocess)
+	if err != nil {
+		return err
+	}
+	err = n.build(&spec, func(param *pb.Param, device consts.DeviceType) (*tensor.Tensor, error) {
+		info, ok := st.Info(param.GetFile())
+		if !ok {
+			return nil, fmt.Errorf("tensor %s not found", param.GetFile())
+		}
+		if info.DType != safetensorsTypes[consts.ScalarType(param.GetType())] {
+			return nil, fmt.Errorf("tensor %s: mismatched dtype %s", param.GetFile(), info.DType)
+		}
+		if err := checkShapes(param.GetElemCount(), info.Shape); err != nil {
+			return nil, fmt.Errorf("tensor %s: %v", param.GetFile(), err)
+		}
+		// same elem count in other layout is not the same param
+		if !nnutil.SameShapes(info.Shape, param.GetShapes()) {
+			return nil, fmt.Errorf("tensor %s: shapes %v mismatch %v in spec", param.GetFile(), info.Shape, param.GetShapes())
+		}
+		return st.Tensor(param.GetFile(), device)
+	}, opts...)
+	if err != nil {
+		return err
+	}
+	n.preprocess = pipeline
+	return nil
+}
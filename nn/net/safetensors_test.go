@@ -0,0 +1,102 @@
+package net
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lwch/gotorch/tensor"
+	"github.com/lwch/tnn/nn/layer"
+)
+
+func TestSafetensors(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "test.safetensors")
+	var net Net
+	net.Add(layer.NewLinear("linear", 2, 3))
+	if err := net.SaveSafetensors(dir); err != nil {
+		t.Fatal(err)
+	}
+	st, err := OpenSafetensors(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	if _, ok := st.Info("linear.w"); !ok {
+		t.Fatalf("missing linear.w, got %v", st.Names())
+	}
+	var loaded Net
+	if err = loaded.LoadSafetensors(dir); err != nil {
+		t.Fatal(err)
+	}
+	want := net.Layers()[0].Params()["w"].Float32Value()
+	got := loaded.Layers()[0].Params()["w"].Float32Value()
+	if len(want) != len(got) {
+		t.Fatalf("invalid param size: %d", len(got))
+	}
+	for i := range want {
+		if want[i] != got[i] {
+			t.Fatalf("param mismatch at %d: %v != %v", i, want[i], got[i])
+		}
+	}
+
+	// same elem count in transposed shapes
+	w, err := st.Tensor("linear.w", net.Layers()[0].Params()["w"].DeviceType())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	err = WriteSafetensors(&buf, []string{"linear.w"},
+		map[string]*tensor.Tensor{"linear.w": w.Reshape(2, 3)}, st.Metadata())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir = filepath.Join(t.TempDir(), "transposed.safetensors")
+	if err = os.WriteFile(dir, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = loaded.LoadSafetensors(dir); err == nil {
+		t.Fatal("expect error of mismatched shapes")
+	}
+}
+
+func TestSafetensorsDType(t *testing.T) {
+	tensors := map[string]*tensor.Tensor{
+		"f64":  tensor.FromFloat64([]float64{1, 2}, tensor.WithShapes(2)),
+		"i64":  tensor.FromInt64([]int64{1, 2, 3}, tensor.WithShapes(3)),
+		"bool": tensor.FromBool([]bool{true}, tensor.WithShapes(1)),
+		"bf16": tensor.FromBFloat16([]float32{1.5}, tensor.WithShapes(1)),
+	}
+	names := []string{"f64", "i64", "bool", "bf16"}
+	var buf bytes.Buffer
+	if err := WriteSafetensors(&buf, names, tensors, nil); err != nil {
+		t.Fatal(err)
+	}
+	st, err := parseSafetensors(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		tt, err := st.Tensor(name, tensors[name].DeviceType())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.ScalarType() != tensors[name].ScalarType() {
+			t.Fatalf("%s: invalid scalar type %s", name, tt.ScalarType().String())
+		}
+	}
+	if _, err = parseSafetensors(buf.Bytes()[:16]); err == nil {
+		t.Fatal("expect error on truncated file")
+	}
+}
+
+func TestSafetensorsOverflow(t *testing.T) {
+	header := []byte(`{"x":{"dtype":"F32","shape":[4611686018427387904,4],"data_offsets":[0,0]}}`)
+	data := make([]byte, 8, 8+len(header))
+	binary.LittleEndian.PutUint64(data, uint64(len(header)))
+	data = append(data, header...)
+	if _, err := parseSafetensors(data); err == nil {
+		t.Fatal("expect error on overflow shape")
+	}
+}
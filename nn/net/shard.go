@@ -0,0 +1,319 @@
+package net
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lwch/gotorch/consts"
+	"github.com/lwch/gotorch/tensor"
+	"github.com/lwch/tnn/internal/mmap"
+	"github.com/lwch/tnn/internal/pb"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const shardIndexFormat = "tnn"
+
+// ShardIndex index of sharded model files, weight_map maps each
+// layer_name/param_name to the file name of its shard
+type ShardIndex struct {
+	Metadata struct {
+		Format    string `json:"format"`
+		TotalSize int64  `json:"total_size"`
+	} `json:"metadata"`
+	Spec       json.RawMessage   `json:"spec"`
+	Preprocess []byte            `json:"preprocess,omitempty"` // encoded preprocessing pipeline
+	WeightMap  map[string]string `json:"weight_map"`
+}
+
+func shardKey(name, param string) string {
+	return name + "/" + param
+}
+
+// shardName build shard file name from index name and generation of the save,
+// e.g. model.tnn.index.json => model-00001-of-00002-g1.tnn
+func shardName(index string, gen, i, total int) string {
+	prefix, ext := shardPrefix(index)
+	return fmt.Sprintf("%s-%05d-of-%05d-g%d%s", prefix, i+1, total, gen, ext)
+}
+
+func shardPrefix(index string) (string, string) {
+	base := strings.TrimSuffix(filepath.Base(index), ".index.json")
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext), ext
+}
+
+// listShards list shard files of index in its directory with their generations,
+// shards saved without generation are generation 0
+func listShards(index string) (map[string]int, error) {
+	prefix, ext := shardPrefix(index)
+	digits := strings.Repeat("[0-9]", 5)
+	pattern := fmt.Sprintf("%s-%s-of-%s*%s", prefix, digits, digits, ext)
+	matches, err := filepath.Glob(filepath.Join(filepath.Dir(index), pattern))
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]int, len(matches))
+	for _, dir := range matches {
+		name := strings.TrimSuffix(filepath.Base(dir), ext)
+		// skip prefix-00001-of-00002
+		suffix := name[len(prefix)+len("-00001-of-00002"):]
+		if suffix == "" {
+			ret[filepath.Base(dir)] = 0
+			continue
+		}
+		gen, err := strconv.Atoi(strings.TrimPrefix(suffix, "-g"))
+		if err != nil || !strings.HasPrefix(suffix, "-g") || gen <= 0 {
+			continue
+		}
+		ret[filepath.Base(dir)] = gen
+	}
+	return ret, nil
+}
+
+// isShardIndex check whether data is a json index instead of a zip file
+func isShardIndex(data []byte) bool {
+	data = bytes.TrimLeft(data, " \t\r\n")
+	return len(data) > 0 && data[0] == '{'
+}
+
+// SaveSharded split params into files no larger than maxSize bytes
+// (a single param larger than maxSize takes one file), shards are written
+// next to the index file with a new generation in their names, the index is
+// written after all shards and then shards of previous saves are removed,
+// so the previous model is kept when crashed
+func (n *Net) SaveSharded(index string, maxSize int64, opts ...SaveOption) error {
+	if maxSize <= 0 {
+		return fmt.Errorf("invalid max size %d", maxSize)
+	}
+	options := saveOptions{method: zip.Deflate}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	keys := make(map[string]bool)
+	var dupErr error
+	spec, err := n.buildSpec(func(i int, name string) string {
+		key := shardKey(n.layers[i].Name(), name)
+		if keys[key] {
+			dupErr = fmt.Errorf("duplicate param name: %s", key)
+		}
+		keys[key] = true
+		return paramFile(i, name)
+	})
+	if err != nil {
+		return err
+	}
+	if dupErr != nil {
+		return dupErr
+	}
+	type item struct {
+		key   string
+		param *pb.Param
+		t     *tensor.Tensor
+	}
+	var shards [][]item
+	var size, total int64
+	for i, l := range n.layers {
+		params := l.Params()
+		names := make([]string, 0, len(params))
+		for name := range params {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			param := spec.Layers[i].Params[name]
+			cnt := param.GetElemCount() * elemSize(consts.ScalarType(param.GetType()))
+			if len(shards) == 0 || (size > 0 && size+cnt > maxSize) {
+				shards = append(shards, nil)
+				size = 0
+			}
+			shards[len(shards)-1] = append(shards[len(shards)-1], item{
+				key:   shardKey(l.Name(), name),
+				param: param,
+				t:     params[name],
+			})
+			size += cnt
+			total += cnt
+		}
+	}
+	var idx ShardIndex
+	idx.Metadata.Format = shardIndexFormat
+	idx.Metadata.TotalSize = total
+	idx.WeightMap = make(map[string]string)
+	existing, err := listShards(index)
+	if err != nil {
+		return err
+	}
+	gen := 1
+	for _, g := range existing {
+		if g >= gen {
+			gen = g + 1
+		}
+	}
+	dir := filepath.Dir(index)
+	for i, items := range shards {
+		name := shardName(index, gen, i, len(shards))
+		err = atomicWrite(filepath.Join(dir, name), func(w io.Writer) error {
+			zw := newZipWriter(w)
+			for _, it := range items {
+				if err := writeParam(zw, it.param.GetFile(), it.t, options.method); err != nil {
+					return fmt.Errorf("%s: %v", it.key, err)
+				}
+			}
+			return zw.Close()
+		})
+		if err != nil {
+			return err
+		}
+		for _, it := range items {
+			idx.WeightMap[it.key] = name
+		}
+	}
+	idx.Spec, err = protojson.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	if n.preprocess != nil {
+		idx.Preprocess, err = n.preprocess.Marshal()
+		if err != nil {
+			return fmt.Errorf("preprocessing: %v", err)
+		}
+	}
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = atomicWrite(index, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return removeStaleShards(index, idx.WeightMap)
+}
+
+// removeStaleShards remove shards of index which are not used by weights,
+// e.g. shards left by the previous save
+func removeStaleShards(index string, weights map[string]string) error {
+	used := make(map[string]bool)
+	for _, name := range weights {
+		used[name] = true
+	}
+	existing, err := listShards(index)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(index)
+	for name := range existing {
+		if used[name] {
+			continue
+		}
+		if err = os.Remove(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shardReader open shards on first use
+type shardReader struct {
+	mu     sync.Mutex
+	dir    string
+	files  map[string]string // param file => shard name
+	shards map[string]*zip.Reader
+	closes []func() error
+}
+
+// openShardIndex parse index, returns SPEC, encoded preprocessing pipeline and reader of shards
+func openShardIndex(dir string, data []byte) (*pb.Net, []byte, *shardReader, error) {
+	var idx ShardIndex
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid index: %v", err)
+	}
+	if idx.Metadata.Format != shardIndexFormat {
+		return nil, nil, nil, fmt.Errorf("unsupported index format: %q", idx.Metadata.Format)
+	}
+	var spec pb.Net
+	if err := protojson.Unmarshal(idx.Spec, &spec); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid spec: %v", err)
+	}
+	r := &shardReader{
+		dir:    filepath.Dir(dir),
+		files:  make(map[string]string),
+		shards: make(map[string]*zip.Reader),
+	}
+	for _, l := range spec.GetLayers() {
+		for name, param := range l.GetParams() {
+			key := shardKey(l.GetName(), name)
+			shard, ok := idx.WeightMap[key]
+			if !ok {
+				return nil, nil, nil, fmt.Errorf("%s not found in weight_map", key)
+			}
+			if filepath.Base(shard) != shard {
+				return nil, nil, nil, fmt.Errorf("%s: invalid shard name %s", key, shard)
+			}
+			r.files[param.GetFile()] = shard
+		}
+	}
+	return &spec, idx.Preprocess, r, nil
+}
+
+func (r *shardReader) open(name string) (*zip.Reader, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if zr, ok := r.shards[name]; ok {
+		return zr, nil
+	}
+	data, unmap, err := mmap.Open(filepath.Join(r.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	zr, err := newZipReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		unmap()
+		return nil, fmt.Errorf("open %s: %v", name, err)
+	}
+	r.shards[name] = zr
+	r.closes = append(r.closes, unmap)
+	return zr, nil
+}
+
+func (r *shardReader) load(param *pb.Param, device consts.DeviceType) (*tensor.Tensor, error) {
+	shard, ok := r.files[param.GetFile()]
+	if !ok {
+		return nil, fmt.Errorf("shard of %s not found", param.GetFile())
+	}
+	zr, err := r.open(shard)
+	if err != nil {
+		return nil, err
+	}
+	return loadParam(zr,
+		param.GetFile(),
+		consts.ScalarType(param.GetType()),
+		param.GetElemCount(),
+		param.GetShapes(),
+		device)
+}
+
+// Close unmap all opened shards
+func (r *shardReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var errs []error
+	for _, fn := range r.closes {
+		errs = append(errs, fn())
+	}
+	r.closes = nil
+	r.shards = make(map[string]*zip.Reader)
+	return errors.Join(errs...)
+}
@@ -0,0 +1,54 @@
+package net
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/lwch/tnn/nn/layer"
+)
+
+func TestSaveSharded(t *testing.T) {
+	dir := t.TempDir()
+	index := filepath.Join(dir, "model.tnn.index.json")
+	var net Net
+	net.Add(layer.NewLinear("hidden", 2, 3))
+	net.Add(layer.NewLinear("output", 3, 1))
+	if err := net.SaveSharded(index, 0); err == nil {
+		t.Fatal("expect error of max size")
+	}
+	if err := net.SaveSharded(index, 16); err != nil {
+		t.Fatal(err)
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "model-*-of-00002-g1.tnn"))
+	if len(matches) != 2 {
+		t.Fatalf("invalid shards: %v", matches)
+	}
+	var loaded Net
+	if err := loaded.Load(index); err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Layers()) != 2 || loaded.ParamCount() != net.ParamCount() {
+		t.Fatal("invalid loaded net")
+	}
+	var stage Net
+	if err := stage.Load(index, WithLayers("output")); err != nil {
+		t.Fatal(err)
+	}
+	if len(stage.Layers()) != 1 || stage.Layers()[0].Name() != "output" {
+		t.Fatal("invalid subset of layers")
+	}
+	if err := stage.Load(index, WithLayers("unknown")); err == nil {
+		t.Fatal("expect error of unknown layer")
+	}
+	// shards of the previous save are not overwritten and removed after the index
+	if err := net.SaveSharded(index, 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	matches, _ = filepath.Glob(filepath.Join(dir, "model-*.tnn"))
+	if len(matches) != 1 || filepath.Base(matches[0]) != "model-00001-of-00001-g2.tnn" {
+		t.Fatalf("stale shards not removed: %v", matches)
+	}
+	if err := loaded.Load(index); err != nil {
+		t.Fatal(err)
+	}
+}
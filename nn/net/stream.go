@@ -0,0 +1,166 @@
+package net
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"unsafe"
+
+	"github.com/lwch/gotorch/consts"
+	"github.com/lwch/gotorch/tensor"
+)
+
+// chunkSize max bytes copied out of a tensor at once when writing params
+const chunkSize = 4 << 20
+
+var nativeOrder binary.ByteOrder = func() binary.ByteOrder {
+	n := uint16(1)
+	if *(*byte)(unsafe.Pointer(&n)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+// writeTensor write data of param in chunks, so only one chunk
+// is copied into go memory at the same time
+func writeTensor(w io.Writer, order binary.ByteOrder, param *tensor.Tensor) error {
+	size := elemSize(param.ScalarType())
+	if size == 0 {
+		return fmt.Errorf("unsupported scalar type: %s", param.ScalarType().String())
+	}
+	param = param.ToDevice(consts.KCPU)
+	total := param.ElemCount()
+	step := int64(chunkSize) / size
+	if total <= step {
+		return writeChunk(w, order, param)
+	}
+	flat := param.Reshape(-1)
+	for offset := int64(0); offset < total; offset += step {
+		n := step
+		if total-offset < n {
+			n = total - offset
+		}
+		if err := writeChunk(w, order, flat.NArrow(0, offset, n)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeChunk(w io.Writer, order binary.ByteOrder, param *tensor.Tensor) error {
+	switch param.ScalarType() {
+	case consts.KUint8:
+		return binary.Write(w, order, param.Uint8Value())
+	case consts.KInt8:
+		return binary.Write(w, order, param.Int8Value())
+	case consts.KInt16:
+		return binary.Write(w, order, param.Int16Value())
+	case consts.KInt32:
+		return binary.Write(w, order, param.Int32Value())
+	case consts.KInt64:
+		return binary.Write(w, order, param.Int64Value())
+	case consts.KHalf:
+		return binary.Write(w, order, param.HalfRaw())
+	case consts.KFloat:
+		return binary.Write(w, order, param.Float32Value())
+	case consts.KDouble:
+		return binary.Write(w, order, param.Float64Value())
+	case consts.KBool:
+		return binary.Write(w, order, param.BoolValue())
+	case consts.KBFloat16:
+		return binary.Write(w, order, param.BFloat16Raw())
+	default:
+		return fmt.Errorf("unsupported scalar type: %s", param.ScalarType().String())
+	}
+}
+
+type elemType interface {
+	uint8 | int8 | int16 | uint16 | int32 | int64 | float32 | float64 | bool
+}
+
+// asBytes get memory of data as bytes
+func asBytes[T elemType](data []T) []byte {
+	if len(data) == 0 {
+		return nil
+	}
+	var zero T
+	return unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(data))),
+		len(data)*int(unsafe.Sizeof(zero)))
+}
+
+// fixOrder convert data from order to native order in place
+func fixOrder[T elemType](data []T, order binary.ByteOrder) {
+	var zero T
+	size := int(unsafe.Sizeof(zero))
+	buf := asBytes(data)
+	if size == 1 {
+		if _, ok := any(zero).(bool); ok {
+			// normalize invalid bool values
+			for i, b := range buf {
+				if b > 1 {
+					buf[i] = 1
+				}
+			}
+		}
+		return
+	}
+	if order == nativeOrder {
+		return
+	}
+	for i := 0; i < len(buf); i += size {
+		for l, r := i, i+size-1; l < r; l, r = l+1, r-1 {
+			buf[l], buf[r] = buf[r], buf[l]
+		}
+	}
+}
+
+// buildParam read data in chunks of chunkSize bytes into one buffer and
+// create the tensor from it once, so no tensor is created for each chunk,
+// libgotorch can not copy data into a slice of an existing tensor
+func buildParam[T elemType](r io.Reader, order binary.ByteOrder, cnt int64, shapes []int64, device consts.DeviceType,
+	fn func(data []T, opts ...tensor.Option) *tensor.Tensor) (*tensor.Tensor, error) {
+	var zero T
+	step := int64(chunkSize) / int64(unsafe.Sizeof(zero))
+	data := make([]T, cnt)
+	for offset := int64(0); offset < cnt; offset += step {
+		end := offset + step
+		if end > cnt {
+			end = cnt
+		}
+		if _, err := io.ReadFull(r, asBytes(data[offset:end])); err != nil {
+			return nil, err
+		}
+	}
+	fixOrder(data, order)
+	return fn(data,
+		tensor.WithShapes(shapes...),
+		tensor.WithDevice(device)), nil
+}
+
+// readTensor read tensor of type t from r
+func readTensor(r io.Reader, order binary.ByteOrder, t consts.ScalarType, cnt int64, shapes []int64, device consts.DeviceType) (*tensor.Tensor, error) {
+	switch t {
+	case consts.KUint8:
+		return buildParam[uint8](r, order, cnt, shapes, device, tensor.FromUint8)
+	case consts.KInt8:
+		return buildParam[int8](r, order, cnt, shapes, device, tensor.FromInt8)
+	case consts.KInt16:
+		return buildParam[int16](r, order, cnt, shapes, device, tensor.FromInt16)
+	case consts.KInt32:
+		return buildParam[int32](r, order, cnt, shapes, device, tensor.FromInt32)
+	case consts.KInt64:
+		return buildParam[int64](r, order, cnt, shapes, device, tensor.FromInt64)
+	case consts.KHalf:
+		return buildParam[uint16](r, order, cnt, shapes, device, tensor.FromHalfRaw)
+	case consts.KFloat:
+		return buildParam[float32](r, order, cnt, shapes, device, tensor.FromFloat32)
+	case consts.KDouble:
+		return buildParam[float64](r, order, cnt, shapes, device, tensor.FromFloat64)
+	case consts.KBool:
+		return buildParam[bool](r, order, cnt, shapes, device, tensor.FromBool)
+	case consts.KBFloat16:
+		return buildParam[uint16](r, order, cnt, shapes, device, tensor.FromBFloat16Raw)
+	default:
+		return nil, fmt.Errorf("unsupported scalar type: %s", t.String())
+	}
+}
@@ -0,0 +1,162 @@
+package parallel
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/lwch/gotorch/consts"
+	"github.com/lwch/gotorch/optimizer"
+	"github.com/lwch/gotorch/tensor"
+	"github.com/lwch/tnn/internal/nnutil"
+	"github.com/lwch/tnn/nn/grad"
+	"github.com/lwch/tnn/nn/net"
+)
+
+// LossFunc compute loss of samples by replica of net, loss is the mean of samples
+type LossFunc func(n *net.Net, idx []int) (*tensor.Tensor, error)
+
+type replica struct {
+	net     *net.Net
+	tracker *grad.Tracker
+}
+
+// DataParallel replicate net to workers, each step runs shards of the batch on
+// replicas by goroutines, gradients are averaged by the count of samples in the
+// order of ranks, so the results are reproducible
+type DataParallel struct {
+	master   *grad.Tracker
+	replicas []replica
+	optm     optimizer.Optimizer
+}
+
+// New create data parallel training of net with workers replicas, optm updates
+// params of net
+func New(n *net.Net, workers int, optm optimizer.Optimizer) (*DataParallel, error) {
+	if workers <= 0 {
+		return nil, fmt.Errorf("invalid workers: %d", workers)
+	}
+	var buf bytes.Buffer
+	if _, err := n.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	dp := &DataParallel{optm: optm}
+	for i := 0; i < workers; i++ {
+		var r replica
+		r.net = new(net.Net)
+		if _, err := r.net.ReadFrom(bytes.NewReader(buf.Bytes()), int64(buf.Len())); err != nil {
+			return nil, fmt.Errorf("replica %d: %v", i, err)
+		}
+		var err error
+		if r.tracker, err = grad.NewTracker(r.net); err != nil {
+			return nil, fmt.Errorf("replica %d: %v", i, err)
+		}
+		dp.replicas = append(dp.replicas, r)
+	}
+	var err error
+	if dp.master, err = grad.NewTracker(n); err != nil {
+		return nil, err
+	}
+	return dp, nil
+}
+
+// Workers get count of replicas
+func (dp *DataParallel) Workers() int {
+	return len(dp.replicas)
+}
+
+// Replica get net of the rank-th replica
+func (dp *DataParallel) Replica(rank int) *net.Net {
+	return dp.replicas[rank].net
+}
+
+// Params get params of net updated by optimizer
+func (dp *DataParallel) Params() []*tensor.Tensor {
+	return dp.master.Params()
+}
+
+// Shard split idx into continuous shards of workers, returns the rank-th shard
+func Shard(idx []int, rank, workers int) []int {
+	size := len(idx) / workers
+	rest := len(idx) % workers
+	start := rank*size + nnutil.Min(rank, rest)
+	if rank < rest {
+		size++
+	}
+	return idx[start : start+size]
+}
+
+// Step run loss of each shard of idx on replicas in parallel, update params
+// of net by the averaged gradients and broadcast them to replicas,
+// returns the mean loss of samples
+func (dp *DataParallel) Step(idx []int, loss LossFunc) (float64, error) {
+	workers := len(dp.replicas)
+	losses := make([]float64, workers)
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for rank := range dp.replicas {
+		shard := Shard(idx, rank, workers)
+		if len(shard) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(rank int, shard []int) {
+			defer wg.Done()
+			losses[rank], errs[rank] = dp.replicas[rank].run(shard, loss)
+			if errs[rank] != nil {
+				errs[rank] = fmt.Errorf("rank %d: %v", rank, errs[rank])
+			}
+		}(rank, shard)
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return 0, err
+	}
+	var mean float64
+	sum := make([]*tensor.Tensor, len(dp.master.Params()))
+	for rank, r := range dp.replicas {
+		n := len(Shard(idx, rank, workers))
+		if n == 0 {
+			continue
+		}
+		weight := float64(n) / float64(len(idx))
+		mean += losses[rank] * weight
+		for i, g := range r.tracker.Grads() {
+			if g == nil {
+				continue
+			}
+			g = g.Mul(nnutil.Scalar(weight, g))
+			if sum[i] == nil {
+				sum[i] = g
+			} else {
+				sum[i] = sum[i].Add(g)
+			}
+		}
+	}
+	if err := dp.master.SetGrads(sum); err != nil {
+		return 0, err
+	}
+	if err := dp.master.Step(dp.optm); err != nil {
+		return 0, err
+	}
+	params := dp.master.Params()
+	for rank, r := range dp.replicas {
+		if err := r.tracker.Load(params); err != nil {
+			return 0, fmt.Errorf("rank %d: %v", rank, err)
+		}
+	}
+	return mean, nil
+}
+
+func (r replica) run(idx []int, loss LossFunc) (float64, error) {
+	l, err := loss(r.net, idx)
+	if err != nil {
+		return 0, err
+	}
+	l.Backward()
+	if err = r.tracker.Collect(); err != nil {
+		return 0, err
+	}
+	return l.ToDevice(consts.KCPU).ToScalarType(consts.KDouble).Float64Value()[0], nil
+}
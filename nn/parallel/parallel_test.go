@@ -0,0 +1,88 @@
+package parallel
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/lwch/gotorch/loss"
+	"github.com/lwch/gotorch/optimizer"
+	"github.com/lwch/gotorch/tensor"
+	"github.com/lwch/tnn/nn/layer"
+	"github.com/lwch/tnn/nn/net"
+)
+
+func TestShard(t *testing.T) {
+	idx := []int{0, 1, 2, 3, 4}
+	var got []int
+	for rank := 0; rank < 3; rank++ {
+		shard := Shard(idx, rank, 3)
+		if len(shard) != []int{2, 2, 1}[rank] {
+			t.Fatalf("unexpected shard %d: %v", rank, shard)
+		}
+		got = append(got, shard...)
+	}
+	for i := range idx {
+		if got[i] != idx[i] {
+			t.Fatalf("unexpected shards: %v", got)
+		}
+	}
+}
+
+func xorLoss(n *net.Net, idx []int) (*tensor.Tensor, error) {
+	x := make([]float32, 0, len(idx)*2)
+	y := make([]float32, 0, len(idx))
+	for _, i := range idx {
+		x = append(x, float32(i&1), float32(i>>1&1))
+		y = append(y, float32(i&1^i>>1&1))
+	}
+	outputs, err := n.Forward(layer.NewContext(true),
+		tensor.FromFloat32(x, tensor.WithShapes(int64(len(idx)), 2)))
+	if err != nil {
+		return nil, err
+	}
+	return loss.NewMse(outputs[0], tensor.FromFloat32(y, tensor.WithShapes(int64(len(idx)), 1))), nil
+}
+
+func train(t *testing.T, data []byte, workers int) []float32 {
+	var n net.Net
+	if _, err := n.ReadFrom(bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	dp, err := New(&n, workers, optimizer.NewAdam())
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := []int{0, 1, 2, 3, 4, 5, 6}
+	for i := 0; i < 3; i++ {
+		if _, err = dp.Step(idx, xorLoss); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var ret []float32
+	for _, p := range dp.Params() {
+		ret = append(ret, p.Float32Value()...)
+	}
+	return ret
+}
+
+func TestStep(t *testing.T) {
+	var n net.Net
+	n.Add(layer.NewLinear("hidden", 2, 4))
+	n.Add(layer.NewLinear("output", 4, 1))
+	var buf bytes.Buffer
+	if _, err := n.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	single := train(t, buf.Bytes(), 1)
+	a := train(t, buf.Bytes(), 3)
+	b := train(t, buf.Bytes(), 3)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("not reproducible at %d", i)
+		}
+		if math.Abs(float64(a[i]-single[i])) > 1e-5 {
+			t.Fatalf("mismatch with single worker at %d: %f, %f", i, a[i], single[i])
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package preprocessing
+
+import (
+	"fmt"
+
+	"github.com/lwch/tnn/internal/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// Pipeline steps applied to features in order, fit it with training data and
+// save it with the model by net.SetPreprocessing, so inference applies the
+// same transform
+//
+//	p := preprocessing.New(
+//	    preprocessing.Log(0),
+//	    preprocessing.StandardScaler(0, 1),
+//	    preprocessing.OneHot(2))
+//	err := p.Fit(rows)
+//	features, err := p.Transform(row)
+type Pipeline struct {
+	steps  []*Step
+	inputs int
+}
+
+// New create pipeline of steps
+func New(steps ...*Step) *Pipeline {
+	return &Pipeline{steps: steps}
+}
+
+// Steps get steps of pipeline
+func (p *Pipeline) Steps() []*Step {
+	return p.steps
+}
+
+// Fit fit steps in order, each step is fitted with rows transformed by the previous steps
+func (p *Pipeline) Fit(rows [][]float32) error {
+	if len(rows) == 0 {
+		return fmt.Errorf("no rows to fit")
+	}
+	p.inputs = 0
+	inputs := len(rows[0])
+	for i, row := range rows {
+		if len(row) != inputs {
+			return fmt.Errorf("row %d: expect %d columns, got %d", i, inputs, len(row))
+		}
+	}
+	for i, s := range p.steps {
+		if err := s.fit(rows); err != nil {
+			return err
+		}
+		if i == len(p.steps)-1 {
+			break
+		}
+		next := make([][]float32, len(rows))
+		for j, row := range rows {
+			var err error
+			if next[j], err = s.transform(nil, row); err != nil {
+				return err
+			}
+		}
+		rows = next
+	}
+	p.inputs = inputs
+	return nil
+}
+
+// InputSize get count of input columns, 0 before fit
+func (p *Pipeline) InputSize() int {
+	return p.inputs
+}
+
+// OutputSize get count of transformed columns
+func (p *Pipeline) OutputSize() (int, error) {
+	if p.inputs == 0 {
+		return 0, fmt.Errorf("pipeline is not fitted")
+	}
+	size := p.inputs
+	for _, s := range p.steps {
+		var err error
+		if size, err = s.width(size); err != nil {
+			return 0, err
+		}
+	}
+	return size, nil
+}
+
+// Transform transform one row
+func (p *Pipeline) Transform(row []float32) ([]float32, error) {
+	if p.inputs == 0 {
+		return nil, fmt.Errorf("pipeline is not fitted")
+	}
+	if len(row) != p.inputs {
+		return nil, fmt.Errorf("expect %d columns, got %d", p.inputs, len(row))
+	}
+	var buf []float32
+	for _, s := range p.steps {
+		var err error
+		if buf, err = s.transform(nil, row); err != nil {
+			return nil, err
+		}
+		row = buf
+	}
+	return append([]float32(nil), row...), nil
+}
+
+// TransformBatch transform rows stored contiguously, e.g. features read by
+// sample.ReaderAt.ReadBatch, returns the transformed rows stored contiguously
+func (p *Pipeline) TransformBatch(data []float32) ([]float32, error) {
+	if p.inputs == 0 {
+		return nil, fmt.Errorf("pipeline is not fitted")
+	}
+	if len(data)%p.inputs != 0 {
+		return nil, fmt.Errorf("size of data %d is not multiple of %d", len(data), p.inputs)
+	}
+	size, err := p.OutputSize()
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]float32, 0, len(data)/p.inputs*size)
+	for i := 0; i < len(data); i += p.inputs {
+		row, err := p.Transform(data[i : i+p.inputs])
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %v", i/p.inputs, err)
+		}
+		ret = append(ret, row...)
+	}
+	return ret, nil
+}
+
+// Marshal encode fitted pipeline
+func (p *Pipeline) Marshal() ([]byte, error) {
+	if p.inputs == 0 {
+		return nil, fmt.Errorf("pipeline is not fitted")
+	}
+	var spec pb.Preprocess
+	spec.Inputs = uint32(p.inputs)
+	for _, s := range p.steps {
+		step := &pb.Step{Kind: s.kind, Args: s.args}
+		for _, c := range s.columns {
+			step.Columns = append(step.Columns, int64(c))
+		}
+		for _, stats := range s.stats {
+			step.Stats = append(step.Stats, &pb.Values{Data: stats})
+		}
+		spec.Steps = append(spec.Steps, step)
+	}
+	return proto.Marshal(&spec)
+}
+
+// Unmarshal decode pipeline encoded by Marshal
+func Unmarshal(data []byte) (*Pipeline, error) {
+	var spec pb.Preprocess
+	if err := proto.Unmarshal(data, &spec); err != nil {
+		return nil, err
+	}
+	p := &Pipeline{inputs: int(spec.GetInputs())}
+	for i, step := range spec.GetSteps() {
+		if _, ok := kinds[step.GetKind()]; !ok {
+			return nil, fmt.Errorf("step %d: unsupported kind: %s", i, step.GetKind())
+		}
+		s := &Step{kind: step.GetKind(), args: step.GetArgs()}
+		for _, c := range step.GetColumns() {
+			s.columns = append(s.columns, int(c))
+		}
+		s.stats = make([][]float64, 0, len(step.GetStats()))
+		for _, v := range step.GetStats() {
+			s.stats = append(s.stats, append([]float64{}, v.GetData()...))
+		}
+		p.steps = append(p.steps, s)
+	}
+	if p.inputs == 0 {
+		return nil, fmt.Errorf("pipeline is not fitted")
+	}
+	size := p.inputs
+	for i, s := range p.steps {
+		if err := s.check(size); err != nil {
+			return nil, fmt.Errorf("step %d: %v", i, err)
+		}
+		size, _ = s.width(size)
+	}
+	return p, nil
+}
@@ -0,0 +1,195 @@
+package preprocessing
+
+import (
+	"math"
+	"testing"
+
+	"github.com/lwch/tnn/internal/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+func equal(a, b []float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(float64(a[i]-b[i])) > 1e-5 {
+			return false
+		}
+	}
+	return true
+}
+
+var rows = [][]float32{
+	{1, 10, 2, -1},
+	{2, 20, 0, 0},
+	{3, 30, 1, 5},
+	{4, 40, 2, 10},
+}
+
+func TestSteps(t *testing.T) {
+	tests := []struct {
+		step   *Step
+		row    []float32
+		expect []float32
+	}{
+		{StandardScaler(0), []float32{2.5, 1, 1, 1}, []float32{0, 1, 1, 1}},
+		{MinMax(1), []float32{0, 25, 0, 0}, []float32{0, 0.5, 0, 0}},
+		{OneHot(2), []float32{0, 0, 1, 0}, []float32{0, 0, 0, 1, 0, 0}},
+		{OneHot(2), []float32{0, 0, 7, 0}, []float32{0, 0, 0, 0, 0, 0}},
+		{Bucketize([]float64{5, 0}, 3), []float32{0, 0, 0, 3}, []float32{0, 0, 0, 1}},
+		{Bucketize([]float64{0, 5}, 3), []float32{0, 0, 0, 5}, []float32{0, 0, 0, 2}},
+		{Log(3), []float32{0, 0, 0, -1}, []float32{0, 0, 0, 0}},
+		{Log(), []float32{0, 0, 0, math.E - 1}, []float32{0, 0, 0, 1}},
+	}
+	for _, tt := range tests {
+		p := New(tt.step)
+		if err := p.Fit(rows); err != nil {
+			t.Fatal(err)
+		}
+		got, err := p.Transform(tt.row)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equal(got, tt.expect) {
+			t.Fatalf("%s: expect %v, got %v", tt.step.Kind(), tt.expect, got)
+		}
+	}
+}
+
+func TestStandardScaler(t *testing.T) {
+	p := New(StandardScaler())
+	if err := p.Fit(rows); err != nil {
+		t.Fatal(err)
+	}
+	stats := p.Steps()[0].Stats()
+	if len(stats) != 4 || stats[0][0] != 2.5 || math.Abs(stats[0][1]-math.Sqrt(1.25)) > 1e-9 {
+		t.Fatalf("unexpected stats: %v", stats)
+	}
+	if stats[2][1] == 0 {
+		t.Fatal("std should not be zero")
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	p := New(OneHot(2), MinMax(0, 5), Log(1))
+	if err := p.Fit(rows); err != nil {
+		t.Fatal(err)
+	}
+	size, err := p.OutputSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 6 {
+		t.Fatalf("expect 6 columns, got %d", size)
+	}
+	var data []float32
+	for _, row := range rows {
+		data = append(data, row...)
+	}
+	batch, err := p.TransformBatch(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch) != len(rows)*size {
+		t.Fatalf("expect %d values, got %d", len(rows)*size, len(batch))
+	}
+	// columns of second step are indices of the output of one-hot
+	expect := []float32{1, float32(math.Log1p(40)), 0, 0, 1, 1}
+	if !equal(batch[3*size:], expect) {
+		t.Fatalf("expect %v, got %v", expect, batch[3*size:])
+	}
+
+	enc, err := p.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Unmarshal(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		a, _ := p.Transform(row)
+		b, err := loaded.Transform(row)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equal(a, b) {
+			t.Fatalf("expect %v, got %v", a, b)
+		}
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	p := New(StandardScaler(4))
+	if _, err := p.Transform([]float32{1}); err == nil {
+		t.Fatal("expect error before fit")
+	}
+	if err := p.Fit(rows); err == nil {
+		t.Fatal("expect column out of range")
+	}
+	if err := New(MinMax(0, 0)).Fit(rows); err == nil {
+		t.Fatal("expect error of duplicate column")
+	}
+	p = New(MinMax())
+	if err := p.Fit(rows); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Transform([]float32{1, 2}); err == nil {
+		t.Fatal("expect column count mismatch")
+	}
+	if _, err := p.TransformBatch(make([]float32, 5)); err == nil {
+		t.Fatal("expect size mismatch")
+	}
+	if _, err := Unmarshal([]byte{0xff}); err == nil {
+		t.Fatal("expect decode error")
+	}
+}
+
+func TestUnmarshalInvalid(t *testing.T) {
+	values := func(data ...float64) *pb.Values {
+		return &pb.Values{Data: data}
+	}
+	tests := []struct {
+		name string
+		step *pb.Step
+	}{
+		{"onehot without columns", &pb.Step{Kind: "onehot", Stats: []*pb.Values{values(0, 1)}}},
+		{"onehot not sorted", &pb.Step{Kind: "onehot", Columns: []int64{0}, Stats: []*pb.Values{values(1, 0)}}},
+		{"standard without columns", &pb.Step{Kind: "standard", Stats: []*pb.Values{values(0, 1)}}},
+		{"standard values", &pb.Step{Kind: "standard", Columns: []int64{0}, Stats: []*pb.Values{values(0)}}},
+		{"minmax values", &pb.Step{Kind: "minmax", Columns: []int64{1}, Stats: []*pb.Values{values(0, 1, 2)}}},
+		{"log values", &pb.Step{Kind: "log", Columns: []int64{0}, Stats: []*pb.Values{values(1)}}},
+		{"bucketize values", &pb.Step{Kind: "bucketize", Args: []float64{1}, Columns: []int64{0}, Stats: []*pb.Values{values(1)}}},
+		{"bucketize boundaries", &pb.Step{Kind: "bucketize", Args: []float64{2, 1}, Columns: []int64{0}, Stats: []*pb.Values{values()}}},
+		{"column out of range", &pb.Step{Kind: "log", Columns: []int64{2}, Stats: []*pb.Values{values()}}},
+		{"duplicate column", &pb.Step{Kind: "log", Columns: []int64{1, 1}, Stats: []*pb.Values{values(), values()}}},
+		{"standard zero std", &pb.Step{Kind: "standard", Columns: []int64{0}, Stats: []*pb.Values{values(0, 0)}}},
+		{"standard negative std", &pb.Step{Kind: "standard", Columns: []int64{0}, Stats: []*pb.Values{values(0, -1)}}},
+		{"standard nan std", &pb.Step{Kind: "standard", Columns: []int64{0}, Stats: []*pb.Values{values(0, math.NaN())}}},
+		{"standard inf std", &pb.Step{Kind: "standard", Columns: []int64{0}, Stats: []*pb.Values{values(0, math.Inf(1))}}},
+	}
+	for _, tt := range tests {
+		data, err := proto.Marshal(&pb.Preprocess{Inputs: 2, Steps: []*pb.Step{tt.step}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = Unmarshal(data); err == nil {
+			t.Fatalf("%s: expect error", tt.name)
+		}
+	}
+	data, err := proto.Marshal(&pb.Preprocess{Inputs: 2, Steps: []*pb.Step{
+		{Kind: "onehot", Columns: []int64{1}, Stats: []*pb.Values{values(0, 1, 2)}},
+		{Kind: "standard", Stats: []*pb.Values{values(0, 1), values(0, 1), values(0, 1), values(0, 1)}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size, _ := p.OutputSize(); size != 4 {
+		t.Fatalf("expect 4 output columns, got %d", size)
+	}
+}
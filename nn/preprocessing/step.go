@@ -0,0 +1,297 @@
+package preprocessing
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// kind fit and transform of one column
+type kind struct {
+	// fit compute statistics of column, nil means nothing to fit
+	fit func(values []float32, args []float64) []float64
+	// transform append transformed values of v to dst
+	transform func(dst []float32, v float32, stats, args []float64) []float32
+	// width count of transformed values
+	width func(stats, args []float64) int
+	// stats count of statistics of each column, -1 means any count of sorted values
+	stats int
+}
+
+var kinds = map[string]kind{
+	"standard": {
+		fit: func(values []float32, _ []float64) []float64 {
+			var mean, m2 float64
+			for i, v := range values {
+				delta := float64(v) - mean
+				mean += delta / float64(i+1)
+				m2 += delta * (float64(v) - mean)
+			}
+			std := 1.0
+			if len(values) > 0 && m2 > 0 {
+				std = math.Sqrt(m2 / float64(len(values)))
+			}
+			return []float64{mean, std}
+		},
+		transform: func(dst []float32, v float32, stats, _ []float64) []float32 {
+			return append(dst, float32((float64(v)-stats[0])/stats[1]))
+		},
+		stats: 2,
+	},
+	"minmax": {
+		fit: func(values []float32, _ []float64) []float64 {
+			if len(values) == 0 {
+				return []float64{0, 1}
+			}
+			lo, hi := float64(values[0]), float64(values[0])
+			for _, v := range values {
+				lo = math.Min(lo, float64(v))
+				hi = math.Max(hi, float64(v))
+			}
+			return []float64{lo, hi}
+		},
+		transform: func(dst []float32, v float32, stats, _ []float64) []float32 {
+			if stats[1] == stats[0] {
+				return append(dst, 0)
+			}
+			return append(dst, float32((float64(v)-stats[0])/(stats[1]-stats[0])))
+		},
+		stats: 2,
+	},
+	"onehot": {
+		fit: func(values []float32, _ []float64) []float64 {
+			seen := make(map[float32]bool)
+			var ret []float64
+			for _, v := range values {
+				if !seen[v] {
+					seen[v] = true
+					ret = append(ret, float64(v))
+				}
+			}
+			sort.Float64s(ret)
+			return ret
+		},
+		transform: func(dst []float32, v float32, stats, _ []float64) []float32 {
+			i := sort.SearchFloat64s(stats, float64(v))
+			for j := range stats {
+				if j == i && stats[i] == float64(v) {
+					dst = append(dst, 1)
+				} else {
+					dst = append(dst, 0)
+				}
+			}
+			return dst
+		},
+		width: func(stats, _ []float64) int {
+			return len(stats)
+		},
+		stats: -1,
+	},
+	"bucketize": {
+		transform: func(dst []float32, v float32, _, args []float64) []float32 {
+			return append(dst, float32(sort.Search(len(args), func(i int) bool {
+				return float64(v) < args[i]
+			})))
+		},
+	},
+	"log": {
+		transform: func(dst []float32, v float32, _, _ []float64) []float32 {
+			return append(dst, float32(math.Log1p(math.Max(float64(v), 0))))
+		},
+	},
+}
+
+// Step one step of pipeline, it transforms the selected columns of its input
+// and keeps the other columns, columns are indices of the output of the
+// previous step
+type Step struct {
+	kind    string
+	columns []int
+	args    []float64
+	stats   [][]float64 // statistics of each column, nil before fit
+}
+
+func newStep(kind string, args []float64, columns []int) *Step {
+	return &Step{kind: kind, columns: columns, args: args}
+}
+
+// StandardScaler transform columns to zero mean and unit variance, all columns
+// are transformed when columns is empty
+func StandardScaler(columns ...int) *Step {
+	return newStep("standard", nil, columns)
+}
+
+// MinMax scale columns into [0, 1] by the min and max values
+func MinMax(columns ...int) *Step {
+	return newStep("minmax", nil, columns)
+}
+
+// OneHot expand each column to indicators of its distinct values, values not
+// seen by fit are transformed to all zeros
+func OneHot(columns ...int) *Step {
+	return newStep("onehot", nil, columns)
+}
+
+// Bucketize transform columns to index of bucket, boundaries are sorted
+// ascending, values less than boundaries[0] are in bucket 0
+func Bucketize(boundaries []float64, columns ...int) *Step {
+	args := append([]float64{}, boundaries...)
+	sort.Float64s(args)
+	return newStep("bucketize", args, columns)
+}
+
+// Log transform columns by log(1+v), negative values are treated as zero
+func Log(columns ...int) *Step {
+	return newStep("log", nil, columns)
+}
+
+// Kind get kind of step
+func (s *Step) Kind() string {
+	return s.kind
+}
+
+// Columns get selected columns
+func (s *Step) Columns() []int {
+	return s.columns
+}
+
+// Stats get fitted statistics of each selected column, e.g. mean and std of
+// standard scaler, nil before fit
+func (s *Step) Stats() [][]float64 {
+	return s.stats
+}
+
+// selected get selected columns of input with size columns, -1 means the
+// column is not selected, otherwise index in stats
+func (s *Step) selected(size int) ([]int, error) {
+	ret := make([]int, size)
+	if len(s.columns) == 0 {
+		for i := range ret {
+			ret[i] = i
+		}
+		return ret, nil
+	}
+	for i := range ret {
+		ret[i] = -1
+	}
+	for i, c := range s.columns {
+		if c < 0 || c >= size {
+			return nil, fmt.Errorf("%s: column %d out of range, %d columns", s.kind, c, size)
+		}
+		if ret[c] >= 0 {
+			return nil, fmt.Errorf("%s: duplicate column %d", s.kind, c)
+		}
+		ret[c] = i
+	}
+	return ret, nil
+}
+
+func (s *Step) fit(rows [][]float32) error {
+	if len(rows) == 0 {
+		return fmt.Errorf("%s: no rows to fit", s.kind)
+	}
+	sel, err := s.selected(len(rows[0]))
+	if err != nil {
+		return err
+	}
+	k := kinds[s.kind]
+	stats := make([][]float64, len(s.columns))
+	if len(s.columns) == 0 {
+		stats = make([][]float64, len(sel))
+	}
+	values := make([]float32, len(rows))
+	for c, i := range sel {
+		if i < 0 {
+			continue
+		}
+		if k.fit == nil {
+			stats[i] = []float64{}
+			continue
+		}
+		for j, row := range rows {
+			if len(row) != len(sel) {
+				return fmt.Errorf("%s: row %d: expect %d columns, got %d", s.kind, j, len(sel), len(row))
+			}
+			values[j] = row[c]
+		}
+		stats[i] = k.fit(values, s.args)
+	}
+	s.stats = stats
+	return nil
+}
+
+// check validate statistics of step for input with size columns, e.g.
+// statistics decoded by Unmarshal
+func (s *Step) check(size int) error {
+	if _, err := s.selected(size); err != nil {
+		return err
+	}
+	columns := len(s.columns)
+	if columns == 0 {
+		columns = size
+	}
+	if len(s.stats) != columns {
+		return fmt.Errorf("%s: expect statistics of %d columns, got %d", s.kind, columns, len(s.stats))
+	}
+	k := kinds[s.kind]
+	for i, stats := range s.stats {
+		column := i
+		if len(s.columns) > 0 {
+			column = s.columns[i]
+		}
+		switch {
+		case k.stats >= 0 && len(stats) != k.stats:
+			return fmt.Errorf("%s: column %d: expect %d statistics, got %d", s.kind, column, k.stats, len(stats))
+		case k.stats < 0 && !sort.Float64sAreSorted(stats):
+			return fmt.Errorf("%s: column %d: statistics are not sorted", s.kind, column)
+		case s.kind == "standard" && !(stats[1] > 0 && !math.IsInf(stats[1], 0)):
+			return fmt.Errorf("%s: column %d: invalid std %g", s.kind, column, stats[1])
+		}
+	}
+	if s.kind == "bucketize" && !sort.Float64sAreSorted(s.args) {
+		return fmt.Errorf("%s: boundaries are not sorted", s.kind)
+	}
+	return nil
+}
+
+// transform append transformed row to dst
+func (s *Step) transform(dst, row []float32) ([]float32, error) {
+	if s.stats == nil {
+		return nil, fmt.Errorf("%s: not fitted", s.kind)
+	}
+	sel, err := s.selected(len(row))
+	if err != nil {
+		return nil, err
+	}
+	if len(s.columns) == 0 && len(row) != len(s.stats) {
+		return nil, fmt.Errorf("%s: expect %d columns, got %d", s.kind, len(s.stats), len(row))
+	}
+	k := kinds[s.kind]
+	for c, v := range row {
+		if i := sel[c]; i >= 0 {
+			dst = k.transform(dst, v, s.stats[i], s.args)
+		} else {
+			dst = append(dst, v)
+		}
+	}
+	return dst, nil
+}
+
+// width get count of output columns of input with size columns
+func (s *Step) width(size int) (int, error) {
+	sel, err := s.selected(size)
+	if err != nil {
+		return 0, err
+	}
+	k := kinds[s.kind]
+	ret := 0
+	for _, i := range sel {
+		switch {
+		case i < 0 || k.width == nil:
+			ret++
+		default:
+			ret += k.width(s.stats[i], s.args)
+		}
+	}
+	return ret, nil
+}
@@ -2,6 +2,7 @@ package sample
 
 import (
 	"encoding/binary"
+	"fmt"
 	"io"
 	"reflect"
 	"sync"
@@ -55,3 +56,19 @@ func (r *Reader) ReadSample(idx uint32, features, labels []float32) error {
 	}
 	return binary.Read(r.r, binary.BigEndian, labels[:r.hdr.LabelSize])
 }
+
+// ReadBatch read samples of indices into contiguous buffers, features must
+// have len(idx)*FeatureSize values and labels must have len(idx)*LabelSize values
+func (r *Reader) ReadBatch(idx []uint32, features, labels []float32) error {
+	fs, ls := int(r.hdr.FeatureSize), int(r.hdr.LabelSize)
+	if len(features) < len(idx)*fs || len(labels) < len(idx)*ls {
+		return fmt.Errorf("buffer is too small for %d samples", len(idx))
+	}
+	for i, n := range idx {
+		err := r.ReadSample(n, features[i*fs:(i+1)*fs], labels[i*ls:(i+1)*ls])
+		if err != nil {
+			return fmt.Errorf("sample %d: %v", n, err)
+		}
+	}
+	return nil
+}
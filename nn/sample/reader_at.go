@@ -0,0 +1,176 @@
+package sample
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"sync"
+
+	"github.com/lwch/tnn/internal/mmap"
+)
+
+var headerSize = int64(reflect.TypeOf(sampleHeader{}).Size())
+
+// ReaderAt lock-free reader of version 1 sample file, samples are read by
+// ReadAt or from mapped memory, so any number of goroutines can read
+// samples concurrently
+type ReaderAt struct {
+	hdr  sampleHeader
+	r    io.ReaderAt
+	data []byte // whole file when mapped into memory
+	pool sync.Pool
+}
+
+// NewReaderAt create sample reader by io.ReaderAt, e.g. *os.File
+func NewReaderAt(r io.ReaderAt) (*ReaderAt, error) {
+	buf := make([]byte, headerSize)
+	if _, err := r.ReadAt(buf, 0); err != nil {
+		return nil, err
+	}
+	ret := &ReaderAt{r: r}
+	if err := binary.Read(bytes.NewReader(buf), binary.BigEndian, &ret.hdr); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// BatchSize get batch size
+func (r *ReaderAt) BatchSize() uint32 {
+	return r.hdr.BatchSize
+}
+
+// FeatureSize get feature size
+func (r *ReaderAt) FeatureSize() uint32 {
+	return r.hdr.FeatureSize
+}
+
+// LabelSize get label size
+func (r *ReaderAt) LabelSize() uint32 {
+	return r.hdr.LabelSize
+}
+
+func (r *ReaderAt) sampleSize() int64 {
+	return (int64(r.hdr.FeatureSize) + int64(r.hdr.LabelSize)) * 4
+}
+
+// ReadSample read sample data
+func (r *ReaderAt) ReadSample(idx uint32, features, labels []float32) error {
+	if idx >= r.hdr.BatchSize {
+		return fmt.Errorf("index out of range: %d", idx)
+	}
+	if len(features) < int(r.hdr.FeatureSize) || len(labels) < int(r.hdr.LabelSize) {
+		return fmt.Errorf("buffer is too small")
+	}
+	offset := headerSize + int64(idx)*r.sampleSize()
+	var data []byte
+	if r.data != nil {
+		end := offset + r.sampleSize()
+		if end > int64(len(r.data)) {
+			return io.ErrUnexpectedEOF
+		}
+		data = r.data[offset:end]
+	} else {
+		buf, _ := r.pool.Get().(*[]byte)
+		if buf == nil {
+			b := make([]byte, r.sampleSize())
+			buf = &b
+		}
+		defer r.pool.Put(buf)
+		data = *buf
+		if _, err := r.r.ReadAt(data, offset); err != nil {
+			return err
+		}
+	}
+	n := int(r.hdr.FeatureSize) * 4
+	decode(data[:n], features[:r.hdr.FeatureSize])
+	decode(data[n:], labels[:r.hdr.LabelSize])
+	return nil
+}
+
+// ReadBatch read samples of indices into contiguous buffers, features must
+// have len(idx)*FeatureSize values and labels must have len(idx)*LabelSize values
+func (r *ReaderAt) ReadBatch(idx []uint32, features, labels []float32) error {
+	fs, ls := int(r.hdr.FeatureSize), int(r.hdr.LabelSize)
+	if len(features) < len(idx)*fs || len(labels) < len(idx)*ls {
+		return fmt.Errorf("buffer is too small for %d samples", len(idx))
+	}
+	for i, n := range idx {
+		err := r.ReadSample(n, features[i*fs:(i+1)*fs], labels[i*ls:(i+1)*ls])
+		if err != nil {
+			return fmt.Errorf("sample %d: %v", n, err)
+		}
+	}
+	return nil
+}
+
+// decode decode big-endian float32 values
+func decode(data []byte, dst []float32) {
+	for i := range dst {
+		dst[i] = math.Float32frombits(binary.BigEndian.Uint32(data[i*4:]))
+	}
+}
+
+// MmapReader version 1 sample file mapped into memory, on platforms
+// without mmap the whole file is read into memory, Close waits for the
+// running reads
+type MmapReader struct {
+	*ReaderAt
+	m      sync.RWMutex
+	closed bool
+	unmap  func() error
+}
+
+// OpenMmap map sample file into memory
+func OpenMmap(dir string) (*MmapReader, error) {
+	data, unmap, err := mmap.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewReaderAt(bytes.NewReader(data))
+	if err != nil {
+		unmap()
+		return nil, err
+	}
+	if int64(len(data)) < headerSize+int64(r.hdr.BatchSize)*r.sampleSize() {
+		unmap()
+		return nil, fmt.Errorf("file is truncated")
+	}
+	r.data = data
+	return &MmapReader{ReaderAt: r, unmap: unmap}, nil
+}
+
+// ReadSample read sample data
+func (r *MmapReader) ReadSample(idx uint32, features, labels []float32) error {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	if r.closed {
+		return os.ErrClosed
+	}
+	return r.ReaderAt.ReadSample(idx, features, labels)
+}
+
+// ReadBatch read samples of indices into contiguous buffers, features must
+// have len(idx)*FeatureSize values and labels must have len(idx)*LabelSize values
+func (r *MmapReader) ReadBatch(idx []uint32, features, labels []float32) error {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	if r.closed {
+		return os.ErrClosed
+	}
+	return r.ReaderAt.ReadBatch(idx, features, labels)
+}
+
+// Close unmap file, samples can not be read after closed
+func (r *MmapReader) Close() error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	return r.unmap()
+}
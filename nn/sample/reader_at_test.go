@@ -0,0 +1,155 @@
+package sample
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func writeV1(t *testing.T, n int) string {
+	dir := filepath.Join(t.TempDir(), "samples")
+	f, err := os.Create(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := NewWriter(f)
+	for i := 0; i < n; i++ {
+		err = w.WriteSample([]float32{float32(i), float32(i + 1)}, []float32{float32(i + 2)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+type batchReader interface {
+	ReadBatch(idx []uint32, features, labels []float32) error
+}
+
+func checkConcurrent(t *testing.T, r batchReader, n int) {
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			idx := make([]uint32, n)
+			for i := range idx {
+				idx[i] = uint32((i*7 + g) % n)
+			}
+			features := make([]float32, n*2)
+			labels := make([]float32, n)
+			if err := r.ReadBatch(idx, features, labels); err != nil {
+				errs <- err
+				return
+			}
+			for i, v := range idx {
+				if features[i*2] != float32(v) || features[i*2+1] != float32(v+1) || labels[i] != float32(v+2) {
+					t.Errorf("sample %d: unexpected values", v)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+func TestReaderAt(t *testing.T) {
+	dir := writeV1(t, 100)
+	f, err := os.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := NewReaderAt(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.BatchSize() != 100 || r.FeatureSize() != 2 || r.LabelSize() != 1 {
+		t.Fatal("invalid header")
+	}
+	checkConcurrent(t, r, 100)
+	if err = r.ReadSample(100, make([]float32, 2), make([]float32, 1)); err == nil {
+		t.Fatal("expect error of index")
+	}
+	if err = r.ReadBatch([]uint32{0, 1}, make([]float32, 3), make([]float32, 2)); err == nil {
+		t.Fatal("expect error of buffer size")
+	}
+}
+
+func TestMmapReader(t *testing.T) {
+	r, err := OpenMmap(writeV1(t, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkConcurrent(t, r, 100)
+	if err = r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = r.ReadSample(0, make([]float32, 2), make([]float32, 1)); err == nil {
+		t.Fatal("expect error of closed reader")
+	}
+}
+
+func TestMmapReaderClose(t *testing.T) {
+	r, err := OpenMmap(writeV1(t, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			features, labels := make([]float32, 2), make([]float32, 1)
+			for j := uint32(0); ; j = (j + 1) % 100 {
+				if err := r.ReadSample(j, features, labels); err != nil {
+					if err != os.ErrClosed {
+						t.Error(err)
+					}
+					return
+				}
+				if features[0] != float32(j) {
+					t.Errorf("sample %d: unexpected features: %v", j, features)
+					return
+				}
+			}
+		}()
+	}
+	if err = r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+}
+
+func TestSampleSize(t *testing.T) {
+	var r ReaderAt
+	r.hdr.FeatureSize = math.MaxUint32
+	r.hdr.LabelSize = 1
+	if size := r.sampleSize(); size != (math.MaxUint32+1)*4 {
+		t.Fatalf("unexpected sample size: %d", size)
+	}
+}
+
+func TestReadBatch(t *testing.T) {
+	f, err := os.Open(writeV1(t, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkConcurrent(t, r, 10)
+}
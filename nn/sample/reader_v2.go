@@ -0,0 +1,135 @@
+package sample
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// ReaderV2 reader of version 2 sample file, records are read by ReadAt, so
+// it can be used by goroutines concurrently
+type ReaderV2 struct {
+	r      io.ReaderAt
+	fields []Field
+	chunks []chunkIndex
+	starts []int // index of the first record of each chunk
+	count  int
+
+	// the last decompressed chunk
+	m     sync.Mutex
+	cache int
+	data  []byte
+}
+
+// NewReaderV2 create version 2 sample reader, size is bytes of the file
+func NewReaderV2(r io.ReaderAt, size int64) (*ReaderV2, error) {
+	fields, err := readHeaderV2(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return nil, err
+	}
+	chunks, offset, err := readIndex(r, size)
+	if err != nil {
+		return nil, err
+	}
+	ret := &ReaderV2{
+		r:      r,
+		fields: fields,
+		chunks: chunks,
+		cache:  -1,
+	}
+	for _, c := range chunks {
+		if c.offset+int64(c.stored) > offset {
+			return nil, fmt.Errorf("invalid chunk at %d", c.offset)
+		}
+		if c.flags&flagZstd == 0 && c.raw != c.stored {
+			return nil, fmt.Errorf("invalid raw size of chunk at %d: %d", c.offset, c.raw)
+		}
+		ret.starts = append(ret.starts, ret.count)
+		ret.count += len(c.records)
+	}
+	return ret, nil
+}
+
+// Fields get fields of records
+func (r *ReaderV2) Fields() []Field {
+	return r.fields
+}
+
+// Field get index of field by name, returns -1 when not found
+func (r *ReaderV2) Field(name string) int {
+	for i, f := range r.fields {
+		if f.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Len get count of records
+func (r *ReaderV2) Len() int {
+	return r.count
+}
+
+// ReadRecord read the idx-th record
+func (r *ReaderV2) ReadRecord(idx int) (Record, error) {
+	if idx < 0 || idx >= r.count {
+		return nil, fmt.Errorf("index out of range: %d", idx)
+	}
+	ci := sort.Search(len(r.starts), func(i int) bool {
+		return r.starts[i] > idx
+	}) - 1
+	c := r.chunks[ci]
+	i := idx - r.starts[ci]
+	start := c.records[i]
+	end := c.raw
+	if i+1 < len(c.records) {
+		end = c.records[i+1]
+	}
+	if start > end || end > c.raw {
+		return nil, fmt.Errorf("invalid offset of record %d", idx)
+	}
+	var data []byte
+	if c.flags&flagZstd == 0 {
+		data = make([]byte, end-start)
+		if _, err := r.r.ReadAt(data, c.offset+int64(start)); err != nil {
+			return nil, err
+		}
+	} else {
+		chunk, err := r.decompress(ci)
+		if err != nil {
+			return nil, err
+		}
+		data = chunk[start:end]
+	}
+	return decodeRecord(data, r.fields)
+}
+
+// decompress read the ci-th chunk, the last chunk is cached because records
+// are usually read in order
+func (r *ReaderV2) decompress(ci int) ([]byte, error) {
+	r.m.Lock()
+	if r.cache == ci {
+		data := r.data
+		r.m.Unlock()
+		return data, nil
+	}
+	r.m.Unlock()
+	c := r.chunks[ci]
+	stored := make([]byte, c.stored)
+	if _, err := r.r.ReadAt(stored, c.offset); err != nil {
+		return nil, err
+	}
+	// raw size is not trusted, the buffer grows with decoded data
+	data, err := zstdDecoder.DecodeAll(stored, nil)
+	if err != nil {
+		return nil, fmt.Errorf("chunk at %d: %v", c.offset, err)
+	}
+	if len(data) != int(c.raw) {
+		return nil, fmt.Errorf("chunk at %d: expect %d bytes, got %d", c.offset, c.raw, len(data))
+	}
+	r.m.Lock()
+	r.cache, r.data = ci, data
+	r.m.Unlock()
+	return data, nil
+}
@@ -0,0 +1,409 @@
+package sample
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Version 2 of sample file, all values are little-endian
+//
+//	header:  "TNNS" | version uint16 | fields uint16 | fields...
+//	field:   name length uint16 | name | dtype uint8 | dims uint8 | dim int64...
+//	chunks:  records of each chunk, optionally zstd compressed
+//	index:   chunks uint32 | chunk...
+//	chunk:   offset uint64 | stored size uint32 | raw size uint32 | flags uint8 |
+//	         records uint32 | record offset in raw chunk uint32...
+//	trailer: index offset uint64 | "TNNX"
+//
+// each record is the values of fields in order, fields which have variable
+// dimension are prefixed by count of values as uint32
+const (
+	magicV2   = "TNNS"
+	magicIdx  = "TNNX"
+	versionV2 = 2
+
+	flagZstd = 1
+
+	trailerSize = 12
+)
+
+// DType data type of field
+type DType uint8
+
+const (
+	Float32 DType = iota + 1
+	Float64
+	Int64
+	Int32
+	Uint8
+)
+
+// Size get bytes of one value
+func (t DType) Size() int {
+	switch t {
+	case Float32, Int32:
+		return 4
+	case Float64, Int64:
+		return 8
+	case Uint8:
+		return 1
+	}
+	return 0
+}
+
+func (t DType) String() string {
+	switch t {
+	case Float32:
+		return "float32"
+	case Float64:
+		return "float64"
+	case Int64:
+		return "int64"
+	case Int32:
+		return "int32"
+	case Uint8:
+		return "uint8"
+	}
+	return fmt.Sprintf("DType(%d)", uint8(t))
+}
+
+// Field named field of samples, one dimension of Shape can be -1 which means
+// the field has variable length, e.g. token ids of sentences
+type Field struct {
+	Name  string
+	Type  DType
+	Shape []int64
+}
+
+// Record values of fields in order, value of each field is []float32,
+// []float64, []int64, []int32 or []uint8 according to its DType
+type Record []interface{}
+
+// size get count of values of fixed dimensions and whether the field has
+// variable dimension
+func (f Field) size() (int64, bool) {
+	n := int64(1)
+	variable := false
+	for _, d := range f.Shape {
+		if d < 0 {
+			variable = true
+			continue
+		}
+		n *= d
+	}
+	return n, variable
+}
+
+func (f Field) check() error {
+	if f.Type.Size() == 0 {
+		return fmt.Errorf("field %s: invalid dtype: %v", f.Name, f.Type)
+	}
+	if len(f.Name) > math.MaxUint16 || len(f.Shape) > math.MaxUint8 {
+		return fmt.Errorf("field %s: name or shape is too long", f.Name)
+	}
+	variable := 0
+	for _, d := range f.Shape {
+		if d < 0 {
+			variable++
+		}
+	}
+	if variable > 1 {
+		return fmt.Errorf("field %s: only one dimension can be variable", f.Name)
+	}
+	// values of fixed dimensions must fit in one chunk
+	n := int64(f.Type.Size())
+	for _, d := range f.Shape {
+		if d > 0 && n > math.MaxUint32/d {
+			return fmt.Errorf("field %s: shape is too large: %v", f.Name, f.Shape)
+		}
+		if d >= 0 {
+			n *= d
+		}
+	}
+	return nil
+}
+
+// Version detect version of sample file, files without magic are version 1
+func Version(r io.ReaderAt) (int, error) {
+	var magic [4]byte
+	if _, err := r.ReadAt(magic[:], 0); err != nil {
+		return 0, err
+	}
+	if string(magic[:]) == magicV2 {
+		return versionV2, nil
+	}
+	return 1, nil
+}
+
+func writeHeaderV2(w io.Writer, fields []Field) (int64, error) {
+	var buf bytes.Buffer
+	buf.WriteString(magicV2)
+	binary.Write(&buf, binary.LittleEndian, uint16(versionV2))
+	binary.Write(&buf, binary.LittleEndian, uint16(len(fields)))
+	for _, f := range fields {
+		if err := f.check(); err != nil {
+			return 0, err
+		}
+		binary.Write(&buf, binary.LittleEndian, uint16(len(f.Name)))
+		buf.WriteString(f.Name)
+		buf.WriteByte(byte(f.Type))
+		buf.WriteByte(byte(len(f.Shape)))
+		binary.Write(&buf, binary.LittleEndian, f.Shape)
+	}
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+func readHeaderV2(r io.Reader) ([]Field, error) {
+	var hdr struct {
+		Magic   [4]byte
+		Version uint16
+		Fields  uint16
+	}
+	if err := binary.Read(r, binary.LittleEndian, &hdr); err != nil {
+		return nil, err
+	}
+	if string(hdr.Magic[:]) != magicV2 {
+		return nil, fmt.Errorf("invalid magic: %q", hdr.Magic[:])
+	}
+	if hdr.Version != versionV2 {
+		return nil, fmt.Errorf("unsupported version: %d", hdr.Version)
+	}
+	fields := make([]Field, hdr.Fields)
+	for i := range fields {
+		var size uint16
+		if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+			return nil, err
+		}
+		name := make([]byte, size)
+		if _, err := io.ReadFull(r, name); err != nil {
+			return nil, err
+		}
+		var meta [2]byte
+		if _, err := io.ReadFull(r, meta[:]); err != nil {
+			return nil, err
+		}
+		fields[i] = Field{Name: string(name), Type: DType(meta[0]), Shape: make([]int64, meta[1])}
+		if err := binary.Read(r, binary.LittleEndian, fields[i].Shape); err != nil {
+			return nil, err
+		}
+		if err := fields[i].check(); err != nil {
+			return nil, err
+		}
+	}
+	return fields, nil
+}
+
+type chunkIndex struct {
+	offset  int64
+	stored  uint32
+	raw     uint32
+	flags   uint8
+	records []uint32 // offsets of records in raw chunk
+}
+
+func writeIndex(w io.Writer, offset int64, chunks []chunkIndex) (int64, error) {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint32(len(chunks)))
+	for _, c := range chunks {
+		binary.Write(&buf, binary.LittleEndian, uint64(c.offset))
+		binary.Write(&buf, binary.LittleEndian, c.stored)
+		binary.Write(&buf, binary.LittleEndian, c.raw)
+		buf.WriteByte(c.flags)
+		binary.Write(&buf, binary.LittleEndian, uint32(len(c.records)))
+		binary.Write(&buf, binary.LittleEndian, c.records)
+	}
+	binary.Write(&buf, binary.LittleEndian, uint64(offset))
+	buf.WriteString(magicIdx)
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+// readIndex read index from the end of file, returns offset of index
+func readIndex(r io.ReaderAt, size int64) ([]chunkIndex, int64, error) {
+	if size < trailerSize {
+		return nil, 0, fmt.Errorf("missing index, the file may not be closed")
+	}
+	var trailer [trailerSize]byte
+	if _, err := r.ReadAt(trailer[:], size-trailerSize); err != nil {
+		return nil, 0, err
+	}
+	if string(trailer[8:]) != magicIdx {
+		return nil, 0, fmt.Errorf("missing index, the file may not be closed")
+	}
+	offset := int64(binary.LittleEndian.Uint64(trailer[:]))
+	if offset < 0 || offset > size-trailerSize {
+		return nil, 0, fmt.Errorf("invalid index offset: %d", offset)
+	}
+	ir := io.NewSectionReader(r, offset, size-trailerSize-offset)
+	var count uint32
+	if err := binary.Read(ir, binary.LittleEndian, &count); err != nil {
+		return nil, 0, err
+	}
+	// chunk header is offset, stored size, raw size, flags and records
+	const chunkHeaderSize = 8 + 4 + 4 + 1 + 4
+	if int64(count) > remain(ir)/chunkHeaderSize {
+		return nil, 0, fmt.Errorf("invalid count of chunks: %d", count)
+	}
+	chunks := make([]chunkIndex, count)
+	for i := range chunks {
+		var hdr struct {
+			Offset  uint64
+			Stored  uint32
+			Raw     uint32
+			Flags   uint8
+			Records uint32
+		}
+		if err := binary.Read(ir, binary.LittleEndian, &hdr); err != nil {
+			return nil, 0, err
+		}
+		if int64(hdr.Records) > remain(ir)/4 {
+			return nil, 0, fmt.Errorf("invalid count of records in chunk at %d: %d", hdr.Offset, hdr.Records)
+		}
+		chunks[i] = chunkIndex{
+			offset:  int64(hdr.Offset),
+			stored:  hdr.Stored,
+			raw:     hdr.Raw,
+			flags:   hdr.Flags,
+			records: make([]uint32, hdr.Records),
+		}
+		if err := binary.Read(ir, binary.LittleEndian, chunks[i].records); err != nil {
+			return nil, 0, err
+		}
+	}
+	return chunks, offset, nil
+}
+
+// remain get bytes left in r
+func remain(r *io.SectionReader) int64 {
+	pos, _ := r.Seek(0, io.SeekCurrent)
+	return r.Size() - pos
+}
+
+// encodeRecord append values of record to buf
+func encodeRecord(buf *bytes.Buffer, fields []Field, rec Record) error {
+	if len(rec) != len(fields) {
+		return fmt.Errorf("expect %d fields, got %d", len(fields), len(rec))
+	}
+	for i, f := range fields {
+		n := valueLen(rec[i], f.Type)
+		if n < 0 {
+			return fmt.Errorf("field %s: expect %s values, got %T", f.Name, f.Type, rec[i])
+		}
+		size, variable := f.size()
+		switch {
+		case variable && (size == 0 || int64(n)%size != 0):
+			return fmt.Errorf("field %s: count of values %d is not multiple of %d", f.Name, n, size)
+		case variable && n > math.MaxUint32:
+			return fmt.Errorf("field %s: too many values: %d", f.Name, n)
+		case !variable && int64(n) != size:
+			return fmt.Errorf("field %s: expect %d values, got %d", f.Name, size, n)
+		}
+		if variable {
+			binary.Write(buf, binary.LittleEndian, uint32(n))
+		}
+		binary.Write(buf, binary.LittleEndian, rec[i])
+	}
+	return nil
+}
+
+func decodeRecord(data []byte, fields []Field) (Record, error) {
+	r := bytes.NewReader(data)
+	rec := make(Record, len(fields))
+	for i, f := range fields {
+		size, variable := f.size()
+		n := size
+		if variable {
+			var count uint32
+			if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+				return nil, fmt.Errorf("field %s: %v", f.Name, err)
+			}
+			n = int64(count)
+		}
+		if n*int64(f.Type.Size()) > int64(r.Len()) {
+			return nil, fmt.Errorf("field %s: unexpected end of record", f.Name)
+		}
+		v := makeValue(f.Type, int(n))
+		if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+			return nil, fmt.Errorf("field %s: %v", f.Name, err)
+		}
+		rec[i] = v
+	}
+	return rec, nil
+}
+
+func valueLen(v interface{}, t DType) int {
+	switch v := v.(type) {
+	case []float32:
+		if t == Float32 {
+			return len(v)
+		}
+	case []float64:
+		if t == Float64 {
+			return len(v)
+		}
+	case []int64:
+		if t == Int64 {
+			return len(v)
+		}
+	case []int32:
+		if t == Int32 {
+			return len(v)
+		}
+	case []uint8:
+		if t == Uint8 {
+			return len(v)
+		}
+	}
+	return -1
+}
+
+func makeValue(t DType, n int) interface{} {
+	switch t {
+	case Float32:
+		return make([]float32, n)
+	case Float64:
+		return make([]float64, n)
+	case Int64:
+		return make([]int64, n)
+	case Int32:
+		return make([]int32, n)
+	default:
+		return make([]uint8, n)
+	}
+}
+
+// Float32s convert value of field to float32
+func Float32s(v interface{}) []float32 {
+	switch v := v.(type) {
+	case []float32:
+		return v
+	case []float64:
+		return convert(v)
+	case []int64:
+		return convert(v)
+	case []int32:
+		return convert(v)
+	case []uint8:
+		return convert(v)
+	}
+	return nil
+}
+
+func convert[T float64 | int64 | int32 | uint8](v []T) []float32 {
+	ret := make([]float32, len(v))
+	for i, n := range v {
+		ret[i] = float32(n)
+	}
+	return ret
+}
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
@@ -0,0 +1,241 @@
+package sample
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testFields = []Field{
+	{Name: "x", Type: Float32, Shape: []int64{2}},
+	{Name: "tokens", Type: Int64, Shape: []int64{-1}},
+	{Name: "mask", Type: Uint8, Shape: []int64{-1, 2}},
+}
+
+func testRecord(i int) Record {
+	tokens := make([]int64, i%5)
+	for j := range tokens {
+		tokens[j] = int64(i*10 + j)
+	}
+	return Record{
+		[]float32{float32(i), float32(-i)},
+		tokens,
+		bytes.Repeat([]uint8{uint8(i)}, (i%3)*2),
+	}
+}
+
+func checkRecords(t *testing.T, r *ReaderV2, n int) {
+	if r.Len() != n {
+		t.Fatalf("expect %d records, got %d", n, r.Len())
+	}
+	if r.Field("tokens") != 1 || r.Field("missing") != -1 {
+		t.Fatal("invalid field index")
+	}
+	for i := n - 1; i >= 0; i-- {
+		rec, err := r.ReadRecord(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := testRecord(i)
+		x := rec[0].([]float32)
+		tokens := rec[1].([]int64)
+		mask := rec[2].([]uint8)
+		if x[0] != float32(i) || x[1] != float32(-i) {
+			t.Fatalf("record %d: unexpected x: %v", i, x)
+		}
+		if len(tokens) != len(expect[1].([]int64)) || len(mask) != len(expect[2].([]uint8)) {
+			t.Fatalf("record %d: unexpected length", i)
+		}
+		for j, v := range tokens {
+			if v != int64(i*10+j) {
+				t.Fatalf("record %d: unexpected tokens: %v", i, tokens)
+			}
+		}
+		if !bytes.Equal(mask, expect[2].([]uint8)) {
+			t.Fatalf("record %d: unexpected mask: %v", i, mask)
+		}
+	}
+}
+
+func TestV2(t *testing.T) {
+	for _, opts := range [][]WriterOption{
+		nil,
+		{WithChunkSize(64)},
+		{WithChunkSize(64), WithCompression()},
+	} {
+		var buf bytes.Buffer
+		w, err := NewWriterV2(&buf, testFields, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 100; i++ {
+			if err = w.WriteRecord(testRecord(i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = w.WriteRecord(Record{[]float64{1, 2}, []int64{}, []uint8{}}); err == nil {
+			t.Fatal("expect error of dtype")
+		}
+		if err = w.WriteRecord(Record{[]float32{1}, []int64{}, []uint8{}}); err == nil {
+			t.Fatal("expect error of shape")
+		}
+		if err = w.WriteRecord(Record{[]float32{1, 2}, []int64{}, []uint8{1}}); err == nil {
+			t.Fatal("expect error of variable shape")
+		}
+		if err = w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		data := bytes.NewReader(buf.Bytes())
+		if v, _ := Version(data); v != 2 {
+			t.Fatalf("expect version 2, got %d", v)
+		}
+		r, err := NewReaderV2(data, int64(buf.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkRecords(t, r, 100)
+	}
+}
+
+func TestAppendV2(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "samples")
+	f, err := os.Create(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w, err := NewWriterV2(f, testFields, WithCompression())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err = w.WriteRecord(testRecord(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	old, err := os.ReadFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err = AppendV2(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 10; i < 25; i++ {
+		if err = w.WriteRecord(testRecord(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReaderV2(f, fi.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, r, 25)
+	data, err := os.ReadFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, old) {
+		t.Fatal("old data is changed by appending")
+	}
+}
+
+func TestInvalidIndex(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriterV2(&buf, testFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.WriteRecord(testRecord(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	offset := binary.LittleEndian.Uint64(data[len(data)-trailerSize:])
+	// count of chunks
+	chunks := append([]byte{}, data...)
+	binary.LittleEndian.PutUint32(chunks[offset:], math.MaxUint32)
+	if _, err = NewReaderV2(bytes.NewReader(chunks), int64(len(chunks))); err == nil {
+		t.Fatal("expect error of chunks")
+	}
+	// count of records in the first chunk
+	records := append([]byte{}, data...)
+	binary.LittleEndian.PutUint32(records[offset+4+8+4+4+1:], math.MaxUint32)
+	if _, err = NewReaderV2(bytes.NewReader(records), int64(len(records))); err == nil {
+		t.Fatal("expect error of records")
+	}
+	// raw size of uncompressed chunk differs from stored size
+	raw := append([]byte{}, data...)
+	binary.LittleEndian.PutUint32(raw[offset+4+8+4:], binary.LittleEndian.Uint32(raw[offset+4+8+4:])+1)
+	if _, err = NewReaderV2(bytes.NewReader(raw), int64(len(raw))); err == nil {
+		t.Fatal("expect error of raw size")
+	}
+}
+
+func TestInvalidRawSize(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriterV2(&buf, testFields, WithCompression())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.WriteRecord(testRecord(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	offset := binary.LittleEndian.Uint64(data[len(data)-trailerSize:])
+	binary.LittleEndian.PutUint32(data[offset+4+8+4:], math.MaxUint32)
+	r, err := NewReaderV2(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = r.ReadRecord(0); err == nil {
+		t.Fatal("expect error of raw size")
+	}
+}
+
+func TestFieldOverflow(t *testing.T) {
+	for _, shape := range [][]int64{
+		{math.MaxInt64},
+		{1 << 31, 1 << 31},
+		{-1, 1 << 40},
+	} {
+		f := Field{Name: "x", Type: Float64, Shape: shape}
+		if err := f.check(); err == nil {
+			t.Fatalf("expect error of shape %v", shape)
+		}
+	}
+	f := Field{Name: "x", Type: Float32, Shape: []int64{0, math.MaxInt64}}
+	if err := f.check(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVersion1(t *testing.T) {
+	var buf buffer
+	w := NewWriter(&buf)
+	if err := w.WriteSample([]float32{1, 2}, []float32{3}); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if v, _ := Version(bytes.NewReader(buf.Bytes())); v != 1 {
+		t.Fatalf("expect version 1, got %d", v)
+	}
+}
@@ -0,0 +1,159 @@
+package sample
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+)
+
+// WriterV2 writer of version 2 sample file, records are buffered into chunks,
+// the index is written by Close, so the writer does not need to seek
+type WriterV2 struct {
+	w         io.Writer
+	fields    []Field
+	compress  bool
+	chunkSize int
+
+	offset  int64
+	chunk   bytes.Buffer
+	records []uint32
+	chunks  []chunkIndex
+	closed  bool
+	m       sync.Mutex
+}
+
+// WriterOption option of version 2 writer
+type WriterOption func(*WriterV2)
+
+// WithCompression compress chunks by zstd
+func WithCompression() WriterOption {
+	return func(w *WriterV2) {
+		w.compress = true
+	}
+}
+
+// WithChunkSize set bytes of records in each chunk, default is 1MB,
+// a compressed chunk is decompressed as a whole when reading any record of it
+func WithChunkSize(n int) WriterOption {
+	return func(w *WriterV2) {
+		w.chunkSize = n
+	}
+}
+
+// NewWriterV2 create version 2 sample writer, the header is written immediately
+func NewWriterV2(w io.Writer, fields []Field, opts ...WriterOption) (*WriterV2, error) {
+	ret := newWriterV2(w, fields, opts)
+	n, err := writeHeaderV2(w, fields)
+	if err != nil {
+		return nil, err
+	}
+	ret.offset = n
+	return ret, nil
+}
+
+func newWriterV2(w io.Writer, fields []Field, opts []WriterOption) *WriterV2 {
+	ret := &WriterV2{w: w, fields: fields, chunkSize: 1 << 20}
+	for _, opt := range opts {
+		opt(ret)
+	}
+	if ret.chunkSize <= 0 {
+		ret.chunkSize = 1 << 20
+	}
+	return ret
+}
+
+// AppendFile file which can be appended, e.g. *os.File opened by os.O_RDWR
+type AppendFile interface {
+	io.ReaderAt
+	io.WriteSeeker
+}
+
+// AppendV2 open version 2 sample file to append records, new chunks and
+// the new index are written after the old index by Close, the old index is
+// kept so the file is still readable by it when appending is interrupted
+// before any data is written
+func AppendV2(f AppendFile, opts ...WriterOption) (*WriterV2, error) {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewReaderV2(f, size)
+	if err != nil {
+		return nil, err
+	}
+	w := newWriterV2(f, r.fields, opts)
+	w.chunks = r.chunks
+	w.offset = size
+	return w, nil
+}
+
+// Fields get fields of records
+func (w *WriterV2) Fields() []Field {
+	return w.fields
+}
+
+// WriteRecord write one record, values are in the order of fields
+func (w *WriterV2) WriteRecord(rec Record) error {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if w.closed {
+		return fmt.Errorf("writer is closed")
+	}
+	offset := w.chunk.Len()
+	if err := encodeRecord(&w.chunk, w.fields, rec); err != nil {
+		w.chunk.Truncate(offset)
+		return err
+	}
+	if w.chunk.Len() > math.MaxUint32 {
+		w.chunk.Truncate(offset)
+		return fmt.Errorf("record is too large")
+	}
+	w.records = append(w.records, uint32(offset))
+	if w.chunk.Len() >= w.chunkSize {
+		return w.flush()
+	}
+	return nil
+}
+
+// flush write buffered records as one chunk
+func (w *WriterV2) flush() error {
+	if len(w.records) == 0 {
+		return nil
+	}
+	c := chunkIndex{
+		offset:  w.offset,
+		raw:     uint32(w.chunk.Len()),
+		records: w.records,
+	}
+	data := w.chunk.Bytes()
+	if w.compress {
+		data = zstdEncoder.EncodeAll(data, nil)
+		c.flags |= flagZstd
+	}
+	c.stored = uint32(len(data))
+	if _, err := w.w.Write(data); err != nil {
+		return err
+	}
+	w.offset += int64(len(data))
+	w.chunks = append(w.chunks, c)
+	w.chunk.Reset()
+	w.records = nil
+	return nil
+}
+
+// Close flush buffered records and write index, the underlying writer is not closed
+func (w *WriterV2) Close() error {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if w.closed {
+		return nil
+	}
+	if err := w.flush(); err != nil {
+		return err
+	}
+	w.closed = true
+	_, err := writeIndex(w.w, w.offset, w.chunks)
+	return err
+}
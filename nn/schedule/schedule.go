@@ -0,0 +1,237 @@
+package schedule
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Optimizer optimizer which learning rate can be changed, all optimizers of gotorch
+type Optimizer interface {
+	GetLr() float64
+	SetLr(lr float64)
+}
+
+// Scheduler change learning rate of optimizer, Step is called after each step of optimizer
+type Scheduler interface {
+	Step()
+	Lr() float64
+	State() State
+	SetState(State)
+}
+
+// State state of scheduler which can be saved in checkpoint
+type State struct {
+	Step int64
+	Base float64
+	Lr   float64
+	Best float64
+	Wait int64
+}
+
+// Encode encode state as string, e.g. to save in Meta of checkpoint
+func (s State) Encode() string {
+	return strings.Join([]string{
+		strconv.FormatInt(s.Step, 10),
+		strconv.FormatFloat(s.Base, 'g', -1, 64),
+		strconv.FormatFloat(s.Lr, 'g', -1, 64),
+		strconv.FormatFloat(s.Best, 'g', -1, 64),
+		strconv.FormatInt(s.Wait, 10),
+	}, " ")
+}
+
+// DecodeState decode state encoded by Encode
+func DecodeState(str string) (State, error) {
+	var s State
+	fields := strings.Fields(str)
+	if len(fields) != 5 {
+		return s, fmt.Errorf("invalid scheduler state: %q", str)
+	}
+	var err error
+	if s.Step, err = strconv.ParseInt(fields[0], 10, 64); err != nil {
+		return s, err
+	}
+	if s.Wait, err = strconv.ParseInt(fields[4], 10, 64); err != nil {
+		return s, err
+	}
+	for i, v := range []*float64{&s.Base, &s.Lr, &s.Best} {
+		if *v, err = strconv.ParseFloat(fields[i+1], 64); err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+// schedule learning rate computed from base learning rate and count of steps
+type schedule struct {
+	optm Optimizer
+	base float64
+	step int64
+	fn   func(base float64, step int64) float64
+}
+
+func newSchedule(optm Optimizer, fn func(base float64, step int64) float64) *schedule {
+	s := &schedule{optm: optm, base: optm.GetLr(), fn: fn}
+	optm.SetLr(fn(s.base, 0))
+	return s
+}
+
+func (s *schedule) Step() {
+	s.step++
+	s.optm.SetLr(s.fn(s.base, s.step))
+}
+
+func (s *schedule) Lr() float64 {
+	return s.optm.GetLr()
+}
+
+func (s *schedule) State() State {
+	return State{Step: s.step, Base: s.base, Lr: s.Lr()}
+}
+
+func (s *schedule) SetState(state State) {
+	s.step = state.Step
+	s.base = state.Base
+	s.optm.SetLr(s.fn(s.base, s.step))
+}
+
+// StepLR multiply learning rate by gamma every size steps, size must be positive
+func StepLR(optm Optimizer, size int64, gamma float64) Scheduler {
+	if size <= 0 {
+		panic(fmt.Errorf("invalid step size: %d", size))
+	}
+	return newSchedule(optm, func(base float64, step int64) float64 {
+		return base * math.Pow(gamma, float64(step/size))
+	})
+}
+
+// Exponential multiply learning rate by gamma every step
+func Exponential(optm Optimizer, gamma float64) Scheduler {
+	return newSchedule(optm, func(base float64, step int64) float64 {
+		return base * math.Pow(gamma, float64(step))
+	})
+}
+
+// CosineWarmRestarts anneal learning rate to minLr by cosine in t0 steps and
+// restart, length of each cycle is multiplied by mult, t0 must be positive
+func CosineWarmRestarts(optm Optimizer, t0, mult int64, minLr float64) Scheduler {
+	if t0 <= 0 {
+		panic(fmt.Errorf("invalid length of the first cycle: %d", t0))
+	}
+	return newSchedule(optm, func(base float64, step int64) float64 {
+		cycle := t0
+		if mult <= 1 {
+			step %= t0
+		} else {
+			for step >= cycle {
+				step -= cycle
+				cycle *= mult
+			}
+		}
+		return minLr + (base-minLr)*(1+math.Cos(math.Pi*float64(step)/float64(cycle)))/2
+	})
+}
+
+// WarmupDecay increase learning rate linearly in warmup steps, then
+// decrease it linearly to minLr at total steps, 0 < warmup < total
+func WarmupDecay(optm Optimizer, warmup, total int64, minLr float64) Scheduler {
+	if warmup <= 0 || total <= warmup {
+		panic(fmt.Errorf("invalid warmup steps %d of total steps %d", warmup, total))
+	}
+	return newSchedule(optm, func(base float64, step int64) float64 {
+		if step < warmup {
+			return base * float64(step+1) / float64(warmup)
+		}
+		if step >= total {
+			return minLr
+		}
+		return minLr + (base-minLr)*float64(total-step)/float64(total-warmup)
+	})
+}
+
+// OneCycle learning rate of optimizer is the max learning rate, it starts from
+// max/div, increases to max in pct of total steps, and then decreases to
+// max/div/finalDiv by cosine, pct of total steps must be in [1, total)
+func OneCycle(optm Optimizer, total int64, pct, div, finalDiv float64) Scheduler {
+	if up := int64(pct * float64(total)); up <= 0 || up >= total {
+		panic(fmt.Errorf("invalid pct %g of total steps %d", pct, total))
+	}
+	if div <= 0 || finalDiv <= 0 {
+		panic(fmt.Errorf("invalid div %g or final div %g", div, finalDiv))
+	}
+	return newSchedule(optm, func(base float64, step int64) float64 {
+		initial := base / div
+		final := initial / finalDiv
+		up := int64(pct * float64(total))
+		anneal := func(from, to float64, pos float64) float64 {
+			return to + (from-to)*(1+math.Cos(math.Pi*pos))/2
+		}
+		switch {
+		case step >= total:
+			return final
+		case step < up:
+			return anneal(initial, base, float64(step)/float64(up))
+		default:
+			return anneal(base, final, float64(step-up)/float64(total-up))
+		}
+	})
+}
+
+// Plateau reduce learning rate when the observed metric is not improved
+type Plateau struct {
+	optm      Optimizer
+	factor    float64
+	patience  int64
+	threshold float64
+	minLr     float64
+	best      float64
+	wait      int64
+}
+
+var _ Scheduler = &Plateau{}
+
+// ReduceOnPlateau multiply learning rate by factor when the observed metric is not
+// decreased by more than threshold (relative) for patience observations
+func ReduceOnPlateau(optm Optimizer, factor float64, patience int64, threshold, minLr float64) *Plateau {
+	return &Plateau{
+		optm:      optm,
+		factor:    factor,
+		patience:  patience,
+		threshold: threshold,
+		minLr:     minLr,
+		best:      math.Inf(1),
+	}
+}
+
+// Step learning rate is only changed by Observe
+func (p *Plateau) Step() {}
+
+// Observe observe metric, e.g. validation loss of each epoch
+func (p *Plateau) Observe(metric float64) {
+	// relative to magnitude of best, so negative metrics work too
+	if math.IsInf(p.best, 1) || metric < p.best-math.Abs(p.best)*p.threshold {
+		p.best = metric
+		p.wait = 0
+		return
+	}
+	p.wait++
+	if p.wait > p.patience {
+		p.optm.SetLr(math.Max(p.optm.GetLr()*p.factor, p.minLr))
+		p.wait = 0
+	}
+}
+
+func (p *Plateau) Lr() float64 {
+	return p.optm.GetLr()
+}
+
+func (p *Plateau) State() State {
+	return State{Lr: p.Lr(), Best: p.best, Wait: p.wait}
+}
+
+func (p *Plateau) SetState(state State) {
+	p.best = state.Best
+	p.wait = state.Wait
+	p.optm.SetLr(state.Lr)
+}
@@ -0,0 +1,135 @@
+package schedule
+
+import (
+	"math"
+	"testing"
+)
+
+type fakeOptimizer struct {
+	lr float64
+}
+
+func (o *fakeOptimizer) GetLr() float64 {
+	return o.lr
+}
+
+func (o *fakeOptimizer) SetLr(lr float64) {
+	o.lr = lr
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestSchedulers(t *testing.T) {
+	cases := map[string]struct {
+		new  func(Optimizer) Scheduler
+		want []float64
+	}{
+		"step": {
+			new:  func(o Optimizer) Scheduler { return StepLR(o, 2, 0.1) },
+			want: []float64{1, 1, 0.1, 0.1, 0.01},
+		},
+		"exponential": {
+			new:  func(o Optimizer) Scheduler { return Exponential(o, 0.5) },
+			want: []float64{1, 0.5, 0.25, 0.125},
+		},
+		"cosine": {
+			new:  func(o Optimizer) Scheduler { return CosineWarmRestarts(o, 2, 2, 0) },
+			want: []float64{1, 0.5, 1, 0.8535533905932737, 0.5, 0.14644660940672627, 1},
+		},
+		"warmup": {
+			new:  func(o Optimizer) Scheduler { return WarmupDecay(o, 2, 4, 0) },
+			want: []float64{0.5, 1, 1, 0.5, 0, 0},
+		},
+		"one cycle": {
+			new:  func(o Optimizer) Scheduler { return OneCycle(o, 4, 0.5, 10, 10) },
+			want: []float64{0.1, 0.55, 1, 0.505, 0.01},
+		},
+	}
+	for name, c := range cases {
+		o := &fakeOptimizer{lr: 1}
+		s := c.new(o)
+		for i, want := range c.want {
+			if i > 0 {
+				s.Step()
+			}
+			if !near(s.Lr(), want) {
+				t.Fatalf("%s: step %d: expect %f, got %f", name, i, want, s.Lr())
+			}
+		}
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	cases := map[string]func(Optimizer){
+		"step size":         func(o Optimizer) { StepLR(o, 0, 0.1) },
+		"cosine t0":         func(o Optimizer) { CosineWarmRestarts(o, 0, 2, 0) },
+		"warmup zero":       func(o Optimizer) { WarmupDecay(o, 0, 4, 0) },
+		"warmup equal":      func(o Optimizer) { WarmupDecay(o, 4, 4, 0) },
+		"one cycle up":      func(o Optimizer) { OneCycle(o, 4, 1, 10, 10) },
+		"one cycle zero up": func(o Optimizer) { OneCycle(o, 4, 0.1, 10, 10) },
+		"one cycle div":     func(o Optimizer) { OneCycle(o, 4, 0.5, 0, 10) },
+	}
+	for name, fn := range cases {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("%s: expect panic", name)
+				}
+			}()
+			fn(&fakeOptimizer{lr: 1})
+		}()
+	}
+}
+
+func TestPlateau(t *testing.T) {
+	o := &fakeOptimizer{lr: 1}
+	p := ReduceOnPlateau(o, 0.5, 1, 0.01, 0.2)
+	for _, metric := range []float64{1, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5} {
+		p.Observe(metric)
+	}
+	if !near(o.lr, 0.2) {
+		t.Fatalf("unexpected lr: %f", o.lr)
+	}
+}
+
+func TestPlateauNegative(t *testing.T) {
+	o := &fakeOptimizer{lr: 1}
+	p := ReduceOnPlateau(o, 0.5, 1, 0.01, 0)
+	// -1.005 is not improved by more than 1% of |-1|
+	for _, metric := range []float64{-1, -1.005, -1.005} {
+		p.Observe(metric)
+	}
+	if !near(o.lr, 0.5) {
+		t.Fatalf("unexpected lr: %f", o.lr)
+	}
+	p.Observe(-2)
+	if p.State().Best != -2 || p.State().Wait != 0 {
+		t.Fatalf("improvement not observed: %+v", p.State())
+	}
+}
+
+func TestState(t *testing.T) {
+	o := &fakeOptimizer{lr: 1}
+	s := StepLR(o, 2, 0.1)
+	s.Step()
+	s.Step()
+	state, err := DecodeState(s.State().Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := StepLR(&fakeOptimizer{lr: 1}, 2, 0.1)
+	restored.SetState(state)
+	if !near(restored.Lr(), 0.1) {
+		t.Fatalf("state not restored: %f", restored.Lr())
+	}
+	p := ReduceOnPlateau(o, 0.5, 1, 0, 0)
+	state, err = DecodeState(p.State().Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsInf(state.Best, 1) {
+		t.Fatal("best not restored")
+	}
+}
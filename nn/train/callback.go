@@ -0,0 +1,82 @@
+package train
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// Callback called by trainer, embed Base to implement only some of the methods
+type Callback interface {
+	OnEpochBegin(t *Trainer, epoch int)
+	OnEpochEnd(t *Trainer, epoch int, result Result)
+	OnBatchBegin(t *Trainer, batch int)
+	OnBatchEnd(t *Trainer, batch int, loss float64)
+}
+
+// Base callback does nothing
+type Base struct{}
+
+var _ Callback = Base{}
+
+func (Base) OnEpochBegin(*Trainer, int)        {}
+func (Base) OnEpochEnd(*Trainer, int, Result)  {}
+func (Base) OnBatchBegin(*Trainer, int)        {}
+func (Base) OnBatchEnd(*Trainer, int, float64) {}
+
+type progress struct {
+	Base
+	w       io.Writer
+	every   time.Duration
+	begin   time.Time
+	last    time.Time
+	batches int
+}
+
+// Progress print progress of batches at most once every interval,
+// and losses and metrics of each epoch
+func Progress(w io.Writer, every time.Duration) Callback {
+	return &progress{w: w, every: every}
+}
+
+func (p *progress) OnEpochBegin(t *Trainer, epoch int) {
+	p.begin = time.Now()
+	p.last = time.Time{}
+	p.batches = t.train.Len()
+}
+
+func (p *progress) OnBatchEnd(t *Trainer, batch int, loss float64) {
+	if time.Since(p.last) < p.every {
+		return
+	}
+	p.last = time.Now()
+	if p.batches < 0 {
+		// count of batches is unknown for iterable dataset
+		fmt.Fprintf(p.w, "train: %d, loss=%f\r", batch+1, loss)
+		return
+	}
+	fmt.Fprintf(p.w, "train: %d/%d, loss=%f\r", batch+1, p.batches, loss)
+}
+
+func (p *progress) OnEpochEnd(t *Trainer, epoch int, result Result) {
+	fmt.Fprintf(p.w, "epoch %d, cost=%s, loss=%f", epoch,
+		time.Since(p.begin).String(), result.Loss)
+	if t.validation != nil {
+		fmt.Fprintf(p.w, ", val_loss=%f", result.ValLoss)
+	}
+	printMetrics(p.w, "", result.Metrics)
+	printMetrics(p.w, "val_", result.ValMetrics)
+	fmt.Fprintln(p.w)
+}
+
+func printMetrics(w io.Writer, prefix string, metrics map[string]float64) {
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, ", %s%s=%f", prefix, name, metrics[name])
+	}
+}
@@ -0,0 +1,111 @@
+package train
+
+import (
+	"math"
+
+	"github.com/lwch/gotorch/consts"
+	"github.com/lwch/gotorch/tensor"
+)
+
+// Metric accumulated by batches of one epoch
+type Metric interface {
+	Name() string
+	Reset()
+	Update(pred, target *tensor.Tensor)
+	Value() float64
+}
+
+func float64s(t *tensor.Tensor) []float64 {
+	return t.ToDevice(consts.KCPU).ToScalarType(consts.KDouble).Float64Value()
+}
+
+type accuracy struct {
+	correct, total int
+}
+
+// Accuracy ratio of correct predictions, the class with max value of the last
+// dimension is compared with class index of target, values are rounded by 0.5
+// when pred and target have the same count of elements
+func Accuracy() Metric {
+	return &accuracy{}
+}
+
+func (m *accuracy) Name() string {
+	return "accuracy"
+}
+
+func (m *accuracy) Reset() {
+	m.correct = 0
+	m.total = 0
+}
+
+func (m *accuracy) Update(pred, target *tensor.Tensor) {
+	p := float64s(pred)
+	y := float64s(target)
+	if len(p) == len(y) {
+		for i := range p {
+			if (p[i] >= 0.5) == (y[i] >= 0.5) {
+				m.correct++
+			}
+		}
+		m.total += len(p)
+		return
+	}
+	shapes := pred.Shapes()
+	classes := int(shapes[len(shapes)-1])
+	for i := range y {
+		row := p[i*classes : (i+1)*classes]
+		best := 0
+		for j := range row {
+			if row[j] > row[best] {
+				best = j
+			}
+		}
+		if best == int(y[i]) {
+			m.correct++
+		}
+	}
+	m.total += len(y)
+}
+
+func (m *accuracy) Value() float64 {
+	if m.total == 0 {
+		return math.NaN()
+	}
+	return float64(m.correct) / float64(m.total)
+}
+
+type meanAbsError struct {
+	sum   float64
+	total int
+}
+
+// MeanAbsError mean of absolute error
+func MeanAbsError() Metric {
+	return &meanAbsError{}
+}
+
+func (m *meanAbsError) Name() string {
+	return "mae"
+}
+
+func (m *meanAbsError) Reset() {
+	m.sum = 0
+	m.total = 0
+}
+
+func (m *meanAbsError) Update(pred, target *tensor.Tensor) {
+	p := float64s(pred)
+	y := float64s(target)
+	for i := range p {
+		m.sum += math.Abs(p[i] - y[i])
+	}
+	m.total += len(p)
+}
+
+func (m *meanAbsError) Value() float64 {
+	if m.total == 0 {
+		return math.NaN()
+	}
+	return m.sum / float64(m.total)
+}
@@ -0,0 +1,460 @@
+package train
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	rt "runtime"
+	"strconv"
+
+	"github.com/lwch/gotorch/optimizer"
+	"github.com/lwch/gotorch/tensor"
+	"github.com/lwch/tnn/internal/nnutil"
+	"github.com/lwch/tnn/nn/data"
+	"github.com/lwch/tnn/nn/layer"
+	"github.com/lwch/tnn/nn/net"
+	"github.com/lwch/tnn/nn/schedule"
+)
+
+// BatchFunc split tensors of batch into inputs of the net and target of the loss
+type BatchFunc func(b *data.Batch) (inputs []*tensor.Tensor, target *tensor.Tensor, err error)
+
+// lastTarget the last tensor of batch is target, the others are inputs
+func lastTarget(b *data.Batch) ([]*tensor.Tensor, *tensor.Tensor, error) {
+	if len(b.Tensors) < 2 {
+		return nil, nil, fmt.Errorf("expect at least 2 tensors of batch, got %d", len(b.Tensors))
+	}
+	n := len(b.Tensors) - 1
+	return b.Tensors[:n], b.Tensors[n], nil
+}
+
+// LossFunc compute loss from the first output of the net and target
+type LossFunc func(pred, target *tensor.Tensor) *tensor.Tensor
+
+// Result losses and metrics of one epoch, validation values are NaN or
+// missing when there is no validation dataset
+type Result struct {
+	Epoch      int
+	Loss       float64
+	ValLoss    float64
+	Metrics    map[string]float64
+	ValMetrics map[string]float64
+}
+
+// Trainer train net by epochs
+type Trainer struct {
+	net       *net.Net
+	loss      LossFunc
+	optimizer optimizer.Optimizer
+	train     *data.DataLoader
+	batch     BatchFunc
+
+	epochs     int
+	accumulate int
+	gc         int
+	rand       *net.RandSource
+	validation *data.DataLoader
+	metrics    []Metric
+	callbacks  []Callback
+	scheduler  schedule.Scheduler
+
+	patience int
+	minDelta float64
+	best     float64 // best monitored loss of early stopping
+	wait     int     // epochs without improvement of early stopping
+
+	checkpoint     string
+	every          int
+	resetOptimizer bool
+
+	epoch   int
+	step    int64
+	stopped bool
+}
+
+// Option option of trainer
+type Option func(*Trainer)
+
+// WithEpochs set count of epochs, default is 1
+func WithEpochs(n int) Option {
+	return func(t *Trainer) {
+		t.epochs = n
+	}
+}
+
+// WithBatch set function splitting batches of data loaders into inputs and
+// target, default uses the last tensor as target and the others as inputs
+func WithBatch(fn BatchFunc) Option {
+	return func(t *Trainer) {
+		t.batch = fn
+	}
+}
+
+// WithAccumulation accumulate gradients of n batches before each step,
+// loss of each batch is divided by n
+func WithAccumulation(n int) Option {
+	return func(t *Trainer) {
+		t.accumulate = n
+	}
+}
+
+// WithGC run garbage collection every n steps to release memory of tensors
+// earlier, default is 0 which leaves it to the go runtime
+func WithGC(n int) Option {
+	return func(t *Trainer) {
+		t.gc = n
+	}
+}
+
+// WithRandSource save state of random source in checkpoint, use the same
+// source to shuffle the data loader by data.WithShuffle
+func WithRandSource(src *net.RandSource) Option {
+	return func(t *Trainer) {
+		t.rand = src
+	}
+}
+
+// WithValidation run validation pass after each epoch
+func WithValidation(loader *data.DataLoader) Option {
+	return func(t *Trainer) {
+		t.validation = loader
+	}
+}
+
+// WithMetrics compute metrics of each epoch
+func WithMetrics(metrics ...Metric) Option {
+	return func(t *Trainer) {
+		t.metrics = append(t.metrics, metrics...)
+	}
+}
+
+// WithCallbacks add callbacks
+func WithCallbacks(cbs ...Callback) Option {
+	return func(t *Trainer) {
+		t.callbacks = append(t.callbacks, cbs...)
+	}
+}
+
+// WithScheduler change learning rate by scheduler after each step, schedulers
+// which have Observe method (e.g. ReduceOnPlateau) observe the monitored loss of each
+// epoch, see WithEarlyStopping, state of scheduler is saved in checkpoint
+func WithScheduler(s schedule.Scheduler) Option {
+	return func(t *Trainer) {
+		t.scheduler = s
+	}
+}
+
+// WithEarlyStopping stop training when the monitored loss is not improved by
+// more than minDelta for patience epochs, validation loss is monitored when
+// there is validation dataset, otherwise training loss
+func WithEarlyStopping(patience int, minDelta float64) Option {
+	return func(t *Trainer) {
+		t.patience = patience
+		t.minDelta = minDelta
+	}
+}
+
+// WithCheckpoint save checkpoint to dir every n epochs and after the last epoch,
+// training resumes from the checkpoint when it exists
+func WithCheckpoint(dir string, every int) Option {
+	return func(t *Trainer) {
+		t.checkpoint = dir
+		t.every = every
+	}
+}
+
+// WithResetOptimizer resume from checkpoint with a stateful optimizer (e.g.
+// adam), only its learning rate is restored and its state starts from zero,
+// resuming with a stateful optimizer fails without it
+func WithResetOptimizer() Option {
+	return func(t *Trainer) {
+		t.resetOptimizer = true
+	}
+}
+
+// New create trainer, batches of each epoch are read from loader,
+// batch size and shuffling are set by options of the data loader
+func New(n *net.Net, loss LossFunc, optm optimizer.Optimizer, loader *data.DataLoader, opts ...Option) *Trainer {
+	t := &Trainer{
+		net:        n,
+		loss:       loss,
+		optimizer:  optm,
+		train:      loader,
+		batch:      lastTarget,
+		epochs:     1,
+		accumulate: 1,
+		best:       math.Inf(1),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Net get the net trained
+func (t *Trainer) Net() *net.Net {
+	return t.net
+}
+
+// Optimizer get the optimizer
+func (t *Trainer) Optimizer() optimizer.Optimizer {
+	return t.optimizer
+}
+
+// Epoch get current epoch, starts from 1
+func (t *Trainer) Epoch() int {
+	return t.epoch
+}
+
+// Step get count of optimizer steps
+func (t *Trainer) Step() int64 {
+	return t.step
+}
+
+// Stop stop training after current epoch
+func (t *Trainer) Stop() {
+	t.stopped = true
+}
+
+// Fit run all epochs, returns results of each epoch
+func (t *Trainer) Fit() ([]Result, error) {
+	if t.accumulate <= 0 {
+		return nil, fmt.Errorf("invalid accumulation %d", t.accumulate)
+	}
+	// stopped training is kept stopped when resumed
+	t.stopped = false
+	if err := t.resume(); err != nil {
+		return nil, err
+	}
+	var history []Result
+	for t.epoch < t.epochs && !t.stopped {
+		t.epoch++
+		for _, cb := range t.callbacks {
+			cb.OnEpochBegin(t, t.epoch)
+		}
+		result, err := t.runEpoch()
+		if err != nil {
+			return history, fmt.Errorf("epoch %d: %v", t.epoch, err)
+		}
+		history = append(history, result)
+		for _, cb := range t.callbacks {
+			cb.OnEpochEnd(t, t.epoch, result)
+		}
+		monitor := result.Loss
+		if t.validation != nil {
+			monitor = result.ValLoss
+		}
+		if o, ok := t.scheduler.(interface{ Observe(float64) }); ok {
+			o.Observe(monitor)
+		}
+		if t.patience > 0 {
+			if monitor < t.best-t.minDelta {
+				t.best = monitor
+				t.wait = 0
+			} else if t.wait++; t.wait >= t.patience {
+				t.stopped = true
+			}
+		}
+		if t.checkpoint != "" && (t.stopped || t.epoch == t.epochs ||
+			(t.every > 0 && t.epoch%t.every == 0)) {
+			if err := t.save(); err != nil {
+				return history, fmt.Errorf("save checkpoint: %v", err)
+			}
+		}
+	}
+	return history, nil
+}
+
+// Evaluate compute loss and metrics of batches read from loader,
+// returns error when there is no sample
+func (t *Trainer) Evaluate(loader *data.DataLoader) (float64, map[string]float64, error) {
+	for _, m := range t.metrics {
+		m.Reset()
+	}
+	var sum float64
+	var count int
+	err := loader.ForEach(func(b *data.Batch) error {
+		inputs, target, err := t.batch(b)
+		if err != nil {
+			return err
+		}
+		outputs, err := t.net.Forward(layer.NewContext(false), inputs...)
+		if err != nil {
+			return err
+		}
+		sum += Value(t.loss(outputs[0], target)) * float64(b.Size)
+		count += b.Size
+		for _, m := range t.metrics {
+			m.Update(outputs[0], target)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	if count == 0 {
+		return 0, nil, errNoSample
+	}
+	return sum / float64(count), t.metricValues(), nil
+}
+
+var errNoSample = errors.New("no sample in dataset")
+
+func (t *Trainer) runEpoch() (Result, error) {
+	result := Result{Epoch: t.epoch, ValLoss: math.NaN()}
+	for _, m := range t.metrics {
+		m.Reset()
+	}
+	var sum float64
+	var count, pending int
+	step := func() {
+		t.optimizer.Step(t.net.Params())
+		t.step++
+		if t.scheduler != nil {
+			t.scheduler.Step()
+		}
+		if t.gc > 0 && t.step%int64(t.gc) == 0 {
+			rt.GC()
+		}
+		pending = 0
+	}
+	it := t.train.Iter()
+	defer it.Close()
+	for i := 0; ; i++ {
+		b, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, err
+		}
+		for _, cb := range t.callbacks {
+			cb.OnBatchBegin(t, i)
+		}
+		inputs, target, err := t.batch(b)
+		if err != nil {
+			return result, err
+		}
+		outputs, err := t.net.Forward(layer.NewContext(true), inputs...)
+		if err != nil {
+			return result, err
+		}
+		l := t.loss(outputs[0], target)
+		loss := Value(l)
+		if t.accumulate > 1 {
+			l = l.Div(nnutil.Scalar(float64(t.accumulate), l))
+		}
+		l.Backward()
+		if pending++; pending == t.accumulate {
+			step()
+		}
+		sum += loss * float64(b.Size)
+		count += b.Size
+		for _, m := range t.metrics {
+			m.Update(outputs[0], target)
+		}
+		for _, cb := range t.callbacks {
+			cb.OnBatchEnd(t, i, loss)
+		}
+	}
+	if pending > 0 {
+		step()
+	}
+	if count == 0 {
+		return result, errNoSample
+	}
+	result.Loss = sum / float64(count)
+	result.Metrics = t.metricValues()
+	if t.validation != nil {
+		var err error
+		result.ValLoss, result.ValMetrics, err = t.Evaluate(t.validation)
+		if err != nil {
+			return result, fmt.Errorf("validation: %v", err)
+		}
+	}
+	return result, nil
+}
+
+func (t *Trainer) metricValues() map[string]float64 {
+	ret := make(map[string]float64, len(t.metrics))
+	for _, m := range t.metrics {
+		ret[m.Name()] = m.Value()
+	}
+	return ret
+}
+
+const (
+	schedulerMeta = "scheduler"
+	bestMeta      = "early_stopping_best"
+	waitMeta      = "early_stopping_wait"
+	stoppedMeta   = "stopped"
+)
+
+func (t *Trainer) save() error {
+	cp := net.Checkpoint{
+		Epoch:     int64(t.epoch),
+		Step:      t.step,
+		Rand:      t.rand,
+		Optimizer: t.optimizer,
+	}
+	cp.Meta = map[string]string{
+		bestMeta:    strconv.FormatFloat(t.best, 'g', -1, 64),
+		waitMeta:    strconv.Itoa(t.wait),
+		stoppedMeta: strconv.FormatBool(t.stopped),
+	}
+	if t.scheduler != nil {
+		cp.Meta[schedulerMeta] = t.scheduler.State().Encode()
+	}
+	return t.net.SaveCheckpoint(t.checkpoint, &cp)
+}
+
+func (t *Trainer) resume() error {
+	if t.checkpoint == "" {
+		return nil
+	}
+	if _, err := os.Stat(t.checkpoint); os.IsNotExist(err) {
+		return nil
+	}
+	cp := net.Checkpoint{Rand: t.rand, Optimizer: t.optimizer, ResetOptimizer: t.resetOptimizer}
+	if err := t.net.LoadCheckpoint(t.checkpoint, &cp); err != nil {
+		return fmt.Errorf("resume: %v", err)
+	}
+	t.epoch = int(cp.Epoch)
+	t.step = cp.Step
+	if str, ok := cp.Meta[schedulerMeta]; ok && t.scheduler != nil {
+		state, err := schedule.DecodeState(str)
+		if err != nil {
+			return fmt.Errorf("resume: %v", err)
+		}
+		t.scheduler.SetState(state)
+	}
+	if str, ok := cp.Meta[bestMeta]; ok {
+		best, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return fmt.Errorf("resume: invalid %s: %v", bestMeta, err)
+		}
+		t.best = best
+	}
+	if str, ok := cp.Meta[waitMeta]; ok {
+		wait, err := strconv.Atoi(str)
+		if err != nil {
+			return fmt.Errorf("resume: invalid %s: %v", waitMeta, err)
+		}
+		t.wait = wait
+	}
+	if str, ok := cp.Meta[stoppedMeta]; ok {
+		stopped, err := strconv.ParseBool(str)
+		if err != nil {
+			return fmt.Errorf("resume: invalid %s: %v", stoppedMeta, err)
+		}
+		t.stopped = stopped
+	}
+	return nil
+}
+
+// Value get value of one element tensor, e.g. loss
+func Value(t *tensor.Tensor) float64 {
+	return float64s(t)[0]
+}
@@ -0,0 +1,164 @@
+package train
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/lwch/gotorch/loss"
+	"github.com/lwch/gotorch/optimizer"
+	"github.com/lwch/gotorch/tensor"
+	"github.com/lwch/tnn/nn/data"
+	"github.com/lwch/tnn/nn/layer"
+	"github.com/lwch/tnn/nn/net"
+	"github.com/lwch/tnn/nn/schedule"
+)
+
+// xor dataset of 4 samples, features and label
+func xor() *data.DataLoader {
+	return xorLoader(4)
+}
+
+func xorLoader(batchSize int, opts ...data.Option) *data.DataLoader {
+	samples := make([]data.Sample, 4)
+	for i := range samples {
+		a, b := float32(i&1), float32(i>>1)
+		samples[i] = data.Sample{{a, b}, {float32(i&1 ^ i>>1)}}
+	}
+	opts = append([]data.Option{data.WithBatchSize(batchSize), data.WithWorkers(1)}, opts...)
+	return data.NewLoader(data.FromSlice(samples), opts...)
+}
+
+type counter struct {
+	Base
+	epochs, batches int
+}
+
+func (c *counter) OnEpochEnd(*Trainer, int, Result) {
+	c.epochs++
+}
+
+func (c *counter) OnBatchEnd(*Trainer, int, float64) {
+	c.batches++
+}
+
+func mse(pred, target *tensor.Tensor) *tensor.Tensor {
+	return loss.NewMse(pred, target)
+}
+
+func newNet() *net.Net {
+	var n net.Net
+	n.Add(layer.NewLinear("hidden", 2, 4))
+	n.Add(layer.NewLinear("output", 4, 1))
+	return &n
+}
+
+func TestFit(t *testing.T) {
+	var cb counter
+	src := net.NewRandSource(1)
+	tr := New(newNet(), mse, optimizer.NewAdam(), xorLoader(3, data.WithShuffle(src)),
+		WithEpochs(3), WithAccumulation(2),
+		WithValidation(xor()), WithMetrics(Accuracy(), MeanAbsError()),
+		WithRandSource(src), WithCallbacks(&cb))
+	history, err := tr.Fit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 || cb.epochs != 3 || cb.batches != 6 {
+		t.Fatalf("unexpected epochs: %d, batches: %d", len(history), cb.batches)
+	}
+	if tr.Step() != 3 {
+		t.Fatalf("unexpected steps: %d", tr.Step())
+	}
+	if _, ok := history[0].ValMetrics["accuracy"]; !ok {
+		t.Fatal("missing validation metrics")
+	}
+}
+
+func TestEarlyStopping(t *testing.T) {
+	tr := New(newNet(), mse, optimizer.NewAdam(optimizer.WithAdamLr(0)), xor(),
+		WithEpochs(10), WithEarlyStopping(2, 1))
+	history, err := tr.Fit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("unexpected epochs: %d", len(history))
+	}
+}
+
+func TestResumeEarlyStopping(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "train.ckpt")
+	tr := New(newNet(), mse, optimizer.NewAdam(optimizer.WithAdamLr(0)), xor(),
+		WithEpochs(2), WithEarlyStopping(3, 1), WithCheckpoint(dir, 1))
+	if _, err := tr.Fit(); err != nil {
+		t.Fatal(err)
+	}
+	// one epoch without improvement is saved, two more epochs stop training
+	tr = New(newNet(), mse, optimizer.NewAdam(optimizer.WithAdamLr(0)), xor(),
+		WithEpochs(10), WithEarlyStopping(3, 1), WithCheckpoint(dir, 1), WithResetOptimizer())
+	history, err := tr.Fit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("early stopping state not resumed: %d epochs", len(history))
+	}
+	// stopped training is not continued
+	tr = New(newNet(), mse, optimizer.NewAdam(optimizer.WithAdamLr(0)), xor(),
+		WithEpochs(20), WithEarlyStopping(3, 1), WithCheckpoint(dir, 1), WithResetOptimizer())
+	if history, err = tr.Fit(); err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 0 {
+		t.Fatalf("stopped state not resumed: %d epochs", len(history))
+	}
+}
+
+func TestEmptyDataset(t *testing.T) {
+	empty := data.NewLoader(data.FromSlice(nil))
+	tr := New(newNet(), mse, optimizer.NewAdam(), empty)
+	if _, err := tr.Fit(); err == nil {
+		t.Fatal("expect error of empty dataset")
+	}
+	if _, _, err := tr.Evaluate(empty); err == nil {
+		t.Fatal("expect error of empty dataset")
+	}
+}
+
+func TestResume(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "train.ckpt")
+	tr := New(newNet(), mse, optimizer.NewAdam(), xor(),
+		WithEpochs(2), WithCheckpoint(dir, 1))
+	if _, err := tr.Fit(); err != nil {
+		t.Fatal(err)
+	}
+	// state of adam can not be restored
+	tr = New(newNet(), mse, optimizer.NewAdam(), xor(),
+		WithEpochs(3), WithCheckpoint(dir, 1))
+	if _, err := tr.Fit(); err == nil {
+		t.Fatal("expect error of optimizer state")
+	}
+	tr = New(newNet(), mse, optimizer.NewAdam(), xor(),
+		WithEpochs(3), WithCheckpoint(dir, 1), WithResetOptimizer())
+	history, err := tr.Fit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].Epoch != 3 {
+		t.Fatalf("not resumed: %v", history)
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	optm := optimizer.NewAdam(optimizer.WithAdamLr(1e-3))
+	tr := New(newNet(), mse, optm, xor(),
+		WithEpochs(2),
+		WithScheduler(schedule.Exponential(optm, 0.5)))
+	if _, err := tr.Fit(); err != nil {
+		t.Fatal(err)
+	}
+	if lr := optm.GetLr(); math.Abs(lr-2.5e-4) > 1e-12 {
+		t.Fatalf("unexpected lr: %f", lr)
+	}
+}